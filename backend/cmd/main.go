@@ -2,9 +2,16 @@ package main
 
 import (
 	config2 "cmd/main.go/config"
+	"cmd/main.go/internal/jobs"
+	"cmd/main.go/internal/metrics"
+	"cmd/main.go/internal/notify"
+	"cmd/main.go/internal/outbox"
 	"cmd/main.go/internal/service"
+	"cmd/main.go/internal/sse"
 	"cmd/main.go/internal/storage"
 	"cmd/main.go/internal/transport"
+	"cmd/main.go/internal/webhooks"
+	"cmd/main.go/pkg/lifecycle"
 	"cmd/main.go/pkg/logger"
 	"cmd/main.go/server"
 
@@ -14,56 +21,232 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
+	"syscall"
 	"time"
 )
 
 func main() {
-	// Load configuration
-	config, err := config2.LoadConfig()
+	// "config print [--redacted]" shows the effective configuration and exits
+	if len(os.Args) > 2 && os.Args[1] == "config" && os.Args[2] == "print" {
+		if err := config2.PrintCommand(os.Args[3:], os.Stdout); err != nil {
+			if config2.IsHelp(err) {
+				os.Exit(0)
+			}
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// Load configuration: defaults, -config file, environment, flags
+	config, err := config2.LoadConfig(os.Args[1:])
+	if config2.IsHelp(err) {
+		os.Exit(0)
+	}
 	if err != nil {
-		panic(fmt.Sprintf("Error loading configuration: %s", err.Error()))
+		fmt.Fprintf(os.Stderr, "Error loading configuration:\n%s\n", err)
+		os.Exit(1)
 	}
 	// Initialize logger
-	appLogger := logger.NewLogger()
-	defer appLogger.Sync()
+	appLogger, logLevel, err := logger.NewLogger(logger.Options{
+		Format:     config.LogFormat,
+		Level:      config.LogLevel,
+		Sampling:   config.LogSampling,
+		File:       config.LogFile,
+		MaxSizeMB:  config.LogMaxSizeMB,
+		MaxBackups: config.LogMaxBackups,
+	})
+	if err != nil {
+		panic(fmt.Sprintf("Error initializing logger: %s", err.Error()))
+	}
+	zap.ReplaceGlobals(appLogger)
+
+	// Listen for SIGINT and SIGTERM from the start; shutdown steps are added below
+	shutdown := lifecycle.New(appLogger)
 
 	// Create context with logger
 	ctx := logger.WithLoggerContext(context.Background(), appLogger)
+	// Startup work is interrupted by SIGINT or SIGTERM rather than running to its timeout
+	startCtx := logger.WithLoggerContext(shutdown.Context(), appLogger)
 
 	// Connect to PostgreSQL
-	db := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		config.DBHost, config.DBPort, config.DBUser, config.DBPassword, config.DBName)
-	appLogger.Info("Connecting to PostgreSQL", zap.String("db_url", db))
+	appLogger.Info("Connecting to PostgreSQL", zap.String("dsn", config.RedactedDSN()))
 
-	mystorage := storage.NewDatabase(db)
-	defer mystorage.Close()
-	mystorage.Migrate()
+	connectCtx, cancelConnect := context.WithTimeout(startCtx, config.DBConnectTimeout)
+	mystorage, err := storage.NewDatabase(connectCtx, config.DSN(), storage.Options{
+		MaxOpenConns:    config.DBMaxOpenConns,
+		MaxIdleConns:    config.DBMaxIdleConns,
+		ConnMaxLifetime: config.DBConnMaxLifetime,
+		ConnMaxIdleTime: config.DBConnMaxIdleTime,
+		QueryTimeout:    config.DBQueryTimeout,
+	})
+	cancelConnect()
+	if startCtx.Err() != nil {
+		appLogger.Fatal("Interrupted while connecting to PostgreSQL")
+	}
+	if err != nil {
+		appLogger.Fatal("Failed to connect to PostgreSQL", zap.Error(err))
+	}
+	if err := mystorage.Migrate(startCtx); err != nil {
+		appLogger.Fatal("Failed to migrate database", zap.Error(err))
+	}
 	myservice := service.NewService(mystorage)
-	myhandler := transport.NewHandler(myservice)
+	metrics.RegisterDB(&mystorage)
+	metrics.RegisterCirculation(myservice)
+
+	// Background jobs
+	scheduler := jobs.NewScheduler(&mystorage)
+	var pickupDays atomic.Int64
+	pickupDays.Store(int64(config.HoldPickupDays))
+	if err := jobs.RegisterLibraryJobs(scheduler, myservice, func() int { return int(pickupDays.Load()) }); err != nil {
+		appLogger.Fatal("Failed to register background jobs", zap.Error(err))
+	}
+
+	// Notifications
+	renderer, err := notify.NewRenderer(config.NotifyTemplatesDir)
+	if err != nil {
+		appLogger.Fatal("Failed to load notification templates", zap.Error(err))
+	}
+	var channels []notify.Channel
+	if config.SMTPHost != "" {
+		channels = append(channels, notify.NewSMTPChannel(notify.SMTPConfig{
+			Host:     config.SMTPHost,
+			Port:     config.SMTPPort,
+			User:     config.SMTPUser,
+			Password: config.SMTPPassword,
+			From:     config.SMTPFrom,
+		}))
+	} else {
+		appLogger.Warn("SMTPHost is not set, email notifications are disabled")
+	}
+	notifier := notify.NewNotifier(&mystorage, renderer, channels...)
+	if err := jobs.RegisterNotificationJobs(scheduler, notifier); err != nil {
+		appLogger.Fatal("Failed to register notification jobs", zap.Error(err))
+	}
+
+	// Webhooks
+	dispatcher := webhooks.NewDispatcher(&mystorage)
+	if err := jobs.RegisterWebhookJobs(scheduler, dispatcher); err != nil {
+		appLogger.Fatal("Failed to register webhook jobs", zap.Error(err))
+	}
+
+	// Domain events: subscribers read the outbox
+	relay := outbox.NewRelay(&mystorage)
+	relay.Subscribe("webhooks", dispatcher.Enqueue)
+
+	// Live dashboard updates
+	hub := sse.NewHub(1000)
+	hub.PublishStatistics(myservice.CountStatistics)
+	relay.SubscribeLocal("live-events", hub.HandleOutboxEvent)
+
+	scheduler.Start(ctx)
+	relay.Start(ctx)
+
+	if config.JWTSecret == "" {
+		appLogger.Warn("JWTSecret is not set, staff-only routes are unavailable")
+	}
+	myhandler := transport.NewHandler(myservice, scheduler, hub, logLevel, config.JWTSecret)
+	myhandler.SetCORSOrigins(config.AllowedOrigins())
+	myhandler.SetRateLimit(config.RateLimit, config.RateBurst)
 
 	// Initialize server
-	srv := &server.Server{}
+	serverOpts := server.Options{
+		ReadTimeout:       config.HTTPReadTimeout,
+		ReadHeaderTimeout: config.HTTPReadHeaderTimeout,
+		WriteTimeout:      config.HTTPWriteTimeout,
+		IdleTimeout:       config.HTTPIdleTimeout,
+		MaxHeaderBytes:    config.HTTPMaxHeaderBytes,
+	}
+	if config.TLSCertFile != "" {
+		serverOpts.TLS = &server.TLSOptions{
+			CertFile:          config.TLSCertFile,
+			KeyFile:           config.TLSKeyFile,
+			ClientCAFile:      config.TLSClientCAFile,
+			RequireClientCert: config.TLSRequireClientCert,
+		}
+	}
+	srv, err := server.NewServer(serverOpts)
+	if err != nil {
+		appLogger.Fatal("Failed to configure server", zap.Error(err))
+	}
 
+	// A listener that fails stops the whole server through the regular shutdown
+	serveCtx, stopServing := context.WithCancelCause(ctx)
 	go func() {
-		if err := srv.RunServer(config.AppPort, myhandler.InitRoutes()); err != nil && err != http.ErrServerClosed {
-			appLogger.Fatal("Failed to start server", zap.Error(err))
+		if err := srv.RunServer(config.AppPort, myhandler.InitRoutes(config.MetricsPort == "")); err != nil && err != http.ErrServerClosed {
+			appLogger.Error("Failed to start server", zap.Error(err))
+			stopServing(err)
 		}
 	}()
+	if config.MetricsPort != "" {
+		go func() {
+			if err := srv.RunAdminServer(config.MetricsPort, myhandler.InitAdminRoutes()); err != nil && err != http.ErrServerClosed {
+				appLogger.Error("Failed to start admin server", zap.Error(err))
+				stopServing(err)
+			}
+		}()
+		appLogger.Info("Metrics are served on admin port", zap.String("port", config.MetricsPort))
+	}
+	if config.HTTPRedirectPort != "" {
+		go func() {
+			if err := srv.RunRedirectServer(config.HTTPRedirectPort, config.AppPort); err != nil && err != http.ErrServerClosed {
+				appLogger.Error("Failed to start redirect server", zap.Error(err))
+				stopServing(err)
+			}
+		}()
+		appLogger.Info("Redirecting plain HTTP to HTTPS", zap.String("port", config.HTTPRedirectPort))
+	}
 
-	appLogger.Info("Server is running", zap.String("port", config.AppPort))
+	myhandler.SetReady(true)
+	appLogger.Info("Server is running", zap.String("port", config.AppPort), zap.Bool("tls", serverOpts.TLS != nil))
 
-	// Graceful shutdown
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, os.Interrupt)
-	<-quit
+	// SIGHUP reloads the settings that can change without a restart
+	reload := &reloader{
+		args:       os.Args[1:],
+		current:    config,
+		log:        appLogger,
+		logLevel:   logLevel,
+		handler:    myhandler,
+		notifier:   notifier,
+		pickupDays: &pickupDays,
+	}
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			reload.reload()
+		}
+	}()
 
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
+	// Graceful shutdown, in this order
+	shutdown.OnShutdown("readiness", 0, func(context.Context) error {
+		// Report not ready first so load balancers stop sending new requests
+		myhandler.SetReady(false)
+		appLogger.Info("Draining before shutdown", zap.Duration("drain", config.ShutdownDrain))
+		time.Sleep(config.ShutdownDrain)
+		return nil
+	})
+	shutdown.OnShutdown("http", config.ShutdownTimeout, func(ctx context.Context) error {
+		// Open event streams would keep Shutdown waiting
+		hub.Close()
+		return srv.Shutdown(ctx)
+	})
+	shutdown.OnShutdown("jobs", config.ShutdownTimeout, scheduler.Stop)
+	shutdown.OnShutdown("outbox", config.ShutdownTimeout, relay.Stop)
+	shutdown.OnShutdown("database", config.ShutdownTimeout, func(context.Context) error {
+		return mystorage.Close()
+	})
 
-	if err := srv.Shutdown(ctx); err != nil {
-		appLogger.Error("Server shutdown failed", zap.Error(err))
+	shutdown.Wait(serveCtx)
+	err = shutdown.Shutdown()
+	if err != nil {
+		appLogger.Error("Server shutdown did not complete", zap.Error(err))
+	} else {
+		appLogger.Info("Server shutdown successfully")
+	}
+	appLogger.Sync()
+	if err != nil || context.Cause(serveCtx) != nil {
+		os.Exit(1)
 	}
-
-	mystorage.Close()
-	appLogger.Info("Server shutdown successfully")
 }
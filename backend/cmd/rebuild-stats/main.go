@@ -0,0 +1,48 @@
+// Command rebuild-stats recomputes the daily loan rollups from the full loan history.
+// The server keeps them up to date as loans are issued and returned; run this after
+// fixing data by hand or changing book or patron categories.
+//
+//	go run ./cmd/rebuild-stats [-config config/config.yaml]
+package main
+
+import (
+	config2 "cmd/main.go/config"
+	"cmd/main.go/internal/storage"
+	"context"
+	"fmt"
+	"os"
+)
+
+func main() {
+	config, err := config2.LoadConfig(os.Args[1:])
+	if config2.IsHelp(err) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration:\n%s\n", err)
+		os.Exit(1)
+	}
+
+	connectCtx, cancel := context.WithTimeout(context.Background(), config.DBConnectTimeout)
+	// A one-off run needs no pool tuning; the rebuild itself is not bound by DBQueryTimeout
+	db, err := storage.NewDatabase(connectCtx, config.DSN(), storage.Options{QueryTimeout: config.DBQueryTimeout})
+	cancel()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error connecting to database:", err)
+		os.Exit(1)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+
+	if err := db.Migrate(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, "Error migrating database:", err)
+		os.Exit(1)
+	}
+	rows, err := db.RebuildLoanStats(ctx)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "Error rebuilding loan rollups:", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Rebuilt loan rollups: %d rows\n", rows)
+}
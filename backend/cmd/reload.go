@@ -0,0 +1,96 @@
+package main
+
+import (
+	config2 "cmd/main.go/config"
+	"cmd/main.go/internal/notify"
+	"cmd/main.go/internal/transport"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// reloader applies configuration changes that take effect without a restart (settings
+// tagged reload in config.Config). A reload is checked and prepared in full before
+// anything is applied, and applying cannot fail, so the server runs either the old or the
+// new configuration, never a mix. A reload that changes a restart-only setting is
+// rejected as a whole.
+//
+// Policies are reloadable in two parts. The hold policy (HoldPickupDays) is a reload
+// setting handled here. Loan policies (loan limits, loan periods, daily fines) live in the
+// database instead of the configuration file and are changed through PUT
+// /api/policies/:category, which takes effect immediately without SIGHUP or a restart.
+//
+// reload is called from a single goroutine.
+type reloader struct {
+	args       []string
+	current    config2.Config
+	log        *zap.Logger
+	logLevel   zap.AtomicLevel
+	handler    *transport.Handler
+	notifier   *notify.Notifier
+	pickupDays *atomic.Int64
+}
+
+func (r *reloader) reload() {
+	r.log.Info("Reloading configuration")
+	next, err := config2.LoadConfig(r.args)
+	if err != nil {
+		r.log.Error("Configuration reload failed, keeping the running configuration", zap.Error(err))
+		return
+	}
+
+	changes := config2.Diff(r.current, next)
+	apply, err := r.prepare(next, changes)
+	if err != nil {
+		r.log.Error("Configuration reload failed, keeping the running configuration", zap.Error(err))
+		return
+	}
+	apply()
+
+	for _, change := range changes {
+		r.log.Info("Setting changed", zap.String("setting", change.Setting), zap.String("from", change.Old), zap.String("to", change.New))
+	}
+	r.log.Info("Configuration reloaded", zap.Int("changes", len(changes)))
+	r.current = next
+}
+
+// prepare checks next and builds everything it needs without touching the running
+// server. The returned function only swaps values in and cannot fail.
+func (r *reloader) prepare(next config2.Config, changes []config2.Change) (func(), error) {
+	var restart []string
+	for _, change := range changes {
+		if !change.Reloadable {
+			restart = append(restart, change.Setting)
+		}
+	}
+	if len(restart) > 0 {
+		return nil, fmt.Errorf("these settings need a restart: %s", strings.Join(restart, ", "))
+	}
+
+	// Templates are parsed again even if the directory did not change, to pick up edited files
+	renderer, err := notify.NewRenderer(next.NotifyTemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+	level, err := zapcore.ParseLevel(next.LogLevel)
+	if err != nil {
+		return nil, fmt.Errorf("LogLevel: %w", err)
+	}
+	// The level is only touched when the configuration changes it, so a level set through
+	// the admin endpoint survives unrelated reloads
+	setLevel := next.LogLevel != r.current.LogLevel
+	origins := next.AllowedOrigins()
+
+	return func() {
+		r.notifier.SetRenderer(renderer)
+		r.handler.SetCORSOrigins(origins)
+		r.handler.SetRateLimit(next.RateLimit, next.RateBurst)
+		r.pickupDays.Store(int64(next.HoldPickupDays))
+		if setLevel {
+			r.logLevel.SetLevel(level)
+		}
+	}, nil
+}
@@ -0,0 +1,50 @@
+// Command token issues a staff access token signed with the server's JWTSecret. The
+// secret is loaded like the server loads it: from the environment (JWTSecret or
+// JWTSecret_FILE) or from the configuration file and flags given after --.
+//
+//	JWTSecret=... go run ./cmd/token -sub librarian1 -ttl 12h
+//	JWTSecret_FILE=/run/secrets/jwt go run ./cmd/token -sub librarian1 -- -config config/config.yaml
+package main
+
+import (
+	config2 "cmd/main.go/config"
+	"cmd/main.go/internal/auth"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+)
+
+func main() {
+	subject := flag.String("sub", "", "staff member ID (required)")
+	role := flag.String("role", "librarian", "staff role")
+	ttl := flag.Duration("ttl", 12*time.Hour, "token lifetime")
+	flag.Parse()
+
+	// Arguments after -- are the server's configuration flags
+	config, err := config2.LoadConfig(flag.Args())
+	if config2.IsHelp(err) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error loading configuration:\n%s\n", err)
+		os.Exit(1)
+	}
+	if config.JWTSecret == "" || *subject == "" {
+		fmt.Fprintln(os.Stderr, "JWTSecret and the -sub flag are required")
+		os.Exit(2)
+	}
+
+	now := time.Now()
+	token, err := auth.Sign([]byte(config.JWTSecret), auth.Claims{
+		Subject:   *subject,
+		Role:      *role,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(*ttl).Unix(),
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	fmt.Println(token)
+}
@@ -1,55 +1,241 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
 )
 
-// Config holds the application configuration
+// Config holds the application configuration.
+//
+// Every field is a setting with the same name in the YAML file and, unless an env tag says
+// otherwise, in the environment; the command-line flag is its kebab-case form (DBHost is
+// -db-host). Fields tagged secret can also be read from a file named by <env>_FILE and
+// have no flag, so they never show up in the process list. Fields tagged reload can be
+// changed on a running server with SIGHUP; changing any other field needs a restart.
 type Config struct {
+	// DatabaseURL is a complete connection string (URL or key=value form) that takes
+	// precedence over the individual DB* settings
+	DatabaseURL string `env:"DATABASE_URL" secret:"true"`
+
 	DBHost     string
-	DBPort     string
+	DBPort     string `default:"5432"`
 	DBUser     string
-	DBPassword string
+	DBPassword string `secret:"true"`
 	DBName     string
 	AppPort    string
+
+	// HoldPickupDays is how long a ready hold waits for the patron before it expires
+	HoldPickupDays int `default:"7" reload:"true"`
+
+	// SMTP settings for email notifications; an empty SMTPHost disables email
+	SMTPHost     string
+	SMTPPort     string `default:"25"`
+	SMTPUser     string
+	SMTPPassword string `secret:"true"`
+	SMTPFrom     string `default:"library@localhost"`
+
+	// JWTSecret signs staff access tokens (HS256); without it staff-only routes (admin,
+	// reports, webhooks, live events) answer 503
+	JWTSecret string `secret:"true"`
+
+	// NotifyTemplatesDir loads notification templates from <dir>/<language>/<kind>.{txt,html}
+	// instead of the built-in ones, so they can be edited and reloaded
+	NotifyTemplatesDir string `reload:"true"`
+
+	// MetricsPort serves /metrics on a separate admin port; empty serves it on AppPort
+	MetricsPort string
+
+	// TLSCertFile and TLSKeyFile serve AppPort over HTTPS (HTTP/2 and HTTP/1.1); a renewed
+	// certificate is picked up without a restart. Both empty serve plain HTTP.
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile verifies client certificates (kiosks) signed by these CAs; with
+	// TLSRequireClientCert, clients without a certificate are refused
+	TLSClientCAFile      string
+	TLSRequireClientCert bool
+	// HTTPRedirectPort serves plain HTTP that redirects to HTTPS; requires TLS
+	HTTPRedirectPort string
+
+	// HTTP server limits; a zero timeout means none
+	HTTPReadTimeout       time.Duration `default:"10s"`
+	HTTPReadHeaderTimeout time.Duration `default:"5s"`
+	HTTPWriteTimeout      time.Duration `default:"10s"`
+	HTTPIdleTimeout       time.Duration `default:"2m"`
+	HTTPMaxHeaderBytes    int           `default:"1048576"`
+
+	// CORSOrigins is a comma-separated list of origins allowed to call the API; empty allows any
+	CORSOrigins string `reload:"true"`
+	// RateLimit is the number of API requests per second allowed from one client address,
+	// with bursts of up to RateBurst; 0 disables the limit
+	RateLimit int `reload:"true"`
+	RateBurst int `default:"20" reload:"true"`
+
+	// DBConnectTimeout is how long startup keeps retrying an unavailable database
+	DBConnectTimeout time.Duration `default:"2m"`
+	// Connection pool: at most DBMaxOpenConns connections (0 means no limit), of which up to
+	// DBMaxIdleConns stay open between requests (never more than DBMaxOpenConns). Connections are replaced after
+	// DBConnMaxLifetime and closed after DBConnMaxIdleTime unused; zero means never.
+	DBMaxOpenConns    int           `default:"25"`
+	DBMaxIdleConns    int           `default:"10"`
+	DBConnMaxLifetime time.Duration `default:"30m"`
+	DBConnMaxIdleTime time.Duration `default:"5m"`
+	// DBQueryTimeout bounds each database call, so a slow query cannot hold a request or a
+	// job forever; 0 means no limit
+	DBQueryTimeout time.Duration `default:"30s"`
+	// ShutdownDrain is how long /readyz reports not ready before the server stops,
+	// giving load balancers time to take the instance out of rotation
+	ShutdownDrain time.Duration `default:"5s"`
+	// ShutdownTimeout bounds each shutdown step: draining HTTP requests, finishing background
+	// jobs and flushing the outbox. The process exits with status 1 if a step overruns it.
+	ShutdownTimeout time.Duration `default:"15s"`
+
+	// LogFormat is "json" (default) or "console"; LogLevel is the initial level and can be
+	// changed at runtime through /api/admin/log-level
+	LogFormat string `default:"json"`
+	LogLevel  string `default:"info" reload:"true"`
+	// LogSampling thins out repeated log entries on hot paths
+	LogSampling bool `default:"true"`
+	// LogFile additionally writes logs to a file rotated at LogMaxSizeMB, keeping LogMaxBackups old files
+	LogFile       string
+	LogMaxSizeMB  int `default:"100"`
+	LogMaxBackups int `default:"5"`
 }
 
-// LoadConfig loads configuration from environment variables
-func LoadConfig() (Config, error) {
-	config := Config{
-		DBHost:     os.Getenv("DBHost"),
-		DBPort:     os.Getenv("DBPort"),
-		DBUser:     os.Getenv("DBUser"),
-		DBPassword: os.Getenv("DBPassword"),
-		DBName:     os.Getenv("DBName"),
-		AppPort:    os.Getenv("AppPort"),
+// LoadConfig builds the configuration from, in increasing priority: built-in defaults,
+// the YAML file given by -config, environment variables and command-line flags.
+// args are the command-line arguments without the program name. All problems found
+// are reported together.
+func LoadConfig(args []string) (Config, error) {
+	fs := flag.NewFlagSet(commandName(), flag.ContinueOnError)
+	config, _, err := load(fs, args)
+	return config, err
+}
+
+// validate checks the merged configuration and returns every problem found
+func (c Config) validate() []error {
+	var problems []error
+	required := func(name, value string) {
+		if value == "" {
+			problems = append(problems, fmt.Errorf("%s is required", name))
+		}
+	}
+	port := func(name, value string) {
+		if value == "" {
+			return
+		}
+		if n, err := strconv.Atoi(value); err != nil || n < 1 || n > 65535 {
+			problems = append(problems, fmt.Errorf("%s: invalid port %q", name, value))
+		}
+	}
+	positive := func(name string, value int) {
+		if value <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive, got %d", name, value))
+		}
+	}
+	notNegative := func(name string, value time.Duration) {
+		if value < 0 {
+			problems = append(problems, fmt.Errorf("%s must not be negative, got %s", name, value))
+		}
+	}
+
+	// DATABASE_URL replaces the DB* settings
+	if c.DatabaseURL == "" {
+		required("DBHost", c.DBHost)
+		required("DBUser", c.DBUser)
+		required("DBPassword", c.DBPassword)
+		required("DBName", c.DBName)
+		port("DBPort", c.DBPort)
+	}
+	required("AppPort", c.AppPort)
+	port("AppPort", c.AppPort)
+	port("MetricsPort", c.MetricsPort)
+	port("HTTPRedirectPort", c.HTTPRedirectPort)
+	if c.SMTPHost != "" {
+		port("SMTPPort", c.SMTPPort)
+	}
+
+	positive("HoldPickupDays", c.HoldPickupDays)
+	positive("LogMaxSizeMB", c.LogMaxSizeMB)
+	positive("RateBurst", c.RateBurst)
+	if c.LogMaxBackups < 0 {
+		problems = append(problems, fmt.Errorf("LogMaxBackups must not be negative, got %d", c.LogMaxBackups))
+	}
+	if c.RateLimit < 0 {
+		problems = append(problems, fmt.Errorf("RateLimit must not be negative, got %d", c.RateLimit))
+	}
+	if c.DBMaxOpenConns < 0 {
+		problems = append(problems, fmt.Errorf("DBMaxOpenConns must not be negative, got %d", c.DBMaxOpenConns))
 	}
+	if c.DBMaxIdleConns < 0 {
+		problems = append(problems, fmt.Errorf("DBMaxIdleConns must not be negative, got %d", c.DBMaxIdleConns))
+	}
+	notNegative("DBConnectTimeout", c.DBConnectTimeout)
+	notNegative("DBConnMaxLifetime", c.DBConnMaxLifetime)
+	notNegative("DBConnMaxIdleTime", c.DBConnMaxIdleTime)
+	notNegative("DBQueryTimeout", c.DBQueryTimeout)
+	notNegative("ShutdownDrain", c.ShutdownDrain)
+	notNegative("ShutdownTimeout", c.ShutdownTimeout)
+	notNegative("HTTPReadTimeout", c.HTTPReadTimeout)
+	notNegative("HTTPReadHeaderTimeout", c.HTTPReadHeaderTimeout)
+	notNegative("HTTPWriteTimeout", c.HTTPWriteTimeout)
+	notNegative("HTTPIdleTimeout", c.HTTPIdleTimeout)
+	positive("HTTPMaxHeaderBytes", c.HTTPMaxHeaderBytes)
 
-	// Validate required environment variables
-	missingVars := []string{}
-	if config.DBHost == "" {
-		missingVars = append(missingVars, "DBHost")
+	if (c.TLSCertFile == "") != (c.TLSKeyFile == "") {
+		problems = append(problems, errors.New("TLSCertFile and TLSKeyFile must be set together"))
+	}
+	if c.TLSCertFile == "" && c.TLSClientCAFile != "" {
+		problems = append(problems, errors.New("TLSClientCAFile requires TLSCertFile and TLSKeyFile"))
 	}
-	if config.DBPort == "" {
-		missingVars = append(missingVars, "DBPort")
+	if c.TLSCertFile == "" && c.HTTPRedirectPort != "" {
+		problems = append(problems, errors.New("HTTPRedirectPort requires TLSCertFile and TLSKeyFile"))
 	}
-	if config.DBUser == "" {
-		missingVars = append(missingVars, "DBUser")
+	if c.TLSRequireClientCert && c.TLSClientCAFile == "" {
+		problems = append(problems, errors.New("TLSRequireClientCert requires TLSClientCAFile"))
 	}
-	if config.DBPassword == "" {
-		missingVars = append(missingVars, "DBPassword")
+
+	if c.LogFormat != "json" && c.LogFormat != "console" {
+		problems = append(problems, fmt.Errorf("LogFormat: expected json or console, got %q", c.LogFormat))
 	}
-	if config.DBName == "" {
-		missingVars = append(missingVars, "DBName")
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		problems = append(problems, fmt.Errorf("LogLevel: unknown level %q", c.LogLevel))
 	}
-	if config.AppPort == "" {
-		missingVars = append(missingVars, "AppPort")
+
+	return problems
+}
+
+// AllowedOrigins returns CORSOrigins as a list; nil means any origin is allowed.
+func (c Config) AllowedOrigins() []string {
+	var origins []string
+	for _, origin := range strings.Split(c.CORSOrigins, ",") {
+		switch origin = strings.TrimSpace(origin); origin {
+		case "":
+		case "*":
+			return nil
+		default:
+			origins = append(origins, origin)
+		}
 	}
+	return origins
+}
 
-	if len(missingVars) > 0 {
-		return config, fmt.Errorf("missing required environment variables: %v", missingVars)
+// commandName is the program name used in flag usage messages
+func commandName() string {
+	if len(os.Args) > 0 {
+		return os.Args[0]
 	}
+	return "library"
+}
 
-	return config, nil
+// IsHelp reports whether err means -h was given and usage has been printed, rather
+// than a real failure.
+func IsHelp(err error) bool {
+	return errors.Is(err, flag.ErrHelp)
 }
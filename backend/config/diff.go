@@ -0,0 +1,35 @@
+package config
+
+import "fmt"
+
+// Change is a setting whose value differs between two configurations. Secret values
+// are masked.
+type Change struct {
+	Setting string
+	Old     string
+	New     string
+	// Reloadable is false for settings that only take effect after a restart
+	Reloadable bool
+}
+
+func (c Change) String() string {
+	return fmt.Sprintf("%s: %q -> %q", c.Setting, c.Old, c.New)
+}
+
+// Diff lists the settings that differ between old and new, in declaration order.
+func Diff(old, new Config) []Change {
+	var changes []Change
+	oldSettings, newSettings := settings(&old), settings(&new)
+	for i, s := range oldSettings {
+		before := fmt.Sprint(s.value.Interface())
+		after := fmt.Sprint(newSettings[i].value.Interface())
+		if before == after {
+			continue
+		}
+		if s.secret {
+			before, after = redactedSecret, redactedSecret
+		}
+		changes = append(changes, Change{Setting: s.name, Old: before, New: after, Reloadable: s.reload})
+	}
+	return changes
+}
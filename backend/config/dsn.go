@@ -0,0 +1,51 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redactedSecret replaces passwords in connection strings that are logged
+const redactedSecret = "xxxxx"
+
+// dsnPassword matches the password in a key=value connection string, quoted or not
+var dsnPassword = regexp.MustCompile(`(password\s*=\s*)('(?:[^'\\]|\\.)*'|\S+)`)
+
+// DSN returns the PostgreSQL connection string: DatabaseURL if set, otherwise one
+// assembled from the DB* settings.
+func (c Config) DSN() string {
+	if c.DatabaseURL != "" {
+		return c.DatabaseURL
+	}
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+		dsnValue(c.DBHost), dsnValue(c.DBPort), dsnValue(c.DBUser), dsnValue(c.DBPassword), dsnValue(c.DBName))
+}
+
+// RedactedDSN returns the connection string with the password masked, safe to log.
+func (c Config) RedactedDSN() string {
+	dsn := c.DSN()
+	if u, err := url.Parse(dsn); err == nil && u.Scheme != "" {
+		if _, ok := u.User.Password(); ok {
+			u.User = url.UserPassword(u.User.Username(), redactedSecret)
+		}
+		if q := u.Query(); q.Has("password") {
+			q.Set("password", redactedSecret)
+			u.RawQuery = q.Encode()
+		}
+		return u.String()
+	}
+	return dsnPassword.ReplaceAllString(dsn, "${1}"+redactedSecret)
+}
+
+// dsnValue quotes a key=value connection string value when it is empty or contains
+// spaces, quotes or backslashes, as libpq expects
+func dsnValue(v string) string {
+	if v != "" && !strings.ContainsAny(v, ` '\`) {
+		return v
+	}
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, `'`, `\'`)
+	return "'" + v + "'"
+}
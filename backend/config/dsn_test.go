@@ -0,0 +1,114 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"assembled",
+			Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: "secret", DBName: "library"},
+			"host=db port=5432 user=app password=secret dbname=library sslmode=disable"},
+		{"quoted values",
+			Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: `it's a \ pass`, DBName: ""},
+			`host=db port=5432 user=app password='it\'s a \\ pass' dbname='' sslmode=disable`},
+		{"url wins",
+			Config{DatabaseURL: "postgres://app:secret@db/library", DBHost: "other"},
+			"postgres://app:secret@db/library"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.DSN(); got != tt.want {
+				t.Errorf("DSN = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactedDSN(t *testing.T) {
+	tests := []struct {
+		name   string
+		config Config
+		want   string
+	}{
+		{"key value",
+			Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: "secret", DBName: "library"},
+			"host=db port=5432 user=app password=xxxxx dbname=library sslmode=disable"},
+		{"quoted key value",
+			Config{DBHost: "db", DBPort: "5432", DBUser: "app", DBPassword: `se cr'et`, DBName: "library"},
+			"host=db port=5432 user=app password=xxxxx dbname=library sslmode=disable"},
+		{"key value url setting",
+			Config{DatabaseURL: "host=db password = secret dbname=library"},
+			"host=db password = xxxxx dbname=library"},
+		{"url user password",
+			Config{DatabaseURL: "postgres://app:secret@db:5432/library?sslmode=require"},
+			"postgres://app:xxxxx@db:5432/library?sslmode=require"},
+		{"url query password",
+			Config{DatabaseURL: "postgres://db/library?password=secret&user=app"},
+			"postgres://db/library?password=xxxxx&user=app"},
+		{"url without password",
+			Config{DatabaseURL: "postgres://app@db/library"},
+			"postgres://app@db/library"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.config.RedactedDSN()
+			if got != tt.want {
+				t.Errorf("RedactedDSN = %s, want %s", got, tt.want)
+			}
+			if strings.Contains(got, "secret") || strings.Contains(got, "cr'et") {
+				t.Errorf("RedactedDSN leaks the password: %s", got)
+			}
+		})
+	}
+}
+
+func TestSecretFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	if err := os.WriteFile(path, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name       string
+		env        map[string]string
+		setting    string
+		want       string
+		wantSource string
+		wantErr    string
+	}{
+		{"variable", map[string]string{"DBPassword": "from-env"}, "DBPassword", "from-env", "env DBPassword", ""},
+		{"file", map[string]string{"DBPassword": "", "DBPassword_FILE": path}, "DBPassword", "from-file", "env DBPassword_FILE", ""},
+		{"env tag", map[string]string{"DATABASE_URL_FILE": path}, "DatabaseURL", "from-file", "env DATABASE_URL_FILE", ""},
+		{"both", map[string]string{"DBPassword": "from-env", "DBPassword_FILE": path}, "", "", "", "both DBPassword and DBPassword_FILE are set"},
+		{"missing file", map[string]string{"DBPassword": "", "DBPassword_FILE": path + ".missing"}, "", "", "", "reading DBPassword_FILE"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sources, err := loadWith(t, "", tt.env)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("err = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := reflect.ValueOf(c).FieldByName(tt.setting).String()
+			if got != tt.want || sources[tt.setting] != tt.wantSource {
+				t.Errorf("%s = %q from %q, want %q from %q", tt.setting, got, sources[tt.setting], tt.want, tt.wantSource)
+			}
+		})
+	}
+}
@@ -0,0 +1,255 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Sources records where each setting's effective value came from, by setting name:
+// "default", "file <path>", "env <NAME>", "env <NAME>_FILE" or "flag -<name>".
+type Sources map[string]string
+
+// setting is one Config field with the names it is read under
+type setting struct {
+	name   string // field name, also the YAML key
+	env    string
+	flag   string // empty for secrets
+	secret bool
+	reload bool
+	def    string
+	value  reflect.Value
+}
+
+// settings lists the fields of c in declaration order, addressable for assignment
+func settings(c *Config) []setting {
+	v := reflect.ValueOf(c).Elem()
+	t := v.Type()
+	list := make([]setting, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		s := setting{
+			name:   f.Name,
+			env:    f.Name,
+			secret: f.Tag.Get("secret") == "true",
+			reload: f.Tag.Get("reload") == "true",
+			def:    f.Tag.Get("default"),
+			value:  v.Field(i),
+		}
+		if env := f.Tag.Get("env"); env != "" {
+			s.env = env
+		}
+		if !s.secret {
+			s.flag = kebab(f.Name)
+		}
+		list = append(list, s)
+	}
+	return list
+}
+
+// set parses raw according to the field type and assigns it
+func (s setting) set(raw string) error {
+	switch {
+	case s.value.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", raw)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(raw)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", raw)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", raw)
+		}
+		s.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", s.value.Type())
+	}
+	return nil
+}
+
+// flagValue collects a flag as raw text, so it is parsed and validated like other layers
+type flagValue struct {
+	raw    string
+	isBool bool
+}
+
+func (v *flagValue) String() string     { return v.raw }
+func (v *flagValue) Set(s string) error { v.raw = s; return nil }
+func (v *flagValue) IsBoolFlag() bool   { return v.isBool }
+
+// load registers the configuration flags on fs, parses args and merges the layers.
+// The returned error joins every problem found; the Config is filled as far as possible.
+func load(fs *flag.FlagSet, args []string) (Config, Sources, error) {
+	var config Config
+	list := settings(&config)
+
+	path := fs.String("config", "", "path to a YAML configuration file")
+	flags := map[string]*flagValue{}
+	for _, s := range list {
+		if s.flag == "" {
+			continue
+		}
+		v := &flagValue{isBool: s.value.Kind() == reflect.Bool}
+		flags[s.flag] = v
+		fs.Var(v, s.flag, fmt.Sprintf("overrides %s (env %s)", s.name, s.env))
+	}
+	if err := fs.Parse(args); err != nil {
+		return config, nil, err
+	}
+
+	var problems []error
+	sources := Sources{}
+	apply := func(s setting, raw, source string) {
+		if err := s.set(raw); err != nil {
+			problems = append(problems, fmt.Errorf("%s from %s: %w", s.name, source, err))
+			return
+		}
+		sources[s.name] = source
+	}
+
+	// Built-in defaults
+	for _, s := range list {
+		sources[s.name] = "default"
+		if s.def != "" {
+			apply(s, s.def, "default")
+		}
+	}
+
+	// YAML file
+	if *path != "" {
+		values, err := readFile(*path)
+		if err != nil {
+			problems = append(problems, err)
+		}
+		byName := map[string]setting{}
+		for _, s := range list {
+			byName[s.name] = s
+		}
+		for _, key := range sortedKeys(values) {
+			s, ok := byName[key]
+			if !ok {
+				problems = append(problems, fmt.Errorf("%s: unknown setting %q", *path, key))
+				continue
+			}
+			apply(s, values[key], "file "+*path)
+		}
+	}
+
+	// Environment; secrets may come from <env>_FILE
+	for _, s := range list {
+		if s.secret {
+			raw, source, err := getSecret(s.env)
+			if err != nil {
+				problems = append(problems, err)
+			} else if raw != "" {
+				apply(s, raw, source)
+			}
+			continue
+		}
+		if raw := os.Getenv(s.env); raw != "" {
+			apply(s, raw, "env "+s.env)
+		}
+	}
+
+	// Command-line flags
+	fs.Visit(func(f *flag.Flag) {
+		for _, s := range list {
+			if s.flag == f.Name {
+				apply(s, flags[f.Name].raw, "flag -"+f.Name)
+			}
+		}
+	})
+
+	problems = append(problems, config.validate()...)
+	return config, sources, errors.Join(problems...)
+}
+
+// readFile reads a flat YAML mapping of setting names to scalar values
+func readFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading config file: %w", err)
+	}
+	var nodes map[string]yaml.Node
+	if err := yaml.Unmarshal(data, &nodes); err != nil {
+		return nil, fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string, len(nodes))
+	var problems []error
+	for key, node := range nodes {
+		switch {
+		case node.Kind != yaml.ScalarNode:
+			problems = append(problems, fmt.Errorf("%s: %s must be a single value", path, key))
+		case node.Tag == "!!null":
+			values[key] = ""
+		default:
+			values[key] = node.Value
+		}
+	}
+	return values, errors.Join(problems...)
+}
+
+// getSecret reads a secret from the variable key or from the file named by key_FILE and
+// reports which one was used. Setting both is an error, so a stale variable cannot
+// silently override the file.
+func getSecret(key string) (value, source string, err error) {
+	value = os.Getenv(key)
+	path := os.Getenv(key + "_FILE")
+	if path == "" {
+		return value, "env " + key, nil
+	}
+	if value != "" {
+		return "", "", fmt.Errorf("both %s and %s_FILE are set", key, key)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", fmt.Errorf("reading %s_FILE: %w", key, err)
+	}
+	// Files written by editors and echo usually end with a newline
+	return strings.TrimRight(string(data), "\r\n"), "env " + key + "_FILE", nil
+}
+
+// kebab turns a field name into a flag name: DBHost -> db-host, LogMaxSizeMB -> log-max-size-mb
+func kebab(name string) string {
+	var b strings.Builder
+	runes := []rune(name)
+	for i, r := range runes {
+		if unicode.IsUpper(r) && i > 0 {
+			prevLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('-')
+			}
+		}
+		b.WriteRune(unicode.ToLower(r))
+	}
+	return b.String()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,137 @@
+package config
+
+import (
+	"flag"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// minimal is the environment of a valid configuration, which the cases below override
+var minimal = map[string]string{
+	"DBHost":     "db",
+	"DBUser":     "app",
+	"DBPassword": "secret",
+	"DBName":     "library",
+	"AppPort":    "8080",
+}
+
+// loadWith writes file (when not empty) as the -config file, sets env and loads args
+func loadWith(t *testing.T, file string, env map[string]string, args ...string) (Config, Sources, error) {
+	t.Helper()
+	for k, v := range minimal {
+		t.Setenv(k, v)
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	if file != "" {
+		path := filepath.Join(t.TempDir(), "config.yaml")
+		if err := os.WriteFile(path, []byte(file), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		args = append([]string{"-config", path}, args...)
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	return load(fs, args)
+}
+
+func TestLoadLayers(t *testing.T) {
+	tests := []struct {
+		name       string
+		file       string
+		env        map[string]string
+		args       []string
+		setting    string
+		want       any
+		wantSource string // the file source is matched by prefix, its path is temporary
+	}{
+		{"default", "", nil, nil, "HoldPickupDays", 7, "default"},
+		{"file over default", "HoldPickupDays: 10\n", nil, nil, "HoldPickupDays", 10, "file "},
+		{"env over file", "HoldPickupDays: 10\n", map[string]string{"HoldPickupDays": "12"}, nil,
+			"HoldPickupDays", 12, "env HoldPickupDays"},
+		{"flag over env", "HoldPickupDays: 10\n", map[string]string{"HoldPickupDays": "12"}, []string{"-hold-pickup-days", "14"},
+			"HoldPickupDays", 14, "flag -hold-pickup-days"},
+		{"env tag", "", map[string]string{"DATABASE_URL": "postgres://db/library"}, nil,
+			"DatabaseURL", "postgres://db/library", "env DATABASE_URL"},
+		{"duration", "ShutdownTimeout: 1m\n", nil, nil, "ShutdownTimeout", time.Minute, "file "},
+		{"bool flag", "", nil, []string{"-log-sampling=false"}, "LogSampling", false, "flag -log-sampling"},
+		{"null in file", "CORSOrigins: ~\n", nil, nil, "CORSOrigins", "", "file "},
+		{"unset", "", nil, nil, "MetricsPort", "", "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, sources, err := loadWith(t, tt.file, tt.env, tt.args...)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := reflect.ValueOf(c).FieldByName(tt.setting).Interface(); got != tt.want {
+				t.Errorf("%s = %v, want %v", tt.setting, got, tt.want)
+			}
+			if got := sources[tt.setting]; !strings.HasPrefix(got, tt.wantSource) || (tt.wantSource != "file " && got != tt.wantSource) {
+				t.Errorf("source = %q, want %q", got, tt.wantSource)
+			}
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		file string
+		env  map[string]string
+		args []string
+		want []string // every problem is reported
+	}{
+		{"unknown file setting", "HoldPickupDay: 10\n", nil, nil, []string{`unknown setting "HoldPickupDay"`}},
+		{"nested value", "CORSOrigins:\n  - a\n", nil, nil, []string{"CORSOrigins must be a single value"}},
+		{"bad types in several layers", "HoldPickupDays: soon\n", map[string]string{"ShutdownTimeout": "15"}, []string{"-rate-limit", "x"},
+			[]string{`HoldPickupDays from file`, `ShutdownTimeout from env ShutdownTimeout: invalid duration "15"`, `RateLimit from flag -rate-limit: invalid integer "x"`}},
+		{"missing required", "", map[string]string{"DBHost": "", "AppPort": ""}, nil,
+			[]string{"DBHost is required", "AppPort is required"}},
+		{"invalid port", "", map[string]string{"AppPort": "99999"}, nil, []string{`AppPort: invalid port "99999"`}},
+		{"tls half set", "", map[string]string{"TLSCertFile": "cert.pem"}, nil, []string{"TLSCertFile and TLSKeyFile must be set together"}},
+		{"secret has no flag", "", nil, []string{"-db-password", "x"}, []string{"flag provided but not defined: -db-password"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := loadWith(t, tt.file, tt.env, tt.args...)
+			if err == nil {
+				t.Fatal("no error")
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not mention %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestKebab(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"AppPort", "app-port"},
+		{"DBHost", "db-host"},
+		{"DatabaseURL", "database-url"},
+		{"LogMaxSizeMB", "log-max-size-mb"},
+		{"TLSClientCAFile", "tls-client-ca-file"},
+		{"HTTPReadHeaderTimeout", "http-read-header-timeout"},
+		{"SMTPFrom", "smtp-from"},
+	}
+
+	for _, tt := range tests {
+		if got := kebab(tt.name); got != tt.want {
+			t.Errorf("kebab(%s) = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"text/tabwriter"
+)
+
+// PrintCommand implements "config print [--redacted] [flags]": it loads the configuration
+// the way the server does and writes every setting with its effective value and the layer
+// it came from. Validation problems are returned after the table is written, so a broken
+// configuration can still be inspected.
+func PrintCommand(args []string, w io.Writer) error {
+	fs := flag.NewFlagSet("config print", flag.ContinueOnError)
+	redacted := fs.Bool("redacted", false, "mask passwords and other secrets")
+	config, sources, err := load(fs, args)
+	if sources == nil {
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "SETTING\tVALUE\tSOURCE")
+	for _, s := range settings(&config) {
+		value := fmt.Sprint(s.value.Interface())
+		switch {
+		case value == "":
+			value = `""`
+		case s.secret && *redacted && s.name == "DatabaseURL":
+			// Where the database is stays visible, only the password is masked
+			value = Config{DatabaseURL: value}.RedactedDSN()
+		case s.secret && *redacted:
+			value = redactedSecret
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", s.name, value, sources[s.name])
+	}
+	if flushErr := tw.Flush(); flushErr != nil {
+		return flushErr
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+)
+
+// ErrInvalidToken возвращается для неподписанного, испорченного или просроченного токена
+var ErrInvalidToken = errors.New("invalid token")
+
+// Claims — поля токена сотрудника. Subject — идентификатор сотрудника.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Role      string `json:"role,omitempty"`
+	IssuedAt  int64  `json:"iat,omitempty"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+var encoding = base64.RawURLEncoding
+
+// header — единственный поддерживаемый заголовок: HS256
+var header = encoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+
+// Sign выпускает JWT, подписанный HS256.
+func Sign(secret []byte, claims Claims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	unsigned := header + "." + encoding.EncodeToString(payload)
+	return unsigned + "." + signature(secret, unsigned), nil
+}
+
+// Verify проверяет подпись HS256 и срок действия токена.
+func Verify(secret []byte, token string, now time.Time) (Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var head struct {
+		Alg string `json:"alg"`
+	}
+	rawHead, err := encoding.DecodeString(parts[0])
+	if err != nil || json.Unmarshal(rawHead, &head) != nil || head.Alg != "HS256" {
+		return Claims{}, ErrInvalidToken
+	}
+
+	expected := signature(secret, parts[0]+"."+parts[1])
+	if !hmac.Equal([]byte(expected), []byte(parts[2])) {
+		return Claims{}, ErrInvalidToken
+	}
+
+	var claims Claims
+	rawClaims, err := encoding.DecodeString(parts[1])
+	if err != nil || json.Unmarshal(rawClaims, &claims) != nil {
+		return Claims{}, ErrInvalidToken
+	}
+	if claims.Subject == "" || claims.ExpiresAt == 0 || now.Unix() >= claims.ExpiresAt {
+		return Claims{}, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func signature(secret []byte, unsigned string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(unsigned))
+	return encoding.EncodeToString(mac.Sum(nil))
+}
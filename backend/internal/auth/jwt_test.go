@@ -0,0 +1,52 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerify(t *testing.T) {
+	secret := []byte("secret")
+	now := time.Unix(1_700_000_000, 0)
+	sign := func(secret []byte, claims Claims) string {
+		token, err := Sign(secret, claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	valid := sign(secret, Claims{Subject: "42", ExpiresAt: now.Unix() + 60})
+
+	tests := []struct {
+		name    string
+		token   string
+		wantErr bool
+	}{
+		{"valid", valid, false},
+		{"other secret", sign([]byte("other"), Claims{Subject: "42", ExpiresAt: now.Unix() + 60}), true},
+		{"expired", sign(secret, Claims{Subject: "42", ExpiresAt: now.Unix()}), true},
+		{"no expiry", sign(secret, Claims{Subject: "42"}), true},
+		{"no subject", sign(secret, Claims{ExpiresAt: now.Unix() + 60}), true},
+		{"tampered payload", strings.Replace(valid, ".", ".x", 1), true},
+		{"alg none", encoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + strings.Split(valid, ".")[1] + ".", true},
+		{"not a jwt", "token", true},
+		{"empty", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := Verify(secret, tt.token, now)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Errorf("err = %v, want %v", err, ErrInvalidToken)
+				}
+				return
+			}
+			if err != nil || claims.Subject != "42" {
+				t.Errorf("Verify = %+v, %v", claims, err)
+			}
+		})
+	}
+}
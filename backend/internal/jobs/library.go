@@ -0,0 +1,141 @@
+package jobs
+
+import (
+	"cmd/main.go/internal/notify"
+	"cmd/main.go/internal/service"
+	"cmd/main.go/internal/webhooks"
+	"cmd/main.go/pkg/logger"
+	"context"
+
+	"go.uber.org/zap"
+)
+
+// Имена задач библиотеки
+const (
+	JobMarkOverdueLoans  = "mark-overdue-loans"
+	JobExpireHolds       = "expire-uncollected-holds"
+	JobRefreshStatistics = "refresh-statistics"
+
+	JobQueueNotifications = "queue-notifications"
+	JobSendNotifications  = "send-notifications"
+
+	JobDeliverWebhooks = "deliver-webhooks"
+	JobPruneOutbox     = "prune-outbox"
+)
+
+// RegisterLibraryJobs регистрирует периодические задачи библиотеки. pickupDays возвращает,
+// сколько дней готовая бронь ждет читателя на полке выдачи, прежде чем будет снята; срок
+// читается при каждом запуске, так что его можно менять без перезапуска.
+func RegisterLibraryJobs(s *Scheduler, svc service.Service, pickupDays func() int) error {
+	jobs := []Job{
+		{
+			Name:       JobMarkOverdueLoans,
+			Schedule:   "15 0 * * *",
+			MaxRetries: 3,
+			Run: func(ctx context.Context) error {
+				n, err := svc.MarkOverdueLoans(ctx)
+				if err != nil {
+					return err
+				}
+				logger.FromContext(ctx).Info("Marked overdue loans", zap.Int("count", n))
+				return nil
+			},
+		},
+		{
+			Name:       JobExpireHolds,
+			Schedule:   "30 * * * *",
+			MaxRetries: 3,
+			Run: func(ctx context.Context) error {
+				n, err := svc.ExpireHolds(ctx, pickupDays())
+				if err != nil {
+					return err
+				}
+				logger.FromContext(ctx).Info("Expired uncollected holds", zap.Int("count", n))
+				return nil
+			},
+		},
+		{
+			Name:       JobPruneOutbox,
+			Schedule:   "0 3 * * *",
+			MaxRetries: 1,
+			Run: func(ctx context.Context) error {
+				n, err := svc.PruneOutbox(ctx)
+				if err != nil {
+					return err
+				}
+				logger.FromContext(ctx).Info("Pruned outbox", zap.Int("count", n))
+				return nil
+			},
+		},
+		{
+			Name:       JobRefreshStatistics,
+			Schedule:   "*/5 * * * *",
+			MaxRetries: 2,
+			Run: func(ctx context.Context) error {
+				return svc.RefreshStatistics(ctx)
+			},
+		},
+	}
+
+	for _, job := range jobs {
+		if err := s.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterNotificationJobs регистрирует постановку уведомлений в очередь и их рассылку.
+func RegisterNotificationJobs(s *Scheduler, notifier *notify.Notifier) error {
+	jobs := []Job{
+		{
+			Name:       JobQueueNotifications,
+			Schedule:   "*/10 * * * *",
+			MaxRetries: 2,
+			Run: func(ctx context.Context) error {
+				n, err := notifier.Queue(ctx)
+				if err != nil {
+					return err
+				}
+				logger.FromContext(ctx).Info("Queued notifications", zap.Int("count", n))
+				return nil
+			},
+		},
+		{
+			Name:     JobSendNotifications,
+			Schedule: "*/2 * * * *",
+			// Неотправленные сообщения остаются в очереди, повторять проход нет смысла
+			MaxRetries: 0,
+			Run: func(ctx context.Context) error {
+				n, err := notifier.Dispatch(ctx)
+				if n > 0 {
+					logger.FromContext(ctx).Info("Sent notifications", zap.Int("count", n))
+				}
+				return err
+			},
+		},
+	}
+
+	for _, job := range jobs {
+		if err := s.Register(job); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RegisterWebhookJobs регистрирует доставку вебхуков. Задержки между повторными
+// попытками хранятся в очереди, поэтому задача просто запускается каждую минуту.
+func RegisterWebhookJobs(s *Scheduler, dispatcher *webhooks.Dispatcher) error {
+	return s.Register(Job{
+		Name:     JobDeliverWebhooks,
+		Schedule: "* * * * *",
+		Run: func(ctx context.Context) error {
+			n, err := dispatcher.Deliver(ctx)
+			if n > 0 {
+				logger.FromContext(ctx).Info("Delivered webhooks", zap.Int("count", n))
+			}
+			return err
+		},
+	})
+}
@@ -0,0 +1,334 @@
+package jobs
+
+import (
+	"cmd/main.go/models"
+	"cmd/main.go/pkg/logger"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+// ErrUnknownJob возвращается при запуске незарегистрированной задачи
+var ErrUnknownJob = errors.New("unknown job")
+
+// ErrJobBusy возвращается, если задача уже выполняется на этой или другой реплике
+var ErrJobBusy = errors.New("job is already running")
+
+// Store хранит историю запусков и дает блокировку между репликами
+type Store interface {
+	TryJobLock(ctx context.Context, job string) (unlock func(), ok bool, err error)
+	StartJobRun(ctx context.Context, job string, attempt int) (int, error)
+	FinishJobRun(ctx context.Context, id int, runErr error) error
+	GetJobRuns(ctx context.Context, job string, limit int) ([]models.JobRun, error)
+}
+
+// Job — периодическая фоновая задача.
+type Job struct {
+	Name     string
+	Schedule string // cron-выражение, например "*/15 * * * *"
+	// MaxRetries — число повторных попыток после ошибки, с экспоненциальной задержкой
+	MaxRetries int
+	Run        func(ctx context.Context) error
+}
+
+// Info описывает зарегистрированную задачу для админского API.
+type Info struct {
+	Name     string          `json:"name"`
+	Schedule string          `json:"schedule"`
+	NextRun  time.Time       `json:"nextRun"`
+	Running  bool            `json:"running"`
+	LastRuns []models.JobRun `json:"lastRuns"`
+}
+
+type entry struct {
+	job      Job
+	schedule cron.Schedule
+	running  bool
+	nextRun  time.Time
+}
+
+// Scheduler запускает задачи по расписанию. Каждый запуск берет advisory-блокировку
+// PostgreSQL, поэтому при нескольких репликах задача выполняется только на одной из них.
+type Scheduler struct {
+	store        Store
+	retryBackoff time.Duration
+
+	// mu также защищает wg.Add вместе с проверкой stopping: после того как Stop закрыл
+	// stopping, новые запуски не регистрируются и wg.Wait не пересекается с wg.Add
+	mu       sync.Mutex
+	entries  map[string]*entry
+	order    []string
+	ctx      context.Context
+	cancel   context.CancelFunc
+	stopping chan struct{}
+	wg       sync.WaitGroup
+}
+
+// NewScheduler создает планировщик без задач.
+func NewScheduler(store Store) *Scheduler {
+	return &Scheduler{
+		store:        store,
+		retryBackoff: time.Second,
+		entries:      make(map[string]*entry),
+		stopping:     make(chan struct{}),
+	}
+}
+
+// Register добавляет задачу; вызывается до Start.
+func (s *Scheduler) Register(job Job) error {
+	schedule, err := cron.ParseStandard(job.Schedule)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", job.Name, job.Schedule, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, exists := s.entries[job.Name]; exists {
+		return fmt.Errorf("job %s: already registered", job.Name)
+	}
+	s.entries[job.Name] = &entry{job: job, schedule: schedule}
+	s.order = append(s.order, job.Name)
+	return nil
+}
+
+// Start запускает по горутине на задачу. Задачи останавливаются при отмене ctx или
+// вызове Stop; Wait дожидается завершения выполняющихся запусков.
+func (s *Scheduler) Start(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.ctx, s.cancel = ctx, cancel
+	if s.isStopping() {
+		return
+	}
+	for _, name := range s.order {
+		s.wg.Add(1)
+		go s.loop(ctx, name)
+	}
+}
+
+// Wait блокируется, пока не завершатся все циклы и запуски задач.
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+// Stop перестает запускать задачи и ждет, пока завершатся уже выполняющиеся запуски.
+// Если ctx истекает раньше, запуски отменяются, и Stop после их завершения возвращает
+// ошибку ctx.
+func (s *Scheduler) Stop(ctx context.Context) error {
+	s.mu.Lock()
+	if !s.isStopping() {
+		close(s.stopping)
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		if s.cancel != nil {
+			s.cancel()
+		}
+		s.mu.Unlock()
+		<-done
+		return ctx.Err()
+	}
+}
+
+// isStopping сообщает, вызван ли Stop
+func (s *Scheduler) isStopping() bool {
+	select {
+	case <-s.stopping:
+		return true
+	default:
+		return false
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, name string) {
+	defer s.wg.Done()
+
+	for {
+		s.mu.Lock()
+		e := s.entries[name]
+		e.nextRun = e.schedule.Next(time.Now())
+		wait := time.Until(e.nextRun)
+		s.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-s.stopping:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := s.run(ctx, name); err != nil && !errors.Is(err, ErrJobBusy) {
+			logger.FromContext(ctx).Error("Background job failed", zap.String("job", name), zap.Error(err))
+		}
+	}
+}
+
+// stallGrace — насколько цикл задачи может опоздать к сроку запуска, прежде чем
+// планировщик считается неисправным
+const stallGrace = time.Minute
+
+// Healthy возвращает ошибку, если планировщик не запущен, уже остановлен или цикл
+// какой-то задачи пропустил срок запуска и не выполняет ее.
+func (s *Scheduler) Healthy() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil {
+		return errors.New("scheduler is not started")
+	}
+	if s.ctx.Err() != nil || s.isStopping() {
+		return errors.New("scheduler is stopped")
+	}
+	for _, name := range s.order {
+		e := s.entries[name]
+		if !e.running && !e.nextRun.IsZero() && time.Since(e.nextRun) > stallGrace {
+			return fmt.Errorf("job %s missed its run at %s", name, e.nextRun.Format(time.RFC3339))
+		}
+	}
+	return nil
+}
+
+// Trigger запускает задачу в фоне немедленно, вне расписания.
+func (s *Scheduler) Trigger(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[name]
+	ctx := s.ctx
+	switch {
+	case !ok:
+		return ErrUnknownJob
+	case ctx == nil:
+		return errors.New("scheduler is not started")
+	case s.isStopping():
+		return errors.New("scheduler is stopping")
+	case e.running:
+		return ErrJobBusy
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		if err := s.run(ctx, name); err != nil {
+			logger.FromContext(ctx).Error("Manually triggered job failed", zap.String("job", name), zap.Error(err))
+		}
+	}()
+	return nil
+}
+
+// run выполняет задачу под advisory-блокировкой, повторяя неудачные попытки с
+// экспоненциальной задержкой. Каждая попытка записывается в историю запусков.
+func (s *Scheduler) run(ctx context.Context, name string) error {
+	s.mu.Lock()
+	e := s.entries[name]
+	if e.running {
+		s.mu.Unlock()
+		return ErrJobBusy
+	}
+	e.running = true
+	job := e.job
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		e.running = false
+		s.mu.Unlock()
+	}()
+
+	unlock, ok, err := s.store.TryJobLock(ctx, job.Name)
+	if err != nil {
+		return fmt.Errorf("acquire lock: %w", err)
+	}
+	if !ok {
+		logger.FromContext(ctx).Debug("Job is running on another replica", zap.String("job", job.Name))
+		return ErrJobBusy
+	}
+	defer unlock()
+
+	log := logger.FromContext(ctx).With(zap.String("job", job.Name))
+	backoff := s.retryBackoff
+	for attempt := 1; ; attempt++ {
+		runID, err := s.store.StartJobRun(ctx, job.Name, attempt)
+		if err != nil {
+			return fmt.Errorf("record run: %w", err)
+		}
+
+		started := time.Now()
+		runErr := job.Run(ctx)
+		// Результат записывается и тогда, когда задачу прервала остановка
+		if err := s.store.FinishJobRun(context.WithoutCancel(ctx), runID, runErr); err != nil {
+			log.Error("Failed to record job result", zap.Error(err))
+		}
+
+		if runErr == nil {
+			log.Info("Job finished", zap.Int("attempt", attempt), zap.Duration("duration", time.Since(started)))
+			return nil
+		}
+		if attempt > job.MaxRetries || ctx.Err() != nil || s.isStopping() {
+			return runErr
+		}
+
+		log.Warn("Job attempt failed, retrying", zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(runErr))
+		select {
+		case <-ctx.Done():
+			return runErr
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// Jobs возвращает зарегистрированные задачи со временем следующего запуска и историей.
+func (s *Scheduler) Jobs(ctx context.Context, historyLimit int) ([]Info, error) {
+	s.mu.Lock()
+	infos := make([]Info, 0, len(s.order))
+	for _, name := range s.order {
+		e := s.entries[name]
+		next := e.nextRun
+		if next.IsZero() {
+			next = e.schedule.Next(time.Now())
+		}
+		infos = append(infos, Info{Name: name, Schedule: e.job.Schedule, NextRun: next, Running: e.running})
+	}
+	s.mu.Unlock()
+
+	for i := range infos {
+		runs, err := s.store.GetJobRuns(ctx, infos[i].Name, historyLimit)
+		if err != nil {
+			return nil, err
+		}
+		infos[i].LastRuns = runs
+	}
+	return infos, nil
+}
+
+// Runs возвращает последние запуски задачи.
+func (s *Scheduler) Runs(ctx context.Context, name string, limit int) ([]models.JobRun, error) {
+	s.mu.Lock()
+	_, ok := s.entries[name]
+	s.mu.Unlock()
+	if !ok {
+		return nil, ErrUnknownJob
+	}
+	return s.store.GetJobRuns(ctx, name, limit)
+}
@@ -0,0 +1,139 @@
+package jobs
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// memoryStore — хранилище без базы: блокировка всегда свободна, история не хранится.
+// closed отмечает, что база «закрыта» после остановки планировщика.
+type memoryStore struct {
+	closed     atomic.Bool
+	lateAccess atomic.Bool
+}
+
+func (s *memoryStore) touch() {
+	if s.closed.Load() {
+		s.lateAccess.Store(true)
+	}
+}
+
+func (s *memoryStore) TryJobLock(context.Context, string) (func(), bool, error) {
+	s.touch()
+	return func() {}, true, nil
+}
+
+func (s *memoryStore) StartJobRun(context.Context, string, int) (int, error) {
+	s.touch()
+	return 1, nil
+}
+
+func (s *memoryStore) FinishJobRun(context.Context, int, error) error {
+	s.touch()
+	return nil
+}
+
+func (s *memoryStore) GetJobRuns(context.Context, string, int) ([]models.JobRun, error) {
+	return nil, nil
+}
+
+func TestTrigger(t *testing.T) {
+	tests := []struct {
+		name    string
+		job     string
+		start   bool
+		stop    bool
+		busy    bool
+		wantErr error // nil — запуск принят; errAny — любая ошибка
+	}{
+		{"unknown job", "nope", true, false, false, ErrUnknownJob},
+		{"not started", "cleanup", false, false, false, errAny},
+		{"stopping", "cleanup", true, true, false, errAny},
+		{"already running", "cleanup", true, false, true, ErrJobBusy},
+		{"accepted", "cleanup", true, false, false, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			release := make(chan struct{})
+			s := NewScheduler(&memoryStore{})
+			s.Register(Job{Name: "cleanup", Schedule: "@yearly", Run: func(ctx context.Context) error {
+				<-release
+				return nil
+			}})
+			if tt.start {
+				s.Start(context.Background())
+			}
+			if tt.stop {
+				s.Stop(context.Background())
+			}
+			if tt.busy {
+				s.Trigger("cleanup")
+				waitRunning(t, s, "cleanup")
+			}
+
+			err := s.Trigger(tt.job)
+			close(release)
+			s.Stop(context.Background())
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Error("no error")
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// errAny в таблице означает, что подходит любая ошибка
+var errAny = errors.New("any error")
+
+func waitRunning(t *testing.T, s *Scheduler, name string) {
+	t.Helper()
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		s.mu.Lock()
+		running := s.entries[name].running
+		s.mu.Unlock()
+		if running {
+			return
+		}
+	}
+	t.Fatalf("job %s did not start", name)
+}
+
+// Запуск, принятый до Stop, завершается до возврата Stop; после Stop запуски не
+// принимаются и хранилище больше не используется.
+func TestTriggerDuringStop(t *testing.T) {
+	for i := 0; i < 50; i++ {
+		store := &memoryStore{}
+		s := NewScheduler(store)
+		s.Register(Job{Name: "cleanup", Schedule: "@yearly", Run: func(ctx context.Context) error {
+			time.Sleep(time.Millisecond)
+			return nil
+		}})
+		s.Start(context.Background())
+
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			for s.Trigger("cleanup") == nil || !s.isStopping() {
+			}
+		}()
+		if err := s.Stop(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+		store.closed.Store(true)
+		<-done
+		time.Sleep(2 * time.Millisecond)
+
+		if store.lateAccess.Load() {
+			t.Fatal("a triggered run used the store after Stop returned")
+		}
+	}
+}
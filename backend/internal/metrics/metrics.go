@@ -0,0 +1,172 @@
+package metrics
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace — префикс метрик приложения
+const namespace = "library"
+
+// Registry — реестр метрик процесса; отдается обработчиком Handler.
+var Registry = prometheus.NewRegistry()
+
+var (
+	httpRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status.",
+	}, []string{"method", "route", "status"})
+
+	httpDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	// LoansIssued считает выдачи, оформленные этим процессом
+	LoansIssued = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_issued_total",
+		Help:      "Loans issued.",
+	})
+
+	// LoansReturned считает возвраты, оформленные этим процессом, включая закрытие займа утерей или порчей
+	LoansReturned = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "loans_returned_total",
+		Help:      "Loans returned, including loans closed as lost or damaged.",
+	})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		httpRequests,
+		httpDuration,
+		LoansIssued,
+		LoansReturned,
+	)
+}
+
+// Handler отдает метрики в формате Prometheus.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// Middleware считает запросы и их длительность по шаблону маршрута gin, а не по пути,
+// чтобы идентификаторы в пути не плодили серии. Запросы мимо маршрутов идут в "unmatched".
+func Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		labels := prometheus.Labels{
+			"method": c.Request.Method,
+			"route":  route,
+			"status": strconv.Itoa(c.Writer.Status()),
+		}
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	}
+}
+
+// StatsGetter — источник состояния пула соединений (sql.DB.Stats).
+type StatsGetter interface {
+	Stats() sql.DBStats
+}
+
+var (
+	dbMaxOpenDesc      = prometheus.NewDesc(namespace+"_db_max_open_connections", "Maximum number of open connections to the database.", nil, nil)
+	dbOpenDesc         = prometheus.NewDesc(namespace+"_db_open_connections", "Established connections, both in use and idle.", nil, nil)
+	dbInUseDesc        = prometheus.NewDesc(namespace+"_db_in_use_connections", "Connections currently in use.", nil, nil)
+	dbIdleDesc         = prometheus.NewDesc(namespace+"_db_idle_connections", "Idle connections.", nil, nil)
+	dbWaitCountDesc    = prometheus.NewDesc(namespace+"_db_wait_count_total", "Connections waited for.", nil, nil)
+	dbWaitDurationDesc = prometheus.NewDesc(namespace+"_db_wait_duration_seconds_total", "Time blocked waiting for a new connection.", nil, nil)
+)
+
+// dbCollector снимает состояние пула соединений при каждом сборе метрик
+type dbCollector struct {
+	db StatsGetter
+}
+
+// RegisterDB добавляет метрики пула соединений базы.
+func RegisterDB(db StatsGetter) {
+	Registry.MustRegister(dbCollector{db: db})
+}
+
+func (c dbCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- dbMaxOpenDesc
+	ch <- dbOpenDesc
+	ch <- dbInUseDesc
+	ch <- dbIdleDesc
+	ch <- dbWaitCountDesc
+	ch <- dbWaitDurationDesc
+}
+
+func (c dbCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.db.Stats()
+	ch <- prometheus.MustNewConstMetric(dbMaxOpenDesc, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbOpenDesc, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(dbInUseDesc, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(dbIdleDesc, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(dbWaitCountDesc, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(dbWaitDurationDesc, prometheus.CounterValue, stats.WaitDuration.Seconds())
+}
+
+// CirculationSource — текущее состояние фонда, которое считается запросом к базе.
+type CirculationSource interface {
+	GetCirculationCounts(ctx context.Context) (models.CirculationCounts, error)
+}
+
+// collectTimeout ограничивает запрос счетчиков, чтобы медленная база не задерживала сбор метрик
+const collectTimeout = 5 * time.Second
+
+var (
+	overdueLoansDesc = prometheus.NewDesc(namespace+"_loans_overdue", "Open loans past their due date.", nil, nil)
+	holdsWaitingDesc = prometheus.NewDesc(namespace+"_holds_waiting", "Holds waiting for a copy.", nil, nil)
+)
+
+// circulationCollector запрашивает счетчики при каждом сборе метрик, так что значения
+// одинаковы на всех репликах и не зависят от того, какая из них оформляла выдачи.
+type circulationCollector struct {
+	source CirculationSource
+}
+
+// RegisterCirculation добавляет метрики просроченных выдач и ожидающих броней.
+func RegisterCirculation(source CirculationSource) {
+	Registry.MustRegister(circulationCollector{source: source})
+}
+
+func (c circulationCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- overdueLoansDesc
+	ch <- holdsWaitingDesc
+}
+
+func (c circulationCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), collectTimeout)
+	defer cancel()
+	counts, err := c.source.GetCirculationCounts(ctx)
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(overdueLoansDesc, err)
+		ch <- prometheus.NewInvalidMetric(holdsWaitingDesc, err)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(overdueLoansDesc, prometheus.GaugeValue, float64(counts.OverdueLoans))
+	ch <- prometheus.MustNewConstMetric(holdsWaitingDesc, prometheus.GaugeValue, float64(counts.HoldsWaiting))
+}
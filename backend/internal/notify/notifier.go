@@ -0,0 +1,144 @@
+package notify
+
+import (
+	"cmd/main.go/models"
+	"cmd/main.go/pkg/logger"
+	"context"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+)
+
+// ChannelEmail — имя канала электронной почты
+const ChannelEmail = "email"
+
+// Message — готовое к отправке сообщение.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Channel доставляет сообщения читателям (почта, SMS и т.п.).
+type Channel interface {
+	Name() string
+	Send(ctx context.Context, msg Message) error
+}
+
+// Store — очередь уведомлений в базе данных.
+type Store interface {
+	GetPendingNotices(ctx context.Context, channel string) ([]models.Notice, error)
+	EnqueueNotification(ctx context.Context, n models.Notification) (bool, error)
+	GetOutgoingNotifications(ctx context.Context, channel string, limit int) ([]models.Notification, error)
+	MarkNotificationSent(ctx context.Context, id int) error
+	MarkNotificationFailed(ctx context.Context, id int, sendErr error, maxAttempts int) error
+}
+
+const (
+	// maxAttempts — сколько раз пытаться отправить сообщение, прежде чем пометить его failed
+	maxAttempts = 5
+	// dispatchBatch — сколько сообщений отправлять за один проход
+	dispatchBatch = 100
+)
+
+// Notifier ставит уведомления в очередь и рассылает их по каналам. Очередь хранится в
+// базе, поэтому неотправленные сообщения переживают перезапуск сервера.
+type Notifier struct {
+	store    Store
+	renderer atomic.Pointer[Renderer]
+	channels []Channel
+}
+
+func NewNotifier(store Store, renderer *Renderer, channels ...Channel) *Notifier {
+	n := &Notifier{store: store, channels: channels}
+	n.renderer.Store(renderer)
+	return n
+}
+
+// SetRenderer подменяет шаблоны писем; уже поставленные в очередь письма не меняются.
+func (n *Notifier) SetRenderer(renderer *Renderer) {
+	n.renderer.Store(renderer)
+}
+
+// Queue находит новые события и кладет письма о них в очередь каждого канала.
+// Возвращает число новых сообщений.
+func (n *Notifier) Queue(ctx context.Context) (int, error) {
+	queued := 0
+	renderer := n.renderer.Load()
+	for _, ch := range n.channels {
+		notices, err := n.store.GetPendingNotices(ctx, ch.Name())
+		if err != nil {
+			return queued, err
+		}
+
+		for _, notice := range notices {
+			msg, err := renderer.Render(notice)
+			if err != nil {
+				return queued, err
+			}
+
+			added, err := n.store.EnqueueNotification(ctx, models.Notification{
+				UserID:    notice.UserID,
+				Channel:   ch.Name(),
+				Kind:      notice.Kind,
+				DedupKey:  notice.DedupKey,
+				Recipient: msg.To,
+				Subject:   msg.Subject,
+				Text:      msg.Text,
+				HTML:      msg.HTML,
+			})
+			if err != nil {
+				return queued, err
+			}
+			if added {
+				queued++
+			}
+		}
+	}
+	return queued, nil
+}
+
+// Dispatch отправляет накопившиеся сообщения. Ошибка отправки одного сообщения не
+// останавливает рассылку: оно останется в очереди до следующего прохода.
+func (n *Notifier) Dispatch(ctx context.Context) (int, error) {
+	sent := 0
+	for _, ch := range n.channels {
+		pending, err := n.store.GetOutgoingNotifications(ctx, ch.Name(), dispatchBatch)
+		if err != nil {
+			return sent, err
+		}
+
+		for _, notification := range pending {
+			if ctx.Err() != nil {
+				return sent, ctx.Err()
+			}
+
+			err := ch.Send(ctx, Message{
+				To:      notification.Recipient,
+				Subject: notification.Subject,
+				Text:    notification.Text,
+				HTML:    notification.HTML,
+			})
+			if err != nil && ctx.Err() != nil {
+				// Отправку прервала остановка, это не неудачная попытка: сообщение уйдет в следующий проход
+				return sent, ctx.Err()
+			}
+			if err != nil {
+				logger.FromContext(ctx).Warn("Failed to send notification",
+					zap.Int("id", notification.ID), zap.String("channel", ch.Name()), zap.Error(err))
+				if err := n.store.MarkNotificationFailed(context.WithoutCancel(ctx), notification.ID, err, maxAttempts); err != nil {
+					return sent, err
+				}
+				continue
+			}
+
+			// Отметка не отменяется вместе с ctx: иначе при остановке письмо уйдет повторно
+			if err := n.store.MarkNotificationSent(context.WithoutCancel(ctx), notification.ID); err != nil {
+				return sent, err
+			}
+			sent++
+		}
+	}
+	return sent, nil
+}
@@ -0,0 +1,152 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"time"
+)
+
+// smtpTimeout ограничивает соединение с почтовым сервером и весь обмен с ним
+const smtpTimeout = 30 * time.Second
+
+// SMTPConfig — параметры почтового сервера. Пустые User и Password означают отправку
+// без авторизации (например, в локальный mailpit).
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	User     string
+	Password string
+	From     string
+}
+
+// SMTPChannel отправляет уведомления письмами через SMTP.
+type SMTPChannel struct {
+	config SMTPConfig
+}
+
+func NewSMTPChannel(config SMTPConfig) *SMTPChannel {
+	return &SMTPChannel{config: config}
+}
+
+func (c *SMTPChannel) Name() string {
+	return ChannelEmail
+}
+
+// Send отправляет письмо из двух частей: текстовой и HTML. Отмена ctx прерывает обмен
+// с сервером, так что после возврата письмо уже не уйдет; письмо, принятое сервером,
+// считается отправленным, даже если завершить сеанс не удалось.
+func (c *SMTPChannel) Send(ctx context.Context, msg Message) error {
+	body, err := c.compose(msg)
+	if err != nil {
+		return err
+	}
+
+	conn, err := (&net.Dialer{Timeout: smtpTimeout}).DialContext(ctx, "tcp", net.JoinHostPort(c.config.Host, c.config.Port))
+	if err != nil {
+		return err
+	}
+	deadline := time.Now().Add(smtpTimeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetDeadline(deadline)
+	stop := context.AfterFunc(ctx, func() { conn.SetDeadline(time.Now()) })
+	defer stop()
+
+	client, err := smtp.NewClient(conn, c.config.Host)
+	if err != nil {
+		conn.Close()
+		return contextError(ctx, err)
+	}
+	defer client.Close()
+
+	if err := c.deliver(client, msg.To, body); err != nil {
+		return contextError(ctx, err)
+	}
+	// Письмо уже принято сервером; ошибка QUIT на доставку не влияет
+	client.Quit()
+	return nil
+}
+
+// deliver повторяет smtp.SendMail на уже открытом соединении
+func (c *SMTPChannel) deliver(client *smtp.Client, to string, body []byte) error {
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: c.config.Host}); err != nil {
+			return err
+		}
+	}
+	if c.config.User != "" {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return errors.New("smtp: server doesn't support AUTH")
+		}
+		if err := client.Auth(smtp.PlainAuth("", c.config.User, c.config.Password, c.config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := client.Mail(c.config.From); err != nil {
+		return err
+	}
+	if err := client.Rcpt(to); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	return w.Close()
+}
+
+// contextError сообщает об отмене ctx вместо ошибки соединения, которую она вызвала
+func contextError(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return err
+}
+
+func (c *SMTPChannel) compose(msg Message) ([]byte, error) {
+	var buf bytes.Buffer
+	parts := multipart.NewWriter(&buf)
+
+	fmt.Fprintf(&buf, "From: %s\r\n", c.config.From)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&buf, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", parts.Boundary())
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		if part.body == "" {
+			continue
+		}
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.body)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := parts.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,178 @@
+package notify
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeSMTP — минимальный SMTP-сервер: отвечает по сценарию и запоминает принятые письма.
+// rcptCode — ответ на RCPT, stall — на какой команде (или greeting) перестать отвечать.
+type fakeSMTP struct {
+	rcptCode string
+	stall    string
+
+	mu        sync.Mutex
+	delivered int
+}
+
+func (s *fakeSMTP) start(t *testing.T) SMTPConfig {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	return SMTPConfig{Host: host, Port: port, From: "library@localhost"}
+}
+
+func (s *fakeSMTP) serve(conn net.Conn) {
+	defer conn.Close()
+	tp := textproto.NewConn(conn)
+	if s.stall == "greeting" {
+		io.Copy(io.Discard, conn)
+		return
+	}
+	tp.PrintfLine("220 fake ESMTP")
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.Fields(line + " x")[0])
+		if cmd == s.stall {
+			// Молчим, пока клиент не закроет соединение
+			io.Copy(io.Discard, conn)
+			return
+		}
+		switch cmd {
+		case "EHLO", "HELO":
+			tp.PrintfLine("250 fake")
+		case "RCPT":
+			code := s.rcptCode
+			if code == "" {
+				code = "250"
+			}
+			tp.PrintfLine("%s rcpt", code)
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			if _, err := tp.ReadDotBytes(); err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.delivered++
+			s.mu.Unlock()
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("250 ok")
+		}
+	}
+}
+
+func (s *fakeSMTP) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.delivered
+}
+
+// errAny в таблице означает, что подходит любая ошибка
+var errAny = errors.New("any error")
+
+func TestSMTPSend(t *testing.T) {
+	tests := []struct {
+		name          string
+		server        *fakeSMTP
+		cancelAfter   time.Duration // 0 — не отменять
+		wantErr       error         // nil — без ошибки; errAny — любая ошибка
+		wantDelivered int
+	}{
+		{"delivered", &fakeSMTP{}, 0, nil, 1},
+		{"recipient rejected", &fakeSMTP{rcptCode: "550"}, 0, errAny, 0},
+		{"cancelled waiting for greeting", &fakeSMTP{stall: "greeting"}, 50 * time.Millisecond, context.Canceled, 0},
+		{"cancelled before data", &fakeSMTP{stall: "DATA"}, 50 * time.Millisecond, context.Canceled, 0},
+		{"quit fails after delivery", &fakeSMTP{stall: "QUIT"}, 50 * time.Millisecond, nil, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			channel := NewSMTPChannel(tt.server.start(t))
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tt.cancelAfter > 0 {
+				time.AfterFunc(tt.cancelAfter, cancel)
+			}
+
+			started := time.Now()
+			err := channel.Send(ctx, Message{To: "reader@example.com", Subject: "Hold ready", Text: "Your hold is ready"})
+			if time.Since(started) > 5*time.Second {
+				t.Fatalf("Send took %s", time.Since(started))
+			}
+			switch {
+			case tt.wantErr == errAny:
+				if err == nil {
+					t.Error("no error")
+				}
+			case !errors.Is(err, tt.wantErr):
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := tt.server.count(); got != tt.wantDelivered {
+				t.Errorf("delivered %d, want %d", got, tt.wantDelivered)
+			}
+		})
+	}
+}
+
+// recordingStore — очередь из одного сообщения, запоминающая отметки
+type recordingStore struct {
+	Store
+	sent, failed int
+}
+
+func (s *recordingStore) GetOutgoingNotifications(context.Context, string, int) ([]models.Notification, error) {
+	return []models.Notification{{ID: 1, Recipient: "reader@example.com"}}, nil
+}
+
+func (s *recordingStore) MarkNotificationSent(context.Context, int) error {
+	s.sent++
+	return nil
+}
+
+func (s *recordingStore) MarkNotificationFailed(context.Context, int, error, int) error {
+	s.failed++
+	return nil
+}
+
+func TestDispatchInterruptedSend(t *testing.T) {
+	server := &fakeSMTP{stall: "DATA"}
+	store := &recordingStore{}
+	n := NewNotifier(store, nil, NewSMTPChannel(server.start(t)))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+	if _, err := n.Dispatch(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want %v", err, context.Canceled)
+	}
+	if store.failed != 0 || store.sent != 0 {
+		t.Errorf("marked %d failed and %d sent, want the message left pending", store.failed, store.sent)
+	}
+}
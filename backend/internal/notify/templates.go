@@ -0,0 +1,93 @@
+package notify
+
+import (
+	"bytes"
+	"cmd/main.go/models"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/fs"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+// dateFormats — формат даты в письмах для каждого языка
+var dateFormats = map[string]string{
+	models.LanguageRussian: "02.01.2006",
+	models.LanguageEnglish: "January 2, 2006",
+}
+
+type messageTemplates struct {
+	text *texttemplate.Template // блоки subject и text
+	html *htmltemplate.Template // блок html
+}
+
+// Renderer собирает текст писем из шаблонов templates/<язык>/<вид>.{txt,html}.
+type Renderer struct {
+	templates map[string]messageTemplates // ключ: язык + "/" + вид
+}
+
+// NewRenderer разбирает шаблоны для всех языков и видов уведомлений: встроенные, если dir
+// пуст, иначе из каталога dir с той же раскладкой <язык>/<вид>.{txt,html}.
+func NewRenderer(dir string) (*Renderer, error) {
+	var files fs.FS = os.DirFS(dir)
+	if dir == "" {
+		files, _ = fs.Sub(templateFS, "templates")
+	}
+	r := &Renderer{templates: make(map[string]messageTemplates)}
+
+	for lang, layout := range dateFormats {
+		layout := layout
+		funcs := map[string]any{"date": func(t time.Time) string { return t.Format(layout) }}
+
+		for _, kind := range []string{models.NotifyOverdue, models.NotifyHoldReady} {
+			base := lang + "/" + kind
+
+			text, err := texttemplate.New(kind).Funcs(funcs).ParseFS(files, base+".txt")
+			if err != nil {
+				return nil, fmt.Errorf("parse %s.txt: %w", base, err)
+			}
+			html, err := htmltemplate.New(kind).Funcs(funcs).ParseFS(files, base+".html")
+			if err != nil {
+				return nil, fmt.Errorf("parse %s.html: %w", base, err)
+			}
+			r.templates[lang+"/"+kind] = messageTemplates{text: text, html: html}
+		}
+	}
+
+	return r, nil
+}
+
+// Render формирует письмо по событию на языке читателя; неизвестный язык заменяется русским.
+func (r *Renderer) Render(notice models.Notice) (Message, error) {
+	t, ok := r.templates[notice.Language+"/"+notice.Kind]
+	if !ok {
+		t, ok = r.templates[models.LanguageRussian+"/"+notice.Kind]
+	}
+	if !ok {
+		return Message{}, fmt.Errorf("no template for %q", notice.Kind)
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := t.text.ExecuteTemplate(&subject, "subject", notice); err != nil {
+		return Message{}, err
+	}
+	if err := t.text.ExecuteTemplate(&text, "text", notice); err != nil {
+		return Message{}, err
+	}
+	if err := t.html.ExecuteTemplate(&html, "html", notice); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      notice.Email,
+		Subject: strings.TrimSpace(subject.String()),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
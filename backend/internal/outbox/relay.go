@@ -0,0 +1,221 @@
+package outbox
+
+import (
+	"cmd/main.go/models"
+	"cmd/main.go/pkg/logger"
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Handler обрабатывает одно событие. Ошибка означает, что событие будет доставлено
+// снова, поэтому обработчики должны быть идемпотентными.
+type Handler func(ctx context.Context, event models.OutboxEvent) error
+
+// Store — outbox и смещения подписчиков в базе данных.
+type Store interface {
+	TryJobLock(ctx context.Context, job string) (unlock func(), ok bool, err error)
+	GetOutboxEvents(ctx context.Context, after models.OutboxPosition, limit int) ([]models.OutboxEvent, error)
+	GetOutboxHead(ctx context.Context) (models.OutboxPosition, error)
+	GetConsumerOffset(ctx context.Context, consumer string) (models.OutboxPosition, error)
+	SetConsumerOffset(ctx context.Context, consumer string, pos models.OutboxPosition) error
+}
+
+const (
+	// pollInterval — как часто проверять outbox на новые события
+	pollInterval = time.Second
+	// retryInterval — пауза после ошибки обработчика или базы
+	retryInterval = 5 * time.Second
+	batchSize     = 100
+)
+
+type subscriber struct {
+	name    string
+	handler Handler
+	// local — подписчик работает на каждой реплике и хранит смещение в памяти
+	local bool
+}
+
+// Relay доставляет события outbox подписчикам внутри процесса: каждому в порядке
+// транзакций, записавших события, и как минимум один раз. Смещение подписчика хранится в базе и сдвигается после
+// успешной обработки каждого события; при нескольких репликах подписчик работает
+// только на одной из них (advisory-блокировка на его имя).
+type Relay struct {
+	store       Store
+	subscribers []subscriber
+	cancel      context.CancelFunc
+	stopping    chan struct{}
+	stopOnce    sync.Once
+	wg          sync.WaitGroup
+}
+
+func NewRelay(store Store) *Relay {
+	return &Relay{store: store, stopping: make(chan struct{})}
+}
+
+// Subscribe регистрирует подписчика; вызывается до Start. Имя определяет смещение,
+// поэтому должно быть постоянным между запусками.
+func (r *Relay) Subscribe(name string, handler Handler) {
+	r.subscribers = append(r.subscribers, subscriber{name: name, handler: handler})
+}
+
+// SubscribeLocal регистрирует подписчика, который получает события на каждой реплике,
+// начиная с момента запуска (например, для рассылки подключенным к этой реплике клиентам).
+// Смещение хранится в памяти и после перезапуска не восстанавливается.
+func (r *Relay) SubscribeLocal(name string, handler Handler) {
+	r.subscribers = append(r.subscribers, subscriber{name: name, handler: handler, local: true})
+}
+
+// Start запускает по горутине на подписчика; они останавливаются при отмене ctx или
+// вызове Stop.
+func (r *Relay) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+	for _, sub := range r.subscribers {
+		r.wg.Add(1)
+		go r.run(ctx, sub)
+	}
+}
+
+// Wait дожидается остановки всех подписчиков.
+func (r *Relay) Wait() {
+	r.wg.Wait()
+}
+
+// Stop останавливает подписчиков. Подписчики со смещением в базе сначала дочитывают
+// outbox до конца, чтобы записанные до остановки события не ждали следующего запуска.
+// Если ctx истекает раньше, обработка прерывается, и Stop после остановки подписчиков
+// возвращает ошибку ctx.
+func (r *Relay) Stop(ctx context.Context) error {
+	r.stopOnce.Do(func() { close(r.stopping) })
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		if r.cancel != nil {
+			r.cancel()
+		}
+		<-done
+		return ctx.Err()
+	}
+}
+
+func (r *Relay) run(ctx context.Context, sub subscriber) {
+	defer r.wg.Done()
+	log := logger.FromContext(ctx).With(zap.String("consumer", sub.name))
+
+	var offset *models.OutboxPosition
+	for {
+		wait := pollInterval
+		var err error
+		if sub.local {
+			offset, err = r.consumeLocal(ctx, sub, offset)
+		} else {
+			err = r.lead(ctx, sub)
+		}
+		if err != nil {
+			log.Error("Outbox consumer failed", zap.Error(err))
+			wait = retryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.stopping:
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// lead берет блокировку подписчика и обрабатывает события, пока не кончится ctx или
+// не случится ошибка. Если подписчик уже работает на другой реплике, сразу возвращается.
+func (r *Relay) lead(ctx context.Context, sub subscriber) error {
+	unlock, ok, err := r.store.TryJobLock(ctx, "outbox:"+sub.name)
+	if err != nil || !ok {
+		return err
+	}
+	defer unlock()
+
+	offset, err := r.store.GetConsumerOffset(ctx, sub.name)
+	if err != nil {
+		return err
+	}
+
+	flushing := false
+	for {
+		var full bool
+		offset, full, err = r.consume(ctx, sub, offset, func(pos models.OutboxPosition) error {
+			// Обработанное событие фиксируется и при остановке, чтобы не повторять его
+			return r.store.SetConsumerOffset(context.WithoutCancel(ctx), sub.name, pos)
+		})
+		if err != nil {
+			return err
+		}
+
+		// Полная пачка — вероятно, есть еще события, читаем сразу
+		if full {
+			continue
+		}
+		if flushing {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-r.stopping:
+			// Еще один проход, чтобы забрать события, появившиеся после последнего опроса
+			flushing = true
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// consumeLocal обрабатывает события локального подписчика. Смещение nil означает первый
+// запуск: подписчик начинает с текущего конца outbox.
+func (r *Relay) consumeLocal(ctx context.Context, sub subscriber, offset *models.OutboxPosition) (*models.OutboxPosition, error) {
+	if offset == nil {
+		head, err := r.store.GetOutboxHead(ctx)
+		if err != nil {
+			return nil, err
+		}
+		return &head, nil
+	}
+	for {
+		next, full, err := r.consume(ctx, sub, *offset, func(models.OutboxPosition) error { return nil })
+		offset = &next
+		if err != nil || !full {
+			return offset, err
+		}
+	}
+}
+
+// consume передает подписчику пачку событий после offset, вызывая commit после каждого.
+// Возвращает новое смещение и признак того, что пачка была полной.
+func (r *Relay) consume(ctx context.Context, sub subscriber, offset models.OutboxPosition, commit func(models.OutboxPosition) error) (models.OutboxPosition, bool, error) {
+	events, err := r.store.GetOutboxEvents(ctx, offset, batchSize)
+	if err != nil {
+		return offset, false, err
+	}
+
+	for _, event := range events {
+		if err := sub.handler(ctx, event); err != nil {
+			return offset, false, fmt.Errorf("event %d (%s): %w", event.ID, event.Type, err)
+		}
+		if err := commit(event.Position()); err != nil {
+			return offset, false, err
+		}
+		offset = event.Position()
+	}
+
+	return offset, len(events) == batchSize, nil
+}
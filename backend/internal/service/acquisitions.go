@@ -0,0 +1,80 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+func (s service) GetVendors(ctx context.Context) ([]models.Vendor, error) {
+	return s.db.GetVendors(ctx)
+}
+
+func (s service) AddVendor(ctx context.Context, vendor models.Vendor) (models.Vendor, error) {
+	if vendor.Name == "" {
+		return models.Vendor{}, fmt.Errorf("%w: name is required", ErrValidation)
+	}
+	return s.db.AddVendor(ctx, vendor)
+}
+
+func (s service) GetFunds(ctx context.Context, year int) ([]models.Fund, error) {
+	return s.db.GetFunds(ctx, year)
+}
+
+func (s service) AddFund(ctx context.Context, fund models.Fund) (models.Fund, error) {
+	if fund.Name == "" || fund.Year <= 0 {
+		return models.Fund{}, fmt.Errorf("%w: name and year are required", ErrValidation)
+	}
+	if fund.Budget < 0 {
+		return models.Fund{}, fmt.Errorf("%w: budget must not be negative", ErrValidation)
+	}
+	return s.db.AddFund(ctx, fund)
+}
+
+func (s service) GetFundReports(ctx context.Context, year int) ([]models.FundReport, error) {
+	return s.db.GetFundReports(ctx, year)
+}
+
+func (s service) GetOrders(ctx context.Context, status string) ([]models.PurchaseOrder, error) {
+	return s.db.GetOrders(ctx, status)
+}
+
+func (s service) GetOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	return s.db.GetOrder(ctx, id)
+}
+
+// CreateOrder создает черновик заказа; каждая строка должна иметь название, количество и цену
+func (s service) CreateOrder(ctx context.Context, order models.PurchaseOrder) (models.PurchaseOrder, error) {
+	if len(order.Lines) == 0 {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: order has no lines", ErrValidation)
+	}
+	for i, line := range order.Lines {
+		if line.Title == "" || line.Quantity <= 0 || line.UnitPrice < 0 {
+			return models.PurchaseOrder{}, fmt.Errorf("%w: line %d needs a title, positive quantity and price", ErrValidation, i+1)
+		}
+	}
+	return s.db.CreateOrder(ctx, order)
+}
+
+func (s service) PlaceOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	if err := s.db.SetOrderStatus(ctx, id, models.OrderPlaced, models.OrderDraft); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.db.GetOrder(ctx, id)
+}
+
+// CancelOrder отменяет заказ; уже полученные экземпляры остаются в фонде и учитываются в расходах
+func (s service) CancelOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	err := s.db.SetOrderStatus(ctx, id, models.OrderCancelled, models.OrderDraft, models.OrderPlaced, models.OrderPartiallyReceived)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	return s.db.GetOrder(ctx, id)
+}
+
+func (s service) ReceiveOrder(ctx context.Context, id, branchID int, lines []models.ReceiveLine) (models.PurchaseOrder, error) {
+	if branchID == 0 || len(lines) == 0 {
+		return models.PurchaseOrder{}, fmt.Errorf("%w: branchID and lines are required", ErrValidation)
+	}
+	return s.db.ReceiveOrder(ctx, id, branchID, lines)
+}
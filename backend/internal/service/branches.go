@@ -0,0 +1,50 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+)
+
+func (s service) GetBranches(ctx context.Context) ([]models.Branch, error) {
+	return s.db.GetBranches(ctx)
+}
+
+func (s service) AddBranch(ctx context.Context, branch models.Branch) (models.Branch, error) {
+	return s.db.AddBranch(ctx, branch)
+}
+
+func (s service) GetCopies(ctx context.Context, bookID, branchID int) ([]models.Copy, error) {
+	return s.db.GetCopies(ctx, bookID, branchID)
+}
+
+func (s service) AddCopy(ctx context.Context, c models.Copy) (models.Copy, error) {
+	return s.db.AddCopy(ctx, c)
+}
+
+func (s service) GetHolds(ctx context.Context, branchID int) ([]models.Hold, error) {
+	return s.db.GetHolds(ctx, branchID)
+}
+
+func (s service) PlaceHold(ctx context.Context, hold models.Hold) (models.Hold, error) {
+	return s.db.PlaceHold(ctx, hold)
+}
+
+func (s service) CancelHold(ctx context.Context, holdID int) error {
+	return s.db.CancelHold(ctx, holdID)
+}
+
+func (s service) GetTransfers(ctx context.Context, branchID int) ([]models.Transfer, error) {
+	return s.db.GetTransfers(ctx, branchID)
+}
+
+func (s service) RequestTransfer(ctx context.Context, copyID, toBranchID int) (models.Transfer, error) {
+	return s.db.RequestTransfer(ctx, copyID, toBranchID)
+}
+
+func (s service) ShipTransfer(ctx context.Context, transferID int) error {
+	return s.db.ShipTransfer(ctx, transferID)
+}
+
+func (s service) ReceiveTransfer(ctx context.Context, transferID int) error {
+	return s.db.ReceiveTransfer(ctx, transferID)
+}
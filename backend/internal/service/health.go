@@ -0,0 +1,24 @@
+package service
+
+import (
+	"cmd/main.go/internal/storage"
+	"context"
+	"fmt"
+)
+
+// CheckDatabase проверяет, что база отвечает и ее схема не старше той, с которой работает
+// код. Более новая схема совместима (см. storage.SchemaVersion), так что при поэтапном
+// обновлении реплики предыдущей версии остаются готовыми.
+func (s service) CheckDatabase(ctx context.Context) error {
+	if err := s.db.Ping(ctx); err != nil {
+		return err
+	}
+	version, err := s.db.GetSchemaVersion(ctx)
+	if err != nil {
+		return err
+	}
+	if version < storage.SchemaVersion() {
+		return fmt.Errorf("schema version %d, expected at least %d", version, storage.SchemaVersion())
+	}
+	return nil
+}
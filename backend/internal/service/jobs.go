@@ -0,0 +1,60 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+// statisticsMaxAge — сколько снимок статистики считается свежим; задача обновления
+// запускается чаще, так что при работающем планировщике дашборд не считает статистику заново
+const statisticsMaxAge = 10 * time.Minute
+
+// GetStatistics отдает свежий снимок статистики, а если его нет — считает ее по базе
+func (s service) GetStatistics(ctx context.Context) (models.Statistics, error) {
+	stats, ok, err := s.db.GetStatisticsSnapshot(ctx, statisticsMaxAge)
+	if err == nil && ok {
+		return stats, nil
+	}
+
+	live, err := s.db.GetStats(ctx)
+	if err != nil {
+		return models.Statistics{}, err
+	}
+	return *live, nil
+}
+
+// CountStatistics считает статистику по базе, минуя снимок, — для живого дашборда
+func (s service) CountStatistics(ctx context.Context) (models.Statistics, error) {
+	live, err := s.db.GetStats(ctx)
+	if err != nil {
+		return models.Statistics{}, err
+	}
+	return *live, nil
+}
+
+func (s service) MarkOverdueLoans(ctx context.Context) (int, error) {
+	return s.db.MarkOverdueLoans(ctx)
+}
+
+func (s service) ExpireHolds(ctx context.Context, pickupDays int) (int, error) {
+	if pickupDays <= 0 {
+		return 0, fmt.Errorf("%w: pickup days must be positive", ErrValidation)
+	}
+	return s.db.ExpireHolds(ctx, pickupDays)
+}
+
+// RefreshStatistics пересчитывает статистику и сохраняет снимок
+func (s service) RefreshStatistics(ctx context.Context) error {
+	stats, err := s.db.GetStats(ctx)
+	if err != nil {
+		return err
+	}
+	return s.db.SaveStatisticsSnapshot(ctx, *stats)
+}
+
+// GetCirculationCounts отдает число просроченных выдач и ожидающих броней для метрик
+func (s service) GetCirculationCounts(ctx context.Context) (models.CirculationCounts, error) {
+	return s.db.GetCirculationCounts(ctx)
+}
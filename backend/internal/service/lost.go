@@ -0,0 +1,43 @@
+package service
+
+import (
+	"cmd/main.go/internal/metrics"
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+func (s service) DeclareLost(ctx context.Context, loanID int) (models.Fine, error) {
+	return s.declareLoanCopy(ctx, loanID, models.LoanLost)
+}
+
+func (s service) DeclareDamaged(ctx context.Context, loanID int) (models.Fine, error) {
+	return s.declareLoanCopy(ctx, loanID, models.LoanDamaged)
+}
+
+// declareLoanCopy закрывает займ утерей или порчей; для метрик это такой же возврат
+func (s service) declareLoanCopy(ctx context.Context, loanID int, outcome string) (models.Fine, error) {
+	fine, err := s.db.DeclareLoanCopy(ctx, loanID, outcome)
+	if err == nil {
+		metrics.LoansReturned.Inc()
+	}
+	return fine, err
+}
+
+func (s service) MarkFound(ctx context.Context, loanID int) (models.Fine, error) {
+	return s.db.MarkLoanFound(ctx, loanID)
+}
+
+func (s service) GetReplacementCosts(ctx context.Context) ([]models.ReplacementCost, error) {
+	return s.db.GetReplacementCosts(ctx)
+}
+
+func (s service) SetReplacementCost(ctx context.Context, cost models.ReplacementCost) error {
+	if cost.Category == "" {
+		return fmt.Errorf("%w: category is required", ErrValidation)
+	}
+	if cost.Amount < 0 {
+		return fmt.Errorf("%w: amount must not be negative", ErrValidation)
+	}
+	return s.db.SetReplacementCost(ctx, cost)
+}
@@ -0,0 +1,36 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+// notificationsLimit — сколько последних сообщений отдавать в журнале уведомлений
+const notificationsLimit = 200
+
+func (s service) GetNotificationPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error) {
+	return s.db.GetNotificationPreferences(ctx, userID)
+}
+
+func (s service) SetNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) (models.NotificationPreferences, error) {
+	if prefs.Language == "" {
+		prefs.Language = models.LanguageRussian
+	}
+	if prefs.Language != models.LanguageRussian && prefs.Language != models.LanguageEnglish {
+		return models.NotificationPreferences{}, fmt.Errorf("%w: unsupported language %q", ErrValidation, prefs.Language)
+	}
+	if err := s.db.SetNotificationPreferences(ctx, prefs); err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	return prefs, nil
+}
+
+func (s service) GetNotifications(ctx context.Context, status string, userID int) ([]models.Notification, error) {
+	switch status {
+	case "", models.NotificationPending, models.NotificationSent, models.NotificationFailed:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrValidation, status)
+	}
+	return s.db.GetNotifications(ctx, status, userID, notificationsLimit)
+}
@@ -0,0 +1,18 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"time"
+)
+
+// outboxRetention — сколько хранить события, уже обработанные всеми подписчиками
+const outboxRetention = 7 * 24 * time.Hour
+
+func (s service) GetOutboxConsumers(ctx context.Context) ([]models.ConsumerOffset, error) {
+	return s.db.GetConsumerOffsets(ctx)
+}
+
+func (s service) PruneOutbox(ctx context.Context) (int, error) {
+	return s.db.PruneOutbox(ctx, outboxRetention)
+}
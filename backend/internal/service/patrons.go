@@ -0,0 +1,92 @@
+package service
+
+import (
+	"cmd/main.go/internal/storage"
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+func validCategory(category string) bool {
+	switch category {
+	case models.CategoryAdult, models.CategoryChild, models.CategoryStudent, models.CategoryStaff:
+		return true
+	}
+	return false
+}
+
+func (s service) GetUser(ctx context.Context, id int) (models.User, error) {
+	return s.db.GetUser(ctx, id)
+}
+
+func (s service) GetUserByCard(ctx context.Context, cardNumber string) (models.User, error) {
+	return s.db.GetUserByCard(ctx, cardNumber)
+}
+
+func (s service) RenewUser(ctx context.Context, id int) (models.User, error) {
+	return s.db.RenewUser(ctx, id)
+}
+
+// BlockUser блокирует или приостанавливает читателя; причина обязательна
+func (s service) BlockUser(ctx context.Context, id int, status, reason string) (models.User, error) {
+	if status != models.UserBlocked && status != models.UserSuspended {
+		return models.User{}, fmt.Errorf("%w: status must be %q or %q", ErrValidation, models.UserBlocked, models.UserSuspended)
+	}
+	if reason == "" {
+		return models.User{}, fmt.Errorf("%w: reason is required", ErrValidation)
+	}
+	return s.db.SetUserStatus(ctx, id, status, reason)
+}
+
+func (s service) UnblockUser(ctx context.Context, id int) (models.User, error) {
+	return s.db.SetUserStatus(ctx, id, models.UserActive, "")
+}
+
+func (s service) GetLoanPolicies(ctx context.Context) ([]models.LoanPolicy, error) {
+	return s.db.GetLoanPolicies(ctx)
+}
+
+func (s service) SetLoanPolicy(ctx context.Context, policy models.LoanPolicy) error {
+	if !validCategory(policy.Category) {
+		return fmt.Errorf("%w: unknown category %q", ErrValidation, policy.Category)
+	}
+	if policy.MaxLoans < 0 || policy.LoanDays <= 0 || policy.MembershipMonths <= 0 {
+		return fmt.Errorf("%w: limits must be positive", ErrValidation)
+	}
+	if policy.DailyFine < 0 {
+		return fmt.Errorf("%w: daily fine must not be negative", ErrValidation)
+	}
+	return s.db.SetLoanPolicy(ctx, policy)
+}
+
+// SetGuardian назначает или снимает ответственного взрослого; остальные проверки
+// ответственного делает хранилище, им нужно состояние обоих читателей
+func (s service) SetGuardian(ctx context.Context, userID int, guardianID *int) (models.User, error) {
+	if guardianID != nil && *guardianID == userID {
+		return models.User{}, storage.ErrSelfGuardian
+	}
+	return s.db.SetGuardian(ctx, userID, guardianID)
+}
+
+func (s service) GetDependents(ctx context.Context, guardianID int) ([]models.Dependent, error) {
+	return s.db.GetDependents(ctx, guardianID)
+}
+
+func (s service) GetFines(ctx context.Context, userID int) ([]models.Fine, error) {
+	return s.db.GetFines(ctx, userID)
+}
+
+// AddFine проводит начисление (положительная сумма) или оплату (отрицательная сумма)
+func (s service) AddFine(ctx context.Context, fine models.Fine) (models.Fine, error) {
+	if fine.Amount == 0 {
+		return models.Fine{}, fmt.Errorf("%w: amount must not be zero", ErrValidation)
+	}
+	if fine.Reason == "" {
+		return models.Fine{}, fmt.Errorf("%w: reason is required", ErrValidation)
+	}
+	return s.db.AddFine(ctx, fine)
+}
+
+func (s service) GetFineBalance(ctx context.Context, userID int) (models.FineBalance, error) {
+	return s.db.GetFineBalance(ctx, userID)
+}
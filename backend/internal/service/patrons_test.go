@@ -0,0 +1,66 @@
+package service
+
+import (
+	"cmd/main.go/internal/storage"
+	"cmd/main.go/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+// Все случаи отклоняются до обращения к базе, поэтому хранилище не нужно
+func TestPatronValidation(t *testing.T) {
+	ctx := context.Background()
+	guardian := 7
+	tests := []struct {
+		name string
+		call func(s service) error
+		want error
+	}{
+		{"unknown category", func(s service) error {
+			_, err := s.AddUser(ctx, models.User{Name: "Ann", Category: "pensioner"})
+			return err
+		}, ErrValidation},
+		{"child without guardian", func(s service) error {
+			_, err := s.AddUser(ctx, models.User{Name: "Ann", Category: models.CategoryChild})
+			return err
+		}, storage.ErrGuardianRequired},
+		{"own guardian", func(s service) error {
+			_, err := s.SetGuardian(ctx, guardian, &guardian)
+			return err
+		}, storage.ErrSelfGuardian},
+		{"block with unknown status", func(s service) error {
+			_, err := s.BlockUser(ctx, 1, models.UserActive, "lost card")
+			return err
+		}, ErrValidation},
+		{"block without reason", func(s service) error {
+			_, err := s.BlockUser(ctx, 1, models.UserBlocked, "")
+			return err
+		}, ErrValidation},
+		{"policy for unknown category", func(s service) error {
+			return s.SetLoanPolicy(ctx, models.LoanPolicy{Category: "pensioner", MaxLoans: 5, LoanDays: 14, MembershipMonths: 12})
+		}, ErrValidation},
+		{"policy without loan days", func(s service) error {
+			return s.SetLoanPolicy(ctx, models.LoanPolicy{Category: models.CategoryAdult, MaxLoans: 5, MembershipMonths: 12})
+		}, ErrValidation},
+		{"policy with negative fine", func(s service) error {
+			return s.SetLoanPolicy(ctx, models.LoanPolicy{Category: models.CategoryAdult, MaxLoans: 5, LoanDays: 14, MembershipMonths: 12, DailyFine: -1})
+		}, ErrValidation},
+		{"zero fine", func(s service) error {
+			_, err := s.AddFine(ctx, models.Fine{UserID: 1, Reason: "late"})
+			return err
+		}, ErrValidation},
+		{"fine without reason", func(s service) error {
+			_, err := s.AddFine(ctx, models.Fine{UserID: 1, Amount: 10})
+			return err
+		}, ErrValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(service{}); !errors.Is(err, tt.want) {
+				t.Errorf("err = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,40 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+func validContactMethod(method string) bool {
+	switch method {
+	case models.ContactCalled, models.ContactEmailed, models.ContactLetter:
+		return true
+	}
+	return false
+}
+
+// GetOverdueReport собирает список читателей с просроченными займами для обзвона
+func (s service) GetOverdueReport(ctx context.Context, filter models.OverdueFilter) (models.OverdueReport, error) {
+	if filter.MinDays < 0 {
+		return models.OverdueReport{}, fmt.Errorf("%w: minimum days overdue must not be negative", ErrValidation)
+	}
+	if filter.Category != "" && !validCategory(filter.Category) {
+		return models.OverdueReport{}, fmt.Errorf("%w: unknown patron category %q", ErrValidation, filter.Category)
+	}
+	return s.db.GetOverdueReport(ctx, filter)
+}
+
+func (s service) GetContactAttempts(ctx context.Context, loanID int) ([]models.ContactAttempt, error) {
+	return s.db.GetContactAttempts(ctx, loanID)
+}
+
+// AddContactAttempt записывает попытку связаться с читателем по открытому займу
+func (s service) AddContactAttempt(ctx context.Context, attempt models.ContactAttempt) (models.ContactAttempt, error) {
+	if !validContactMethod(attempt.Method) {
+		return models.ContactAttempt{}, fmt.Errorf("%w: unknown contact method %q", ErrValidation, attempt.Method)
+	}
+	attempt.Note = strings.TrimSpace(attempt.Note)
+	return s.db.AddContactAttempt(ctx, attempt)
+}
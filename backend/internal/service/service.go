@@ -1,36 +1,131 @@
 package service
 
 import (
+	"cmd/main.go/internal/metrics"
 	"cmd/main.go/internal/storage"
 	"cmd/main.go/models"
+	"context"
+	"errors"
+	"fmt"
 )
 
-type Service interface {
-	GetBooks() ([]models.Book, error)
-	AddBook(book models.Book) (models.Book, error)
-	DeleteBook(id int) error
-
-	GetUsers() ([]models.User, error)
-	AddUser(user models.User) (models.User, error)
-	DeleteUser(id int) error
-
-	GetLoans() ([]models.Loan, error)
-	IssueLoan(userID, bookID int) (models.Loan, error)
-	ReturnLoan(loanID int) (models.Loan, error)
+// ErrValidation оборачивает ошибки некорректных входных данных
+var ErrValidation = errors.New("validation error")
 
-	GetStatistics() (models.Statistics, error)
+type Service interface {
+	GetBooks(ctx context.Context, branchID int) ([]models.Book, error)
+	AddBook(ctx context.Context, book models.Book) (models.Book, error)
+	DeleteBook(ctx context.Context, id int) error
+
+	GetUsers(ctx context.Context) ([]models.User, error)
+	GetUser(ctx context.Context, id int) (models.User, error)
+	GetUserByCard(ctx context.Context, cardNumber string) (models.User, error)
+	AddUser(ctx context.Context, user models.User) (models.User, error)
+	DeleteUser(ctx context.Context, id int) error
+	RenewUser(ctx context.Context, id int) (models.User, error)
+	BlockUser(ctx context.Context, id int, status, reason string) (models.User, error)
+	UnblockUser(ctx context.Context, id int) (models.User, error)
+	SetGuardian(ctx context.Context, userID int, guardianID *int) (models.User, error)
+	GetDependents(ctx context.Context, guardianID int) ([]models.Dependent, error)
+
+	GetFines(ctx context.Context, userID int) ([]models.Fine, error)
+	AddFine(ctx context.Context, fine models.Fine) (models.Fine, error)
+	GetFineBalance(ctx context.Context, userID int) (models.FineBalance, error)
+
+	GetNotificationPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error)
+	SetNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) (models.NotificationPreferences, error)
+	GetNotifications(ctx context.Context, status string, userID int) ([]models.Notification, error)
+
+	GetLoanPolicies(ctx context.Context) ([]models.LoanPolicy, error)
+	SetLoanPolicy(ctx context.Context, policy models.LoanPolicy) error
+
+	GetLoans(ctx context.Context, branchID int) ([]models.Loan, error)
+	IssueLoan(ctx context.Context, userID, bookID, branchID int) (models.Loan, error)
+	ReturnLoan(ctx context.Context, loanID int) (models.Loan, error)
+	DeclareLost(ctx context.Context, loanID int) (models.Fine, error)
+	DeclareDamaged(ctx context.Context, loanID int) (models.Fine, error)
+	MarkFound(ctx context.Context, loanID int) (models.Fine, error)
+
+	GetReplacementCosts(ctx context.Context) ([]models.ReplacementCost, error)
+	SetReplacementCost(ctx context.Context, cost models.ReplacementCost) error
+
+	GetBranches(ctx context.Context) ([]models.Branch, error)
+	AddBranch(ctx context.Context, branch models.Branch) (models.Branch, error)
+	GetCopies(ctx context.Context, bookID, branchID int) ([]models.Copy, error)
+	AddCopy(ctx context.Context, c models.Copy) (models.Copy, error)
+
+	GetHolds(ctx context.Context, branchID int) ([]models.Hold, error)
+	PlaceHold(ctx context.Context, hold models.Hold) (models.Hold, error)
+	CancelHold(ctx context.Context, holdID int) error
+
+	GetTransfers(ctx context.Context, branchID int) ([]models.Transfer, error)
+	RequestTransfer(ctx context.Context, copyID, toBranchID int) (models.Transfer, error)
+	ShipTransfer(ctx context.Context, transferID int) error
+	ReceiveTransfer(ctx context.Context, transferID int) error
+
+	GetStocktakes(ctx context.Context) ([]models.Stocktake, error)
+	OpenStocktake(ctx context.Context, st models.Stocktake) (models.Stocktake, error)
+	AddStocktakeScans(ctx context.Context, stocktakeID int, barcodes []string) (int, error)
+	GetStocktakeReport(ctx context.Context, stocktakeID int) (models.StocktakeReport, error)
+	MarkStocktakeMissingLost(ctx context.Context, stocktakeID int) (int, error)
+	CloseStocktake(ctx context.Context, stocktakeID int) error
+
+	GetVendors(ctx context.Context) ([]models.Vendor, error)
+	AddVendor(ctx context.Context, vendor models.Vendor) (models.Vendor, error)
+	GetFunds(ctx context.Context, year int) ([]models.Fund, error)
+	AddFund(ctx context.Context, fund models.Fund) (models.Fund, error)
+	GetFundReports(ctx context.Context, year int) ([]models.FundReport, error)
+
+	GetOrders(ctx context.Context, status string) ([]models.PurchaseOrder, error)
+	GetOrder(ctx context.Context, id int) (models.PurchaseOrder, error)
+	CreateOrder(ctx context.Context, order models.PurchaseOrder) (models.PurchaseOrder, error)
+	PlaceOrder(ctx context.Context, id int) (models.PurchaseOrder, error)
+	CancelOrder(ctx context.Context, id int) (models.PurchaseOrder, error)
+	ReceiveOrder(ctx context.Context, id, branchID int, lines []models.ReceiveLine) (models.PurchaseOrder, error)
+
+	GetWeedingCandidates(ctx context.Context, filter models.WeedingFilter) ([]models.WeedingCandidate, error)
+	WithdrawCopies(ctx context.Context, copyIDs []int, reason string) ([]models.Withdrawal, error)
+	GetWithdrawals(ctx context.Context, year int) ([]models.Withdrawal, error)
+	SetCopyCondition(ctx context.Context, copyID int, condition string) error
+
+	GetOverdueReport(ctx context.Context, filter models.OverdueFilter) (models.OverdueReport, error)
+	GetContactAttempts(ctx context.Context, loanID int) ([]models.ContactAttempt, error)
+	AddContactAttempt(ctx context.Context, attempt models.ContactAttempt) (models.ContactAttempt, error)
+
+	GetStatistics(ctx context.Context) (models.Statistics, error)
+	CountStatistics(ctx context.Context) (models.Statistics, error)
+	GetTrendStatistics(ctx context.Context, period models.StatisticsPeriod) (models.TrendStatistics, error)
+	GetRollupStatistics(ctx context.Context, period models.StatisticsPeriod, branchID int) (models.RollupStatistics, error)
+
+	MarkOverdueLoans(ctx context.Context) (int, error)
+	ExpireHolds(ctx context.Context, pickupDays int) (int, error)
+	RefreshStatistics(ctx context.Context) error
+
+	GetWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	AddWebhook(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id int) error
+	GetWebhookDeliveries(ctx context.Context, subscriptionID int, status string) ([]models.WebhookDelivery, error)
+	GetWebhookDelivery(ctx context.Context, id int) (models.WebhookDelivery, error)
+	RedeliverWebhook(ctx context.Context, id int) (models.WebhookDelivery, error)
+
+	GetOutboxConsumers(ctx context.Context) ([]models.ConsumerOffset, error)
+	PruneOutbox(ctx context.Context) (int, error)
+
+	GetCirculationCounts(ctx context.Context) (models.CirculationCounts, error)
+
+	CheckDatabase(ctx context.Context) error
 }
 
 type service struct {
 	db storage.Database
 }
 
-func (s service) GetBooks() ([]models.Book, error) {
-	return s.db.GetBooks()
+func (s service) GetBooks(ctx context.Context, branchID int) ([]models.Book, error) {
+	return s.db.GetBooks(ctx, branchID)
 }
 
-func (s service) AddBook(book models.Book) (models.Book, error) {
-	id, err := s.db.AddBook(book)
+func (s service) AddBook(ctx context.Context, book models.Book) (models.Book, error) {
+	id, err := s.db.AddBook(ctx, book)
 	if err != nil {
 		return models.Book{}, err
 	}
@@ -38,37 +133,49 @@ func (s service) AddBook(book models.Book) (models.Book, error) {
 	return book, nil
 }
 
-func (s service) DeleteBook(id int) error {
-	return s.db.DeleteBook(id)
-}
-
-func (s service) GetUsers() ([]models.User, error) {
-	return s.db.GetUsers()
+func (s service) DeleteBook(ctx context.Context, id int) error {
+	return s.db.DeleteBook(ctx, id)
 }
 
-func (s service) AddUser(user models.User) (models.User, error) {
-	return s.db.AddUser(user)
+func (s service) GetUsers(ctx context.Context) ([]models.User, error) {
+	return s.db.GetUsers(ctx)
 }
 
-func (s service) DeleteUser(id int) error {
-	return s.db.DeleteUser(id)
+func (s service) AddUser(ctx context.Context, user models.User) (models.User, error) {
+	if user.Category == "" {
+		user.Category = models.CategoryAdult
+	}
+	if !validCategory(user.Category) {
+		return models.User{}, fmt.Errorf("%w: unknown category %q", ErrValidation, user.Category)
+	}
+	if user.Category == models.CategoryChild && user.GuardianID == nil {
+		return models.User{}, storage.ErrGuardianRequired
+	}
+	return s.db.AddUser(ctx, user)
 }
 
-func (s service) GetLoans() ([]models.Loan, error) {
-	return s.db.GetLoans()
+func (s service) DeleteUser(ctx context.Context, id int) error {
+	return s.db.DeleteUser(ctx, id)
 }
 
-func (s service) IssueLoan(userID, bookID int) (models.Loan, error) {
-	return s.db.IssueLoan(userID, bookID)
+func (s service) GetLoans(ctx context.Context, branchID int) ([]models.Loan, error) {
+	return s.db.GetLoans(ctx, branchID)
 }
 
-func (s service) ReturnLoan(loanID int) (models.Loan, error) {
-	return s.db.ReturnLoan(loanID)
+func (s service) IssueLoan(ctx context.Context, userID, bookID, branchID int) (models.Loan, error) {
+	loan, err := s.db.IssueLoan(ctx, userID, bookID, branchID)
+	if err == nil {
+		metrics.LoansIssued.Inc()
+	}
+	return loan, err
 }
 
-func (s service) GetStatistics() (models.Statistics, error) {
-	stats, err := s.db.GetStats()
-	return *stats, err
+func (s service) ReturnLoan(ctx context.Context, loanID int) (models.Loan, error) {
+	loan, err := s.db.ReturnLoan(ctx, loanID)
+	if err == nil {
+		metrics.LoansReturned.Inc()
+	}
+	return loan, err
 }
 
 // NewService создает новый экземпляр сервиса
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// trendDefaultDays — период статистики по умолчанию, если не задано начало
+	trendDefaultDays = 30
+	// trendMaxPoints ограничивает длину ряда, чтобы нельзя было запросить ряд по дням за века
+	trendMaxPoints = 1000
+	// trendTopLimit — длина рейтингов книг, категорий и читателей
+	trendTopLimit = 10
+)
+
+// GetTrendStatistics отдает статистику за период по всей истории выдач
+func (s service) GetTrendStatistics(ctx context.Context, period models.StatisticsPeriod) (models.TrendStatistics, error) {
+	period, err := normalizePeriod(period)
+	if err != nil {
+		return models.TrendStatistics{}, err
+	}
+	return s.db.GetTrendStatistics(ctx, period, trendTopLimit)
+}
+
+// GetRollupStatistics отдает статистику за период по дневным сводкам выдач
+func (s service) GetRollupStatistics(ctx context.Context, period models.StatisticsPeriod, branchID int) (models.RollupStatistics, error) {
+	period, err := normalizePeriod(period)
+	if err != nil {
+		return models.RollupStatistics{}, err
+	}
+	return s.db.GetRollupStatistics(ctx, period, branchID)
+}
+
+// normalizePeriod подставляет значения по умолчанию и проверяет период: без конца период
+// заканчивается сегодня, без начала — начинается за 30 дней до конца; интервал — день.
+func normalizePeriod(period models.StatisticsPeriod) (models.StatisticsPeriod, error) {
+	if period.To.IsZero() {
+		period.To = time.Now()
+	}
+	if period.From.IsZero() {
+		period.From = period.To.AddDate(0, 0, -trendDefaultDays)
+	}
+	if period.Granularity == "" {
+		period.Granularity = models.GranularityDay
+	}
+
+	if period.From.After(period.To) {
+		return period, fmt.Errorf("%w: from must not be after to", ErrValidation)
+	}
+
+	var points int
+	days := int(period.To.Sub(period.From).Hours()/24) + 1
+	switch period.Granularity {
+	case models.GranularityDay:
+		points = days
+	case models.GranularityWeek:
+		points = days/7 + 1
+	case models.GranularityMonth:
+		points = days/28 + 1
+	default:
+		return period, fmt.Errorf("%w: unknown granularity %q", ErrValidation, period.Granularity)
+	}
+	if points > trendMaxPoints {
+		return period, fmt.Errorf("%w: period too long for %s granularity", ErrValidation, period.Granularity)
+	}
+
+	return period, nil
+}
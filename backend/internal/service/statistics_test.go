@@ -0,0 +1,79 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestNormalizePeriod(t *testing.T) {
+	day := func(s string) time.Time {
+		d, err := time.Parse(time.DateOnly, s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return d
+	}
+	tests := []struct {
+		name    string
+		period  models.StatisticsPeriod
+		want    models.StatisticsPeriod
+		wantErr bool
+	}{
+		{"defaults granularity",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-31")},
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-31"), Granularity: models.GranularityDay}, false},
+		{"defaults start",
+			models.StatisticsPeriod{To: day("2024-03-31"), Granularity: models.GranularityWeek},
+			models.StatisticsPeriod{From: day("2024-03-01"), To: day("2024-03-31"), Granularity: models.GranularityWeek}, false},
+		{"single day",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01"), Granularity: models.GranularityDay},
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01"), Granularity: models.GranularityDay}, false},
+		{"from after to",
+			models.StatisticsPeriod{From: day("2024-02-01"), To: day("2024-01-01")}, models.StatisticsPeriod{}, true},
+		{"unknown granularity",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-31"), Granularity: "hour"}, models.StatisticsPeriod{}, true},
+		{"days at the limit",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01").AddDate(0, 0, trendMaxPoints-1), Granularity: models.GranularityDay},
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01").AddDate(0, 0, trendMaxPoints-1), Granularity: models.GranularityDay}, false},
+		{"too many days",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01").AddDate(0, 0, trendMaxPoints), Granularity: models.GranularityDay},
+			models.StatisticsPeriod{}, true},
+		{"same range by month",
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01").AddDate(0, 0, trendMaxPoints), Granularity: models.GranularityMonth},
+			models.StatisticsPeriod{From: day("2024-01-01"), To: day("2024-01-01").AddDate(0, 0, trendMaxPoints), Granularity: models.GranularityMonth}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := normalizePeriod(tt.period)
+			if tt.wantErr {
+				if !errors.Is(err, ErrValidation) {
+					t.Errorf("err = %v, want %v", err, ErrValidation)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !got.From.Equal(tt.want.From) || !got.To.Equal(tt.want.To) || got.Granularity != tt.want.Granularity {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizePeriodDefaultEnd(t *testing.T) {
+	before := time.Now()
+	got, err := normalizePeriod(models.StatisticsPeriod{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.To.Before(before) || got.To.After(time.Now()) {
+		t.Errorf("to = %s, want now", got.To)
+	}
+	if want := got.To.AddDate(0, 0, -trendDefaultDays); !got.From.Equal(want) {
+		t.Errorf("from = %s, want %s", got.From, want)
+	}
+}
@@ -0,0 +1,45 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+	"strings"
+)
+
+func (s service) GetStocktakes(ctx context.Context) ([]models.Stocktake, error) {
+	return s.db.GetStocktakes(ctx)
+}
+
+func (s service) OpenStocktake(ctx context.Context, st models.Stocktake) (models.Stocktake, error) {
+	if st.ShelfFrom != "" && st.ShelfTo != "" && st.ShelfFrom > st.ShelfTo {
+		return models.Stocktake{}, fmt.Errorf("%w: shelfFrom must not be after shelfTo", ErrValidation)
+	}
+	return s.db.OpenStocktake(ctx, st)
+}
+
+// AddStocktakeScans принимает пачку штрихкодов со сканера; пустые строки и пробелы отбрасываются
+func (s service) AddStocktakeScans(ctx context.Context, stocktakeID int, barcodes []string) (int, error) {
+	cleaned := make([]string, 0, len(barcodes))
+	for _, barcode := range barcodes {
+		if barcode = strings.TrimSpace(barcode); barcode != "" {
+			cleaned = append(cleaned, barcode)
+		}
+	}
+	if len(cleaned) == 0 {
+		return 0, fmt.Errorf("%w: no barcodes in batch", ErrValidation)
+	}
+	return s.db.AddStocktakeScans(ctx, stocktakeID, cleaned)
+}
+
+func (s service) GetStocktakeReport(ctx context.Context, stocktakeID int) (models.StocktakeReport, error) {
+	return s.db.GetStocktakeReport(ctx, stocktakeID)
+}
+
+func (s service) MarkStocktakeMissingLost(ctx context.Context, stocktakeID int) (int, error) {
+	return s.db.MarkStocktakeMissingLost(ctx, stocktakeID)
+}
+
+func (s service) CloseStocktake(ctx context.Context, stocktakeID int) error {
+	return s.db.CloseStocktake(ctx, stocktakeID)
+}
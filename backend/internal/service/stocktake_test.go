@@ -0,0 +1,37 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestStocktakeValidation(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		name string
+		call func(s service) error
+	}{
+		{"shelf range reversed", func(s service) error {
+			_, err := s.OpenStocktake(ctx, models.Stocktake{BranchID: 1, ShelfFrom: "B", ShelfTo: "A"})
+			return err
+		}},
+		{"empty batch", func(s service) error {
+			_, err := s.AddStocktakeScans(ctx, 1, nil)
+			return err
+		}},
+		{"only blank barcodes", func(s service) error {
+			_, err := s.AddStocktakeScans(ctx, 1, []string{"", "  ", "\t"})
+			return err
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(service{}); !errors.Is(err, ErrValidation) {
+				t.Errorf("err = %v, want %v", err, ErrValidation)
+			}
+		})
+	}
+}
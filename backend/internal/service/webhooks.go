@@ -0,0 +1,64 @@
+package service
+
+import (
+	"cmd/main.go/internal/webhooks"
+	"cmd/main.go/models"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"slices"
+)
+
+// deliveriesLimit — сколько последних доставок подписки отдавать в журнале
+const deliveriesLimit = 100
+
+func (s service) GetWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	return s.db.GetWebhooks(ctx)
+}
+
+// AddWebhook регистрирует подписку; если секрет не задан, он генерируется и
+// возвращается один раз в ответе.
+func (s service) AddWebhook(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	if err := webhooks.CheckURL(ctx, sub.URL); err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("%w: %v", ErrValidation, err)
+	}
+	if len(sub.Events) == 0 {
+		return models.WebhookSubscription{}, fmt.Errorf("%w: at least one event type is required", ErrValidation)
+	}
+	for _, event := range sub.Events {
+		if !slices.Contains(models.EventTypes, event) {
+			return models.WebhookSubscription{}, fmt.Errorf("%w: unknown event type %q", ErrValidation, event)
+		}
+	}
+
+	if sub.Secret == "" {
+		secret := make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			return models.WebhookSubscription{}, err
+		}
+		sub.Secret = hex.EncodeToString(secret)
+	}
+	sub.Active = true
+
+	return s.db.AddWebhook(ctx, sub)
+}
+
+func (s service) DeleteWebhook(ctx context.Context, id int) error {
+	return s.db.DeleteWebhook(ctx, id)
+}
+
+func (s service) GetWebhookDeliveries(ctx context.Context, subscriptionID int, status string) ([]models.WebhookDelivery, error) {
+	return s.db.GetWebhookDeliveries(ctx, subscriptionID, status, deliveriesLimit)
+}
+
+func (s service) GetWebhookDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	return s.db.GetWebhookDelivery(ctx, id)
+}
+
+func (s service) RedeliverWebhook(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	if err := s.db.RedeliverWebhook(ctx, id); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	return s.db.GetWebhookDelivery(ctx, id)
+}
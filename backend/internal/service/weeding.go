@@ -0,0 +1,57 @@
+package service
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+func validCondition(condition string) bool {
+	switch condition {
+	case models.ConditionGood, models.ConditionFair, models.ConditionPoor:
+		return true
+	}
+	return false
+}
+
+func (s service) GetWeedingCandidates(ctx context.Context, filter models.WeedingFilter) ([]models.WeedingCandidate, error) {
+	if filter.Months <= 0 {
+		return nil, fmt.Errorf("%w: months must be positive", ErrValidation)
+	}
+	if filter.Condition != "" && !validCondition(filter.Condition) {
+		return nil, fmt.Errorf("%w: unknown condition %q", ErrValidation, filter.Condition)
+	}
+	return s.db.GetWeedingCandidates(ctx, filter)
+}
+
+// WithdrawCopies списывает экземпляры пачкой; причина обязательна, повторы в списке отбрасываются
+func (s service) WithdrawCopies(ctx context.Context, copyIDs []int, reason string) ([]models.Withdrawal, error) {
+	if reason == "" {
+		return nil, fmt.Errorf("%w: reason is required", ErrValidation)
+	}
+
+	seen := make(map[int]bool, len(copyIDs))
+	unique := make([]int, 0, len(copyIDs))
+	for _, id := range copyIDs {
+		if !seen[id] {
+			seen[id] = true
+			unique = append(unique, id)
+		}
+	}
+	if len(unique) == 0 {
+		return nil, fmt.Errorf("%w: no copies to withdraw", ErrValidation)
+	}
+
+	return s.db.WithdrawCopies(ctx, unique, reason)
+}
+
+func (s service) GetWithdrawals(ctx context.Context, year int) ([]models.Withdrawal, error) {
+	return s.db.GetWithdrawals(ctx, year)
+}
+
+func (s service) SetCopyCondition(ctx context.Context, copyID int, condition string) error {
+	if !validCondition(condition) {
+		return fmt.Errorf("%w: unknown condition %q", ErrValidation, condition)
+	}
+	return s.db.SetCopyCondition(ctx, copyID, condition)
+}
@@ -0,0 +1,134 @@
+package sse
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// EventStatistics — событие с полным снимком счетчиков статистики (models.Statistics).
+// Счетчики зависят от состояния базы (например, книга перестает учитываться, когда
+// утерян или списан ее последний экземпляр), поэтому отправляется снимок, а не разница.
+const EventStatistics = "statistics"
+
+// statisticsInterval — не чаще чем раз в столько пересчитывается снимок статистики;
+// события за это время объединяются в один пересчет
+const statisticsInterval = 2 * time.Second
+
+// statisticsEvents — события outbox, после которых счетчики дашборда могут измениться
+var statisticsEvents = map[string]bool{
+	models.EventBookAdded:     true,
+	models.EventBookDeleted:   true,
+	models.EventUserAdded:     true,
+	models.EventUserDeleted:   true,
+	models.EventLoanIssued:    true,
+	models.EventLoanReturned:  true,
+	models.EventCopyLost:      true,
+	models.EventCopyFound:     true,
+	models.EventCopyWithdrawn: true,
+}
+
+// HandleOutboxEvent — подписчик outbox: пересылает событие клиентам и, если оно
+// меняет статистику, планирует рассылку нового снимка статистики.
+func (h *Hub) HandleOutboxEvent(ctx context.Context, event models.OutboxEvent) error {
+	var ref struct {
+		BranchID       *int `json:"branchID"`
+		PickupBranchID *int `json:"pickupBranchID"`
+	}
+	// Полезная нагрузка событий удаления не содержит филиала — это не ошибка
+	_ = json.Unmarshal(event.Data, &ref)
+
+	branchID := 0
+	switch {
+	case ref.BranchID != nil:
+		branchID = *ref.BranchID
+	case ref.PickupBranchID != nil:
+		branchID = *ref.PickupBranchID
+	}
+
+	if err := h.Publish(event.Type, event.Data, branchID); err != nil {
+		return err
+	}
+	if statisticsEvents[event.Type] {
+		h.mu.Lock()
+		feed := h.statistics
+		h.mu.Unlock()
+		if feed != nil {
+			feed.schedule()
+		}
+	}
+	return nil
+}
+
+// PublishStatistics включает событие statistics: после событий, меняющих счетчики,
+// хаб загружает снимок через load и рассылает его всем клиентам.
+func (h *Hub) PublishStatistics(load func(ctx context.Context) (models.Statistics, error)) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.statistics = &statisticsFeed{
+		load:     load,
+		interval: statisticsInterval,
+		publish: func(stats models.Statistics) {
+			if err := h.Publish(EventStatistics, stats, 0); err != nil {
+				zap.L().Warn("Failed to publish statistics", zap.Error(err))
+			}
+		},
+	}
+}
+
+// statisticsFeed пересчитывает снимок статистики не чаще раза в interval. Пересчет
+// начинается после события, поэтому последнее изменение всегда попадает в снимок.
+type statisticsFeed struct {
+	load     func(ctx context.Context) (models.Statistics, error)
+	publish  func(stats models.Statistics)
+	interval time.Duration
+
+	mu      sync.Mutex
+	timer   *time.Timer // запланированный пересчет; nil — не запланирован
+	last    time.Time
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+func (f *statisticsFeed) schedule() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.stopped || f.timer != nil {
+		return
+	}
+	f.wg.Add(1)
+	f.timer = time.AfterFunc(max(f.interval-time.Since(f.last), 0), func() {
+		defer f.wg.Done()
+		f.refresh()
+	})
+}
+
+func (f *statisticsFeed) refresh() {
+	f.mu.Lock()
+	// События, пришедшие во время загрузки, запланируют следующий пересчет
+	f.timer, f.last = nil, time.Now()
+	f.mu.Unlock()
+
+	stats, err := f.load(context.Background())
+	if err != nil {
+		zap.L().Warn("Failed to load statistics for live events", zap.Error(err))
+		return
+	}
+	f.publish(stats)
+}
+
+// stop отменяет запланированный пересчет и дожидается идущего, чтобы после остановки
+// хаба база больше не использовалась
+func (f *statisticsFeed) stop() {
+	f.mu.Lock()
+	f.stopped = true
+	if f.timer != nil && f.timer.Stop() {
+		f.wg.Done()
+	}
+	f.mu.Unlock()
+	f.wg.Wait()
+}
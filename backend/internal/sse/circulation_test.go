@@ -0,0 +1,98 @@
+package sse
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// statisticsHub возвращает хаб, который пересчитывает статистику не чаще раза в interval, и
+// счетчик вызовов загрузки
+func statisticsHub(t *testing.T, interval time.Duration) (*Hub, *atomic.Int64) {
+	t.Helper()
+	h := NewHub(16)
+	var loads atomic.Int64
+	h.PublishStatistics(func(context.Context) (models.Statistics, error) {
+		n := loads.Add(1)
+		return models.Statistics{TotalBooks: int(n)}, nil
+	})
+	h.statistics.interval = interval
+	t.Cleanup(h.Close)
+	return h, &loads
+}
+
+func TestStatisticsEvents(t *testing.T) {
+	tests := []struct {
+		name   string
+		events []string
+		want   int64 // сколько раз загружен снимок
+	}{
+		{"unrelated event", []string{models.EventHoldPlaced}, 0},
+		{"loan issued", []string{models.EventLoanIssued}, 1},
+		{"copy lost", []string{models.EventCopyLost}, 1},
+		{"copy found", []string{models.EventCopyFound}, 1},
+		{"copy withdrawn", []string{models.EventCopyWithdrawn}, 1},
+		{"burst is coalesced", []string{models.EventBookAdded, models.EventUserAdded, models.EventLoanReturned}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, loads := statisticsHub(t, 50*time.Millisecond)
+			// Первый пересчет был только что, поэтому следующие события ждут interval
+			h.statistics.last = time.Now()
+			client, _, _ := h.Subscribe(Filter{Types: []string{EventStatistics}}, 0)
+
+			for _, event := range tt.events {
+				if err := h.HandleOutboxEvent(context.Background(), models.OutboxEvent{Type: event, Data: json.RawMessage(`{}`)}); err != nil {
+					t.Fatal(err)
+				}
+			}
+			time.Sleep(200 * time.Millisecond)
+
+			if got := loads.Load(); got != tt.want {
+				t.Fatalf("loaded %d times, want %d", got, tt.want)
+			}
+			if tt.want == 0 {
+				return
+			}
+			msg := <-client.Messages()
+			var stats models.Statistics
+			if err := json.Unmarshal(msg.Data, &stats); err != nil || stats.TotalBooks != 1 {
+				t.Errorf("statistics event = %s, %v", msg.Data, err)
+			}
+		})
+	}
+}
+
+func TestStatisticsCloseWaits(t *testing.T) {
+	h := NewHub(16)
+	started, release := make(chan struct{}), make(chan struct{})
+	var finished atomic.Bool
+	h.PublishStatistics(func(context.Context) (models.Statistics, error) {
+		close(started)
+		<-release
+		finished.Store(true)
+		return models.Statistics{}, nil
+	})
+	h.statistics.interval = 0
+
+	h.HandleOutboxEvent(context.Background(), models.OutboxEvent{Type: models.EventLoanIssued, Data: json.RawMessage(`{}`)})
+	<-started
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		close(release)
+	}()
+	h.Close()
+	if !finished.Load() {
+		t.Error("Close returned while statistics were loading")
+	}
+
+	// После Close новые события статистику не загружают
+	h.HandleOutboxEvent(context.Background(), models.OutboxEvent{Type: models.EventLoanIssued, Data: json.RawMessage(`{}`)})
+	if h.statistics.timer != nil {
+		t.Error("statistics scheduled after Close")
+	}
+}
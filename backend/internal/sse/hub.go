@@ -0,0 +1,170 @@
+package sse
+
+import (
+	"encoding/json"
+	"slices"
+	"sync"
+)
+
+// EventReset отправляется клиенту, чье Last-Event-ID уже вытеснено из буфера
+// (или выдано другим процессом): пропущенные события восстановить нельзя, и клиенту
+// нужно заново загрузить состояние целиком.
+const EventReset = "reset"
+
+// clientBuffer — сколько сообщений может ждать отправки одному клиенту. Клиент,
+// который не успевает их забирать, отключается и переподключается с Last-Event-ID.
+const clientBuffer = 256
+
+// Message — одно событие потока.
+type Message struct {
+	ID       uint64
+	Event    string
+	Data     json.RawMessage
+	BranchID int // 0 — событие не относится к конкретному филиалу
+}
+
+// Filter отбирает события для клиента. Пустой Types — все типы; BranchID == 0 — все филиалы.
+type Filter struct {
+	Types    []string
+	BranchID int
+}
+
+func (f Filter) match(m Message) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, m.Event) {
+		return false
+	}
+	return f.BranchID == 0 || m.BranchID == 0 || m.BranchID == f.BranchID
+}
+
+// Client — подписка одного подключения.
+type Client struct {
+	ch     chan Message
+	filter Filter
+}
+
+// Messages возвращает канал событий клиента. Канал закрывается, если клиент отстал
+// или хаб остановлен.
+func (c *Client) Messages() <-chan Message {
+	return c.ch
+}
+
+// Hub рассылает события всем подключенным клиентам и хранит последние события для
+// возобновления по Last-Event-ID. Publish никогда не блокируется на медленном клиенте.
+type Hub struct {
+	mu      sync.Mutex
+	seq     uint64
+	buffer  []Message // кольцевой буфер последних событий
+	next    int       // позиция следующей записи в buffer
+	clients map[*Client]struct{}
+	closed  bool
+
+	statistics *statisticsFeed // nil — событие statistics не рассылается
+}
+
+// NewHub создает хаб с буфером повтора на size событий.
+func NewHub(size int) *Hub {
+	return &Hub{
+		buffer:  make([]Message, 0, size),
+		clients: make(map[*Client]struct{}),
+	}
+}
+
+// Publish добавляет событие в буфер и рассылает его подходящим клиентам.
+func (h *Hub) Publish(event string, data any, branchID int) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.closed {
+		return nil
+	}
+
+	h.seq++
+	msg := Message{ID: h.seq, Event: event, Data: payload, BranchID: branchID}
+	if len(h.buffer) < cap(h.buffer) {
+		h.buffer = append(h.buffer, msg)
+	} else {
+		h.buffer[h.next] = msg
+	}
+	h.next = (h.next + 1) % cap(h.buffer)
+
+	for c := range h.clients {
+		if !c.filter.match(msg) {
+			continue
+		}
+		select {
+		case c.ch <- msg:
+		default:
+			// Клиент не успевает — отключаем, он догонит через Last-Event-ID
+			delete(h.clients, c)
+			close(c.ch)
+		}
+	}
+	return nil
+}
+
+// Subscribe подключает клиента. Если lastEventID не 0, возвращает пропущенные с тех
+// пор события; ok == false означает, что их уже нет в буфере.
+func (h *Hub) Subscribe(filter Filter, lastEventID uint64) (client *Client, replay []Message, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	client = &Client{ch: make(chan Message, clientBuffer), filter: filter}
+	if h.closed {
+		close(client.ch)
+		return client, nil, true
+	}
+	h.clients[client] = struct{}{}
+
+	if lastEventID == 0 {
+		return client, nil, true
+	}
+	oldest := h.seq + 1
+	if len(h.buffer) > 0 {
+		oldest = h.buffer[h.next%len(h.buffer)].ID
+	}
+	if lastEventID > h.seq || lastEventID+1 < oldest {
+		return client, nil, false
+	}
+
+	for i := range h.buffer {
+		msg := h.buffer[(h.next+i)%len(h.buffer)]
+		if msg.ID > lastEventID && filter.match(msg) {
+			replay = append(replay, msg)
+		}
+	}
+	return client, replay, true
+}
+
+// Unsubscribe отключает клиента.
+func (h *Hub) Unsubscribe(client *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.ch)
+	}
+}
+
+// Close отключает всех клиентов; новые подключения сразу завершаются. Идущий пересчет
+// статистики завершается до возврата.
+func (h *Hub) Close() {
+	h.mu.Lock()
+	feed := h.statistics
+	h.mu.Unlock()
+	if feed != nil {
+		// Без блокировки хаба: пересчет сам публикует через Publish
+		feed.stop()
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.closed = true
+	for c := range h.clients {
+		delete(h.clients, c)
+		close(c.ch)
+	}
+}
@@ -0,0 +1,136 @@
+package sse
+
+import (
+	"slices"
+	"testing"
+)
+
+func ids(msgs []Message) []uint64 {
+	var out []uint64
+	for _, m := range msgs {
+		out = append(out, m.ID)
+	}
+	return out
+}
+
+func TestSubscribeReplay(t *testing.T) {
+	tests := []struct {
+		name        string
+		size        int
+		published   int
+		lastEventID uint64
+		filter      Filter
+		wantIDs     []uint64
+		wantOK      bool
+	}{
+		{"new client", 4, 3, 0, Filter{}, nil, true},
+		{"up to date", 4, 3, 3, Filter{}, nil, true},
+		{"missed some", 4, 3, 1, Filter{}, []uint64{2, 3}, true},
+		{"buffer wrapped", 4, 10, 6, Filter{}, []uint64{7, 8, 9, 10}, true},
+		{"oldest evicted", 4, 10, 5, Filter{}, nil, false},
+		{"id from the future", 4, 3, 7, Filter{}, nil, false},
+		{"empty hub", 4, 0, 1, Filter{}, nil, false},
+		{"filtered by branch", 8, 6, 1, Filter{BranchID: 1}, []uint64{3, 5}, true},
+		{"filtered by type on replay", 8, 6, 2, Filter{Types: []string{"even"}}, []uint64{4, 6}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := NewHub(tt.size)
+			for i := 1; i <= tt.published; i++ {
+				event := "odd"
+				if i%2 == 0 {
+					event = "even"
+				}
+				// Нечетные события — филиал 1, четные — филиал 2
+				if err := h.Publish(event, i, 2-i%2); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			client, replay, ok := h.Subscribe(tt.filter, tt.lastEventID)
+			defer h.Unsubscribe(client)
+			if ok != tt.wantOK {
+				t.Errorf("ok = %v, want %v", ok, tt.wantOK)
+			}
+			if got := ids(replay); !slices.Equal(got, tt.wantIDs) {
+				t.Errorf("replay = %v, want %v", got, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestFilterMatch(t *testing.T) {
+	tests := []struct {
+		name   string
+		filter Filter
+		msg    Message
+		want   bool
+	}{
+		{"empty filter", Filter{}, Message{Event: "loan.issued", BranchID: 3}, true},
+		{"type listed", Filter{Types: []string{"loan.issued"}}, Message{Event: "loan.issued"}, true},
+		{"type not listed", Filter{Types: []string{"loan.issued"}}, Message{Event: "hold.ready"}, false},
+		{"same branch", Filter{BranchID: 3}, Message{BranchID: 3}, true},
+		{"other branch", Filter{BranchID: 3}, Message{BranchID: 4}, false},
+		{"event without branch", Filter{BranchID: 3}, Message{BranchID: 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.match(tt.msg); got != tt.want {
+				t.Errorf("match = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPublishDelivers(t *testing.T) {
+	h := NewHub(4)
+	client, _, _ := h.Subscribe(Filter{BranchID: 1}, 0)
+	defer h.Unsubscribe(client)
+
+	h.Publish("loan.issued", 1, 2)
+	h.Publish("loan.issued", 2, 1)
+
+	select {
+	case msg := <-client.Messages():
+		if msg.ID != 2 {
+			t.Errorf("got event %d, want 2", msg.ID)
+		}
+	default:
+		t.Fatal("no event delivered")
+	}
+}
+
+func TestSlowClientDisconnected(t *testing.T) {
+	h := NewHub(4)
+	client, _, _ := h.Subscribe(Filter{}, 0)
+	for i := 0; i <= clientBuffer; i++ {
+		h.Publish("loan.issued", i, 0)
+	}
+
+	n := 0
+	for range client.Messages() {
+		n++
+	}
+	if n != clientBuffer {
+		t.Errorf("got %d events before disconnect, want %d", n, clientBuffer)
+	}
+}
+
+func TestClose(t *testing.T) {
+	h := NewHub(4)
+	client, _, _ := h.Subscribe(Filter{}, 0)
+	h.Close()
+
+	if _, open := <-client.Messages(); open {
+		t.Error("client channel not closed")
+	}
+	late, _, _ := h.Subscribe(Filter{}, 0)
+	if _, open := <-late.Messages(); open {
+		t.Error("subscription after Close not closed")
+	}
+	if err := h.Publish("loan.issued", 1, 0); err != nil {
+		t.Errorf("Publish after Close: %v", err)
+	}
+}
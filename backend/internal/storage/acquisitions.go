@@ -0,0 +1,373 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Операции для поставщиков
+
+func (d *Database) GetVendors(ctx context.Context) ([]models.Vendor, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id, name, email, phone FROM vendors ORDER BY name")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var vendors []models.Vendor
+	for rows.Next() {
+		var vendor models.Vendor
+		if err := rows.Scan(&vendor.ID, &vendor.Name, &vendor.Email, &vendor.Phone); err != nil {
+			return nil, err
+		}
+		vendors = append(vendors, vendor)
+	}
+
+	return vendors, rows.Err()
+}
+
+func (d *Database) AddVendor(ctx context.Context, vendor models.Vendor) (models.Vendor, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, "INSERT INTO vendors (name, email, phone) VALUES ($1, $2, $3) RETURNING id",
+		vendor.Name, vendor.Email, vendor.Phone).Scan(&vendor.ID)
+	if err != nil {
+		return models.Vendor{}, err
+	}
+	return vendor, nil
+}
+
+// Операции для фондов
+
+// GetFunds возвращает фонды; если year не 0 — только за этот год.
+func (d *Database) GetFunds(ctx context.Context, year int) ([]models.Fund, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id, name, year, budget FROM funds WHERE $1 = 0 OR year = $1 ORDER BY year, name", year)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var funds []models.Fund
+	for rows.Next() {
+		var fund models.Fund
+		if err := rows.Scan(&fund.ID, &fund.Name, &fund.Year, &fund.Budget); err != nil {
+			return nil, err
+		}
+		funds = append(funds, fund)
+	}
+
+	return funds, rows.Err()
+}
+
+func (d *Database) AddFund(ctx context.Context, fund models.Fund) (models.Fund, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, "INSERT INTO funds (name, year, budget) VALUES ($1, $2, $3) RETURNING id",
+		fund.Name, fund.Year, fund.Budget).Scan(&fund.ID)
+	if err != nil {
+		return models.Fund{}, err
+	}
+	return fund, nil
+}
+
+// GetFundReports сравнивает выделенный бюджет фондов с потраченным (получено) и
+// зарезервированным (заказано, но еще не получено).
+func (d *Database) GetFundReports(ctx context.Context, year int) ([]models.FundReport, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT funds.id, funds.name, funds.year, funds.budget,
+			COALESCE(SUM((order_lines.quantity - order_lines.received) * order_lines.unit_price)
+				FILTER (WHERE purchase_orders.status IN ($2, $3)), 0),
+			COALESCE(SUM(order_lines.received * order_lines.unit_price), 0)
+		FROM funds
+		LEFT JOIN purchase_orders ON purchase_orders.fund_id = funds.id
+		LEFT JOIN order_lines ON order_lines.order_id = purchase_orders.id
+		WHERE $1 = 0 OR funds.year = $1
+		GROUP BY funds.id
+		ORDER BY funds.year, funds.name`, year, models.OrderPlaced, models.OrderPartiallyReceived)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.FundReport
+	for rows.Next() {
+		var report models.FundReport
+		if err := rows.Scan(&report.FundID, &report.Name, &report.Year, &report.Allocated, &report.Committed, &report.Spent); err != nil {
+			return nil, err
+		}
+		report.Remaining = report.Allocated - report.Committed - report.Spent
+		reports = append(reports, report)
+	}
+
+	return reports, rows.Err()
+}
+
+// Операции для заказов
+
+const orderColumns = "id, vendor_id, fund_id, status, created_at, ordered_at"
+
+func scanOrder(row rowScanner) (models.PurchaseOrder, error) {
+	var order models.PurchaseOrder
+	err := row.Scan(&order.ID, &order.VendorID, &order.FundID, &order.Status, &order.CreatedAt, &order.OrderedAt)
+	return order, err
+}
+
+// GetOrders возвращает заказы без строк; если status не пуст — только в этом статусе.
+func (d *Database) GetOrders(ctx context.Context, status string) ([]models.PurchaseOrder, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+orderColumns+` FROM purchase_orders WHERE $1 = '' OR status = $1 ORDER BY created_at DESC`, status)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var orders []models.PurchaseOrder
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, order)
+	}
+
+	return orders, rows.Err()
+}
+
+// GetOrder возвращает заказ вместе со строками.
+func (d *Database) GetOrder(ctx context.Context, id int) (models.PurchaseOrder, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	order, err := scanOrder(d.db.QueryRowContext(ctx, `SELECT `+orderColumns+` FROM purchase_orders WHERE id = $1`, id))
+	if err == sql.ErrNoRows {
+		return models.PurchaseOrder{}, ErrNotFound
+	}
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, order_id, title, author, category, isbn, quantity, unit_price, received, book_id
+		FROM order_lines WHERE order_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var line models.OrderLine
+		if err := rows.Scan(&line.ID, &line.OrderID, &line.Title, &line.Author, &line.Category, &line.ISBN,
+			&line.Quantity, &line.UnitPrice, &line.Received, &line.BookID); err != nil {
+			return models.PurchaseOrder{}, err
+		}
+		order.Lines = append(order.Lines, line)
+	}
+
+	return order, rows.Err()
+}
+
+// CreateOrder создает черновик заказа со строками.
+func (d *Database) CreateOrder(ctx context.Context, order models.PurchaseOrder) (models.PurchaseOrder, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	defer tx.Rollback()
+
+	var id int
+	err = tx.QueryRowContext(ctx, "INSERT INTO purchase_orders (vendor_id, fund_id, status) VALUES ($1, $2, $3) RETURNING id",
+		order.VendorID, order.FundID, models.OrderDraft).Scan(&id)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	for _, line := range order.Lines {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO order_lines (order_id, title, author, category, isbn, quantity, unit_price)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			id, line.Title, line.Author, line.Category, line.ISBN, line.Quantity, line.UnitPrice)
+		if err != nil {
+			return models.PurchaseOrder{}, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	return d.GetOrder(ctx, id)
+}
+
+// SetOrderStatus переводит заказ в статус to, если он сейчас в одном из статусов from.
+func (d *Database) SetOrderStatus(ctx context.Context, id int, to string, from ...string) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var orderedAt *time.Time
+	if to == models.OrderPlaced {
+		now := time.Now()
+		orderedAt = &now
+	}
+
+	var found bool
+	err := d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM purchase_orders WHERE id = $1)", id).Scan(&found)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE purchase_orders SET status = $1, ordered_at = COALESCE($2, ordered_at)
+		WHERE id = $3 AND status = ANY($4)`, to, orderedAt, id, pq.Array(from))
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+// ReceiveOrder принимает поступление по заказу в филиал: для каждой строки находит книгу
+// по ISBN или заводит новую, создает экземпляры со сгенерированными штрихкодами и ценой
+// из заказа и пересчитывает статус заказа.
+func (d *Database) ReceiveOrder(ctx context.Context, orderID, branchID int, lines []models.ReceiveLine) (models.PurchaseOrder, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM purchase_orders WHERE id = $1 FOR UPDATE", orderID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return models.PurchaseOrder{}, ErrNotFound
+	}
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+	if status != models.OrderPlaced && status != models.OrderPartiallyReceived {
+		return models.PurchaseOrder{}, ErrInvalidState
+	}
+
+	for _, rl := range lines {
+		if err := d.receiveOrderLine(ctx, tx, orderID, branchID, rl); err != nil {
+			return models.PurchaseOrder{}, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE purchase_orders SET status = CASE
+			WHEN NOT EXISTS (SELECT 1 FROM order_lines WHERE order_id = $1 AND received < quantity) THEN $2
+			ELSE $3 END
+		WHERE id = $1`, orderID, models.OrderReceived, models.OrderPartiallyReceived)
+	if err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.PurchaseOrder{}, err
+	}
+
+	return d.GetOrder(ctx, orderID)
+}
+
+func (d *Database) receiveOrderLine(ctx context.Context, tx *sql.Tx, orderID, branchID int, rl models.ReceiveLine) error {
+	var line models.OrderLine
+	err := tx.QueryRowContext(ctx, `
+		SELECT title, author, category, isbn, quantity, unit_price, received, book_id
+		FROM order_lines WHERE id = $1 AND order_id = $2
+		FOR UPDATE`, rl.LineID, orderID).Scan(&line.Title, &line.Author, &line.Category, &line.ISBN,
+		&line.Quantity, &line.UnitPrice, &line.Received, &line.BookID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if rl.Quantity <= 0 || line.Received+rl.Quantity > line.Quantity {
+		return ErrInvalidState
+	}
+
+	bookID := 0
+	if line.BookID != nil {
+		bookID = *line.BookID
+	} else {
+		// Книга с тем же ISBN уже может быть в каталоге — тогда добавляем к ней экземпляры.
+		// xmax = 0 только у вставленной строки: о новой книге сообщаем, как AddBook.
+		book := models.Book{Title: line.Title, Author: line.Author, Category: line.Category}
+		if line.ISBN != "" {
+			book.ISBN = &line.ISBN
+		}
+		var inserted bool
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO books (title, author, category, isbn) VALUES ($1, $2, $3, $4)
+			ON CONFLICT (isbn) DO UPDATE SET isbn = EXCLUDED.isbn
+			RETURNING id, (xmax = 0)`, book.Title, book.Author, book.Category, book.ISBN).Scan(&book.ID, &inserted)
+		if err != nil {
+			return err
+		}
+		if inserted {
+			if err := recordEvent(ctx, tx, models.EventBookAdded, book); err != nil {
+				return err
+			}
+		}
+		bookID = book.ID
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO copies (id, book_id, barcode, home_branch_id, current_branch_id, status, price)
+		SELECT ids.id, $1, 'CPY' || lpad(ids.id::text, 8, '0'), $2, $2, $3, $4
+		FROM (SELECT nextval(pg_get_serial_sequence('copies', 'id')) AS id FROM generate_series(1, $5)) AS ids
+		RETURNING id`, bookID, branchID, models.CopyAvailable, line.UnitPrice, rl.Quantity)
+	if err != nil {
+		return err
+	}
+	var copyIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		copyIDs = append(copyIDs, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Новые экземпляры сразу закрывают ожидающие брони
+	for _, id := range copyIDs {
+		if err := d.releaseCopy(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE order_lines SET received = received + $1, book_id = $2 WHERE id = $3", rl.Quantity, bookID, rl.LineID)
+	return err
+}
@@ -0,0 +1,415 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Операции для филиалов
+
+func (d *Database) GetBranches(ctx context.Context) ([]models.Branch, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id, name, address FROM branches ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var branches []models.Branch
+	for rows.Next() {
+		var branch models.Branch
+		if err := rows.Scan(&branch.ID, &branch.Name, &branch.Address); err != nil {
+			return nil, err
+		}
+		branches = append(branches, branch)
+	}
+
+	return branches, rows.Err()
+}
+
+func (d *Database) AddBranch(ctx context.Context, branch models.Branch) (models.Branch, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, "INSERT INTO branches (name, address) VALUES ($1, $2) RETURNING id", branch.Name, branch.Address).Scan(&branch.ID)
+	if err != nil {
+		return models.Branch{}, err
+	}
+
+	return branch, nil
+}
+
+// Операции для экземпляров
+
+// GetCopies возвращает экземпляры; нулевые bookID и branchID означают «без фильтра».
+func (d *Database) GetCopies(ctx context.Context, bookID, branchID int) ([]models.Copy, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+copyColumns+` FROM copies
+		WHERE ($1 = 0 OR book_id = $1) AND ($2 = 0 OR current_branch_id = $2)
+		ORDER BY id`, bookID, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCopies(rows)
+}
+
+const copyColumns = `copies.id, copies.book_id, copies.barcode, copies.home_branch_id, copies.current_branch_id,
+	copies.status, copies.price, copies.shelf, copies.condition, copies.acquired_at`
+
+func scanCopies(rows *sql.Rows) ([]models.Copy, error) {
+	var copies []models.Copy
+	for rows.Next() {
+		var c models.Copy
+		if err := rows.Scan(&c.ID, &c.BookID, &c.Barcode, &c.HomeBranchID, &c.CurrentBranchID, &c.Status, &c.Price, &c.Shelf, &c.Condition, &c.AcquiredAt); err != nil {
+			return nil, err
+		}
+		copies = append(copies, c)
+	}
+
+	return copies, rows.Err()
+}
+
+// AddCopy добавляет экземпляр в домашний филиал и сразу пытается закрыть им ожидающую бронь.
+func (d *Database) AddCopy(ctx context.Context, c models.Copy) (models.Copy, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Copy{}, err
+	}
+	defer tx.Rollback()
+
+	c.CurrentBranchID = c.HomeBranchID
+	c.Status = models.CopyAvailable
+	if c.Condition == "" {
+		c.Condition = models.ConditionGood
+	}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO copies (book_id, barcode, home_branch_id, current_branch_id, status, price, shelf, condition)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id, acquired_at`,
+		c.BookID, c.Barcode, c.HomeBranchID, c.CurrentBranchID, c.Status, c.Price, c.Shelf, c.Condition).Scan(&c.ID, &c.AcquiredAt)
+	if err != nil {
+		return models.Copy{}, err
+	}
+
+	if err := d.releaseCopy(ctx, tx, c.ID); err != nil {
+		return models.Copy{}, err
+	}
+	if err := tx.QueryRowContext(ctx, "SELECT status FROM copies WHERE id = $1", c.ID).Scan(&c.Status); err != nil {
+		return models.Copy{}, err
+	}
+
+	return c, tx.Commit()
+}
+
+// releaseCopy освобождает экземпляр: откладывает его под самую раннюю ожидающую бронь
+// в текущем филиале, иначе отправляет под бронь в другом филиале, иначе делает доступным.
+func (d *Database) releaseCopy(ctx context.Context, tx *sql.Tx, copyID int) error {
+	var bookID, branchID int
+	err := tx.QueryRowContext(ctx, "SELECT book_id, current_branch_id FROM copies WHERE id = $1 FOR UPDATE", copyID).Scan(&bookID, &branchID)
+	if err != nil {
+		return err
+	}
+
+	var holdID, pickupBranchID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, pickup_branch_id FROM holds
+		WHERE book_id = $1 AND status = $2
+		ORDER BY pickup_branch_id = $3 DESC, created_at
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, bookID, models.HoldWaiting, branchID).Scan(&holdID, &pickupBranchID)
+	if err == sql.ErrNoRows {
+		_, err = tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", models.CopyAvailable, copyID)
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	return d.assignCopyToHold(ctx, tx, holdID, pickupBranchID, copyID, branchID)
+}
+
+// assignCopyToHold закрепляет экземпляр за бронью. Если экземпляр находится в другом
+// филиале, создается заявка на перемещение в филиал выдачи.
+func (d *Database) assignCopyToHold(ctx context.Context, tx *sql.Tx, holdID, pickupBranchID, copyID, copyBranchID int) error {
+	if copyBranchID == pickupBranchID {
+		if _, err := tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", models.CopyOnHold, copyID); err != nil {
+			return err
+		}
+		_, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1, copy_id = $2, ready_at = now() WHERE id = $3", models.HoldReady, copyID, holdID)
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", models.CopyInTransit, copyID); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1, copy_id = $2 WHERE id = $3", models.HoldInTransit, copyID, holdID); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO transfers (copy_id, from_branch_id, to_branch_id, hold_id, status)
+		VALUES ($1, $2, $3, $4, $5)`, copyID, copyBranchID, pickupBranchID, holdID, models.TransferRequested)
+	return err
+}
+
+// Операции для броней
+
+// GetHolds возвращает брони; если branchID не 0 — только с выдачей в этом филиале.
+func (d *Database) GetHolds(ctx context.Context, branchID int) ([]models.Hold, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, user_id, book_id, pickup_branch_id, copy_id, status, created_at FROM holds
+		WHERE $1 = 0 OR pickup_branch_id = $1
+		ORDER BY created_at`, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []models.Hold
+	for rows.Next() {
+		var hold models.Hold
+		if err := rows.Scan(&hold.ID, &hold.UserID, &hold.BookID, &hold.PickupBranchID, &hold.CopyID, &hold.Status, &hold.CreatedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// PlaceHold создает бронь и сразу пытается закрыть ее свободным экземпляром:
+// сначала в филиале выдачи, затем в любом другом филиале через перемещение.
+func (d *Database) PlaceHold(ctx context.Context, hold models.Hold) (models.Hold, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Hold{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkCategoryRestriction(ctx, tx, hold.UserID, hold.BookID); err != nil {
+		return models.Hold{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO holds (user_id, book_id, pickup_branch_id, status)
+		VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+		hold.UserID, hold.BookID, hold.PickupBranchID, models.HoldWaiting).Scan(&hold.ID, &hold.CreatedAt)
+	if err != nil {
+		return models.Hold{}, err
+	}
+
+	var copyID, copyBranchID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, current_branch_id FROM copies
+		WHERE book_id = $1 AND status = $2
+		ORDER BY current_branch_id = $3 DESC, id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, hold.BookID, models.CopyAvailable, hold.PickupBranchID).Scan(&copyID, &copyBranchID)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return models.Hold{}, err
+	default:
+		if err := d.assignCopyToHold(ctx, tx, hold.ID, hold.PickupBranchID, copyID, copyBranchID); err != nil {
+			return models.Hold{}, err
+		}
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT copy_id, status FROM holds WHERE id = $1", hold.ID).Scan(&hold.CopyID, &hold.Status)
+	if err != nil {
+		return models.Hold{}, err
+	}
+	if err := recordEvent(ctx, tx, models.EventHoldPlaced, hold); err != nil {
+		return models.Hold{}, err
+	}
+
+	return hold, tx.Commit()
+}
+
+// CancelHold отменяет бронь. Отложенный на полке экземпляр передается следующей брони;
+// экземпляр в пути освобождается при приемке перемещения.
+func (d *Database) CancelHold(ctx context.Context, holdID int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var copyID *int
+	err = tx.QueryRowContext(ctx, "SELECT status, copy_id FROM holds WHERE id = $1 FOR UPDATE", holdID).Scan(&status, &copyID)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.HoldWaiting && status != models.HoldInTransit && status != models.HoldReady {
+		return ErrInvalidState
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1 WHERE id = $2", models.HoldCancelled, holdID); err != nil {
+		return err
+	}
+
+	if status == models.HoldReady && copyID != nil {
+		if err := d.releaseCopy(ctx, tx, *copyID); err != nil {
+			return err
+		}
+	}
+	if err := recordEvent(ctx, tx, models.EventHoldCancelled, models.EntityDeleted{ID: holdID}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// Операции для перемещений
+
+// GetTransfers возвращает перемещения; если branchID не 0 — только из филиала или в него.
+func (d *Database) GetTransfers(ctx context.Context, branchID int) ([]models.Transfer, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, copy_id, from_branch_id, to_branch_id, hold_id, status, requested_at, shipped_at, received_at
+		FROM transfers
+		WHERE $1 = 0 OR from_branch_id = $1 OR to_branch_id = $1
+		ORDER BY requested_at`, branchID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transfers []models.Transfer
+	for rows.Next() {
+		var t models.Transfer
+		if err := rows.Scan(&t.ID, &t.CopyID, &t.FromBranchID, &t.ToBranchID, &t.HoldID, &t.Status, &t.RequestedAt, &t.ShippedAt, &t.ReceivedAt); err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, t)
+	}
+
+	return transfers, rows.Err()
+}
+
+// RequestTransfer создает заявку на перемещение свободного экземпляра в другой филиал.
+func (d *Database) RequestTransfer(ctx context.Context, copyID, toBranchID int) (models.Transfer, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+	defer tx.Rollback()
+
+	t := models.Transfer{CopyID: copyID, ToBranchID: toBranchID, Status: models.TransferRequested}
+	err = tx.QueryRowContext(ctx, `
+		UPDATE copies SET status = $1
+		WHERE id = $2 AND status = $3 AND current_branch_id <> $4
+		RETURNING current_branch_id`, models.CopyInTransit, copyID, models.CopyAvailable, toBranchID).Scan(&t.FromBranchID)
+	if err == sql.ErrNoRows {
+		return models.Transfer{}, ErrInvalidState
+	}
+	if err != nil {
+		return models.Transfer{}, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO transfers (copy_id, from_branch_id, to_branch_id, status)
+		VALUES ($1, $2, $3, $4) RETURNING id, requested_at`,
+		t.CopyID, t.FromBranchID, t.ToBranchID, t.Status).Scan(&t.ID, &t.RequestedAt)
+	if err != nil {
+		return models.Transfer{}, err
+	}
+
+	return t, tx.Commit()
+}
+
+// ShipTransfer отмечает, что экземпляр отправлен из филиала.
+func (d *Database) ShipTransfer(ctx context.Context, transferID int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "UPDATE transfers SET status = $1, shipped_at = $2 WHERE id = $3 AND status = $4",
+		models.TransferInTransit, time.Now(), transferID, models.TransferRequested)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+// ReceiveTransfer принимает экземпляр в филиале назначения. Если перемещение
+// выполнялось под бронь, бронь становится готовой к выдаче.
+func (d *Database) ReceiveTransfer(ctx context.Context, transferID int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var copyID, toBranchID int
+	var holdID *int
+	err = tx.QueryRowContext(ctx, `
+		UPDATE transfers SET status = $1, received_at = $2
+		WHERE id = $3 AND status IN ($4, $5)
+		RETURNING copy_id, to_branch_id, hold_id`,
+		models.TransferReceived, time.Now(), transferID, models.TransferRequested, models.TransferInTransit).Scan(&copyID, &toBranchID, &holdID)
+	if err == sql.ErrNoRows {
+		return ErrInvalidState
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE copies SET current_branch_id = $1 WHERE id = $2", toBranchID, copyID); err != nil {
+		return err
+	}
+
+	filled := false
+	if holdID != nil {
+		res, err := tx.ExecContext(ctx, "UPDATE holds SET status = $1, ready_at = now() WHERE id = $2 AND status = $3", models.HoldReady, *holdID, models.HoldInTransit)
+		if err != nil {
+			return err
+		}
+		n, _ := res.RowsAffected()
+		filled = n > 0
+	}
+
+	if filled {
+		_, err = tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", models.CopyOnHold, copyID)
+	} else {
+		err = d.releaseCopy(ctx, tx, copyID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
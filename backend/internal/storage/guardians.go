@@ -0,0 +1,210 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+)
+
+// checkGuardian проверяет ответственного взрослого для читателя категории category:
+// у детского билета он обязателен, а указанный для любого читателя должен существовать,
+// быть активен и сам не быть ребенком.
+func checkGuardian(ctx context.Context, q queryRower, category string, guardianID *int) error {
+	if guardianID == nil {
+		if category == models.CategoryChild {
+			return ErrGuardianRequired
+		}
+		return nil
+	}
+
+	var guardianCategory, status string
+	err := q.QueryRowContext(ctx, "SELECT category, status FROM users WHERE id = $1", *guardianID).Scan(&guardianCategory, &status)
+	if err == sql.ErrNoRows {
+		return ErrInvalidGuardian
+	}
+	if err != nil {
+		return err
+	}
+	if guardianCategory == models.CategoryChild || status != models.UserActive {
+		return ErrInvalidGuardian
+	}
+	return nil
+}
+
+// SetGuardian привязывает читателя к ответственному взрослому. Для детских билетов
+// отвязка (guardianID == nil) запрещена.
+func (d *Database) SetGuardian(ctx context.Context, userID int, guardianID *int) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	var category string
+	err = tx.QueryRowContext(ctx, "SELECT category FROM users WHERE id = $1 FOR UPDATE", userID).Scan(&category)
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrNotFound
+	}
+	if err != nil {
+		return models.User{}, err
+	}
+
+	if err := checkGuardian(ctx, tx, category, guardianID); err != nil {
+		return models.User{}, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE users SET guardian_id = $1 WHERE id = $2", guardianID, userID); err != nil {
+		return models.User{}, err
+	}
+
+	user, err := getUser(ctx, tx, "users.id = $1", userID)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return user, tx.Commit()
+}
+
+// GetDependents возвращает подопечных читателя вместе с их займами и бронями.
+func (d *Database) GetDependents(ctx context.Context, guardianID int) ([]models.Dependent, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+userColumns+userBorrowedJoin+` WHERE users.guardian_id = $1 GROUP BY users.id ORDER BY users.id`, guardianID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dependents []models.Dependent
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		dependents = append(dependents, models.Dependent{User: user})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i := range dependents {
+		if dependents[i].Loans, err = d.getUserLoans(ctx, dependents[i].User.ID); err != nil {
+			return nil, err
+		}
+		if dependents[i].Holds, err = d.getUserHolds(ctx, dependents[i].User.ID); err != nil {
+			return nil, err
+		}
+	}
+
+	return dependents, nil
+}
+
+func (d *Database) getUserLoans(ctx context.Context, userID int) ([]models.Loan, error) {
+	rows, err := d.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE user_id = $1 ORDER BY borrow_date DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var loans []models.Loan
+	for rows.Next() {
+		loan, err := scanLoan(rows)
+		if err != nil {
+			return nil, err
+		}
+		loans = append(loans, loan)
+	}
+
+	return loans, rows.Err()
+}
+
+func (d *Database) getUserHolds(ctx context.Context, userID int) ([]models.Hold, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, user_id, book_id, pickup_branch_id, copy_id, status, created_at FROM holds
+		WHERE user_id = $1 AND status IN ($2, $3, $4) ORDER BY created_at`,
+		userID, models.HoldWaiting, models.HoldInTransit, models.HoldReady)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []models.Hold
+	for rows.Next() {
+		var hold models.Hold
+		if err := rows.Scan(&hold.ID, &hold.UserID, &hold.BookID, &hold.PickupBranchID, &hold.CopyID, &hold.Status, &hold.CreatedAt); err != nil {
+			return nil, err
+		}
+		holds = append(holds, hold)
+	}
+
+	return holds, rows.Err()
+}
+
+// Операции для штрафов
+
+// GetFines возвращает записи журнала штрафов читателя и его подопечных.
+func (d *Database) GetFines(ctx context.Context, userID int) ([]models.Fine, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT fines.id, fines.user_id, fines.loan_id, fines.amount, fines.reason, fines.created_at
+		FROM fines
+		JOIN users ON users.id = fines.user_id
+		WHERE users.id = $1 OR users.guardian_id = $1
+		ORDER BY fines.created_at`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fines []models.Fine
+	for rows.Next() {
+		var fine models.Fine
+		if err := rows.Scan(&fine.ID, &fine.UserID, &fine.LoanID, &fine.Amount, &fine.Reason, &fine.CreatedAt); err != nil {
+			return nil, err
+		}
+		fines = append(fines, fine)
+	}
+
+	return fines, rows.Err()
+}
+
+// AddFine добавляет запись в журнал штрафов (начисление или оплату).
+func (d *Database) AddFine(ctx context.Context, fine models.Fine) (models.Fine, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, `
+		INSERT INTO fines (user_id, loan_id, amount, reason) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, fine.UserID, fine.LoanID, fine.Amount, fine.Reason).Scan(&fine.ID, &fine.CreatedAt)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	return fine, nil
+}
+
+// GetFineBalance считает задолженность читателя; штрафы подопечных входят в баланс опекуна.
+func (d *Database) GetFineBalance(ctx context.Context, userID int) (models.FineBalance, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	balance := models.FineBalance{UserID: userID}
+	err := d.db.QueryRowContext(ctx, `
+		SELECT
+			COALESCE(SUM(fines.amount) FILTER (WHERE users.id = $1), 0),
+			COALESCE(SUM(fines.amount) FILTER (WHERE users.guardian_id = $1), 0)
+		FROM users
+		LEFT JOIN fines ON fines.user_id = users.id
+		WHERE users.id = $1 OR users.guardian_id = $1`, userID).Scan(&balance.Own, &balance.Dependents)
+	if err != nil {
+		return models.FineBalance{}, err
+	}
+
+	balance.Total = balance.Own + balance.Dependents
+	return balance, nil
+}
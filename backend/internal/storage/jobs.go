@@ -0,0 +1,202 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"hash/fnv"
+	"time"
+)
+
+// Операции для фоновых задач
+
+// TryJobLock пытается взять advisory-блокировку PostgreSQL для задачи. Блокировка
+// сессионная, поэтому держится на выделенном соединении до вызова unlock; так одну
+// задачу в каждый момент выполняет только одна реплика.
+func (d *Database) TryJobLock(ctx context.Context, job string) (unlock func(), ok bool, err error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	h := fnv.New64a()
+	h.Write([]byte("job:" + job))
+	key := int64(h.Sum64())
+
+	conn, err := d.db.Conn(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&ok); err != nil || !ok {
+		conn.Close()
+		return nil, false, err
+	}
+
+	return func() {
+		// Соединение могло быть закрыто вместе с контекстом задачи — снимаем блокировку в отдельном
+		conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+		conn.Close()
+	}, true, nil
+}
+
+// StartJobRun записывает начало попытки выполнения задачи.
+func (d *Database) StartJobRun(ctx context.Context, job string, attempt int) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var id int
+	err := d.db.QueryRowContext(ctx, "INSERT INTO job_runs (job, attempt, status) VALUES ($1, $2, $3) RETURNING id",
+		job, attempt, models.JobRunning).Scan(&id)
+	return id, err
+}
+
+// FinishJobRun записывает завершение попытки; runErr == nil означает успех.
+func (d *Database) FinishJobRun(ctx context.Context, id int, runErr error) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	status, message := models.JobSucceeded, (*string)(nil)
+	if runErr != nil {
+		text := runErr.Error()
+		status, message = models.JobFailed, &text
+	}
+	_, err := d.db.ExecContext(ctx, "UPDATE job_runs SET status = $1, finished_at = now(), error = $2 WHERE id = $3", status, message, id)
+	return err
+}
+
+// GetJobRuns возвращает последние запуски задачи (или всех задач, если job пуст).
+func (d *Database) GetJobRuns(ctx context.Context, job string, limit int) ([]models.JobRun, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, job, attempt, status, started_at, finished_at, error FROM job_runs
+		WHERE $1 = '' OR job = $1
+		ORDER BY started_at DESC
+		LIMIT $2`, job, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var runs []models.JobRun
+	for rows.Next() {
+		var run models.JobRun
+		if err := rows.Scan(&run.ID, &run.Job, &run.Attempt, &run.Status, &run.StartedAt, &run.FinishedAt, &run.Error); err != nil {
+			return nil, err
+		}
+		runs = append(runs, run)
+	}
+
+	return runs, rows.Err()
+}
+
+// MarkOverdueLoans помечает просроченными открытые займы с истекшим сроком возврата.
+func (d *Database) MarkOverdueLoans(ctx context.Context) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "UPDATE loans SET overdue = true WHERE return_date IS NULL AND due_date < CURRENT_DATE AND NOT overdue")
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// ExpireHolds снимает брони, которые ждут на полке дольше pickupDays дней;
+// освободившиеся экземпляры уходят следующим в очереди.
+func (d *Database) ExpireHolds(ctx context.Context, pickupDays int) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE holds SET status = $1
+		WHERE id IN (
+			SELECT id FROM holds
+			WHERE status = $2 AND ready_at < now() - make_interval(days => $3)
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING copy_id`, models.HoldExpired, models.HoldReady, pickupDays)
+	if err != nil {
+		return 0, err
+	}
+	var copyIDs []int
+	for rows.Next() {
+		var copyID int
+		if err := rows.Scan(&copyID); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		copyIDs = append(copyIDs, copyID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for _, copyID := range copyIDs {
+		if err := d.releaseCopy(ctx, tx, copyID); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(copyIDs), tx.Commit()
+}
+
+// SaveStatisticsSnapshot сохраняет рассчитанную статистику для быстрой отдачи дашборду.
+func (d *Database) SaveStatisticsSnapshot(ctx context.Context, stats models.Statistics) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	data, err := json.Marshal(stats)
+	if err != nil {
+		return err
+	}
+	if _, err := d.db.ExecContext(ctx, "INSERT INTO statistics_snapshots (data) VALUES ($1)", data); err != nil {
+		return err
+	}
+	// Храним только последние снимки
+	_, err = d.db.ExecContext(ctx, `DELETE FROM statistics_snapshots WHERE id NOT IN (
+		SELECT id FROM statistics_snapshots ORDER BY taken_at DESC LIMIT 100)`)
+	return err
+}
+
+// GetStatisticsSnapshot возвращает последний снимок не старше maxAge; ok == false, если такого нет.
+func (d *Database) GetStatisticsSnapshot(ctx context.Context, maxAge time.Duration) (stats models.Statistics, ok bool, err error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var data []byte
+	err = d.db.QueryRowContext(ctx, `
+		SELECT data FROM statistics_snapshots
+		WHERE taken_at > now() - make_interval(secs => $1)
+		ORDER BY taken_at DESC LIMIT 1`, maxAge.Seconds()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return stats, false, nil
+	}
+	if err != nil {
+		return stats, false, err
+	}
+
+	return stats, true, json.Unmarshal(data, &stats)
+}
+
+// GetCirculationCounts считает открытые просроченные выдачи и ожидающие брони.
+func (d *Database) GetCirculationCounts(ctx context.Context) (models.CirculationCounts, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var counts models.CirculationCounts
+	err := d.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM loans WHERE return_date IS NULL AND due_date < CURRENT_DATE),
+			(SELECT COUNT(*) FROM holds WHERE status = $1)`, models.HoldWaiting).Scan(&counts.OverdueLoans, &counts.HoldsWaiting)
+	return counts, err
+}
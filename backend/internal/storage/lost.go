@@ -0,0 +1,192 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// DeclareLoanCopy закрывает займ как утерю или порчу экземпляра: экземпляр получает
+// статус lost/damaged, а на счет читателя начисляется стоимость замены — цена экземпляра
+// или, если она не указана, стоимость по умолчанию для категории книги. Закрытие займа
+// публикуется как loan.returned с outcome, как и обычный возврат.
+func (d *Database) DeclareLoanCopy(ctx context.Context, loanID int, outcome string) (models.Fine, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	defer tx.Rollback()
+
+	var userID int
+	var copyID *int
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, copy_id FROM loans
+		WHERE id = $1 AND return_date IS NULL
+		FOR UPDATE`, loanID).Scan(&userID, &copyID)
+	if err == sql.ErrNoRows {
+		return models.Fine{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if copyID == nil {
+		// Займы без экземпляра (книги, заведенные до учета экземпляров) не по чему оценить
+		return models.Fine{}, ErrInvalidState
+	}
+
+	var cost *float64
+	err = tx.QueryRowContext(ctx, `
+		SELECT COALESCE(copies.price, replacement_costs.amount)
+		FROM copies
+		JOIN books ON books.id = copies.book_id
+		LEFT JOIN replacement_costs ON replacement_costs.category = books.category
+		WHERE copies.id = $1`, *copyID).Scan(&cost)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if cost == nil {
+		return models.Fine{}, ErrNoReplacementCost
+	}
+
+	copyStatus := models.CopyLost
+	if outcome == models.LoanDamaged {
+		copyStatus = models.CopyDamaged
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE loans SET return_date = $1, outcome = $2 WHERE id = $3", time.Now(), outcome, loanID); err != nil {
+		return models.Fine{}, err
+	}
+	if err := countLoanStats(ctx, tx, loanID, true); err != nil {
+		return models.Fine{}, err
+	}
+	if _, err := tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", copyStatus, *copyID); err != nil {
+		return models.Fine{}, err
+	}
+
+	loan, err := scanLoan(tx.QueryRowContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE id = $1`, loanID))
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if err := recordEvent(ctx, tx, models.EventLoanReturned, loan); err != nil {
+		return models.Fine{}, err
+	}
+
+	fine := models.Fine{UserID: userID, LoanID: &loanID, Amount: *cost, Reason: models.FineReplacement}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO fines (user_id, loan_id, amount, reason) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, fine.UserID, fine.LoanID, fine.Amount, fine.Reason).Scan(&fine.ID, &fine.CreatedAt)
+	if err != nil {
+		return models.Fine{}, err
+	}
+
+	return fine, tx.Commit()
+}
+
+// MarkLoanFound отменяет утерю: экземпляр возвращается в оборот (или под ожидающую бронь),
+// а начисленная стоимость замены возвращается на счет читателя отрицательной записью.
+// Отменить можно только утерю, и только пока экземпляр все еще числится утерянным: если
+// его успели списать, он не возвращается в оборот. Поврежденный экземпляр читатель сдал,
+// искать его не нужно.
+func (d *Database) MarkLoanFound(ctx context.Context, loanID int) (models.Fine, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	defer tx.Rollback()
+
+	var userID, copyID int
+	err = tx.QueryRowContext(ctx, `
+		SELECT user_id, copy_id FROM loans
+		WHERE id = $1 AND outcome = $2
+		FOR UPDATE`, loanID, models.LoanLost).Scan(&userID, &copyID)
+	if err == sql.ErrNoRows {
+		return models.Fine{}, ErrInvalidState
+	}
+	if err != nil {
+		return models.Fine{}, err
+	}
+
+	var copyStatus string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM copies WHERE id = $1 FOR UPDATE", copyID).Scan(&copyStatus)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	if copyStatus != models.CopyLost {
+		return models.Fine{}, ErrInvalidState
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE loans SET outcome = $1 WHERE id = $2", models.LoanFound, loanID); err != nil {
+		return models.Fine{}, err
+	}
+	if err := d.releaseCopy(ctx, tx, copyID); err != nil {
+		return models.Fine{}, err
+	}
+	rows, err := tx.QueryContext(ctx, "SELECT "+copyColumns+" FROM copies WHERE id = $1", copyID)
+	if err != nil {
+		return models.Fine{}, err
+	}
+	found, err := scanCopies(rows)
+	rows.Close()
+	if err != nil {
+		return models.Fine{}, err
+	}
+	for _, c := range found {
+		if err := recordEvent(ctx, tx, models.EventCopyFound, c); err != nil {
+			return models.Fine{}, err
+		}
+	}
+
+	fine := models.Fine{UserID: userID, LoanID: &loanID, Reason: models.FineReplacementRefund}
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO fines (user_id, loan_id, amount, reason)
+		SELECT $1, $2, -COALESCE(SUM(amount), 0), $3 FROM fines
+		WHERE loan_id = $2 AND reason IN ($4, $3)
+		RETURNING id, amount, created_at`,
+		fine.UserID, loanID, fine.Reason, models.FineReplacement).Scan(&fine.ID, &fine.Amount, &fine.CreatedAt)
+	if err != nil {
+		return models.Fine{}, err
+	}
+
+	return fine, tx.Commit()
+}
+
+// Операции для стоимости замены по категориям
+
+func (d *Database) GetReplacementCosts(ctx context.Context) ([]models.ReplacementCost, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT category, amount FROM replacement_costs ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var costs []models.ReplacementCost
+	for rows.Next() {
+		var cost models.ReplacementCost
+		if err := rows.Scan(&cost.Category, &cost.Amount); err != nil {
+			return nil, err
+		}
+		costs = append(costs, cost)
+	}
+
+	return costs, rows.Err()
+}
+
+func (d *Database) SetReplacementCost(ctx context.Context, cost models.ReplacementCost) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO replacement_costs (category, amount) VALUES ($1, $2)
+		ON CONFLICT (category) DO UPDATE SET amount = EXCLUDED.amount`, cost.Category, cost.Amount)
+	return err
+}
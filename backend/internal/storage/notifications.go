@@ -0,0 +1,188 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+)
+
+// Операции для уведомлений
+
+// GetNotificationPreferences возвращает настройки уведомлений читателя; если читатель
+// их не менял, возвращаются настройки по умолчанию.
+func (d *Database) GetNotificationPreferences(ctx context.Context, userID int) (models.NotificationPreferences, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	prefs := models.NotificationPreferences{UserID: userID}
+	err := d.db.QueryRowContext(ctx, `
+		SELECT COALESCE(p.language, $2), COALESCE(p.overdue, true), COALESCE(p.hold_ready, true)
+		FROM users LEFT JOIN notification_preferences p ON p.user_id = users.id
+		WHERE users.id = $1`, userID, models.LanguageRussian).Scan(&prefs.Language, &prefs.Overdue, &prefs.HoldReady)
+	if err == sql.ErrNoRows {
+		return models.NotificationPreferences{}, ErrNotFound
+	}
+	if err != nil {
+		return models.NotificationPreferences{}, err
+	}
+	return prefs, nil
+}
+
+func (d *Database) SetNotificationPreferences(ctx context.Context, prefs models.NotificationPreferences) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var found bool
+	if err := d.db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", prefs.UserID).Scan(&found); err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO notification_preferences (user_id, language, overdue, hold_ready) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET language = EXCLUDED.language, overdue = EXCLUDED.overdue, hold_ready = EXCLUDED.hold_ready`,
+		prefs.UserID, prefs.Language, prefs.Overdue, prefs.HoldReady)
+	return err
+}
+
+// GetPendingNotices находит события, о которых читателям еще не сообщили по каналу
+// channel: просроченные займы и брони, готовые к выдаче. Читатели без email и
+// отказавшиеся от уведомления такого вида пропускаются.
+func (d *Database) GetPendingNotices(ctx context.Context, channel string) ([]models.Notice, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT $1::text, 'overdue:' || loans.id, users.id, users.name, users.email, COALESCE(p.language, $3),
+			books.title, books.author, loans.due_date, ''
+		FROM loans
+		JOIN users ON users.id = loans.user_id
+		JOIN books ON books.id = loans.book_id
+		LEFT JOIN notification_preferences p ON p.user_id = users.id
+		WHERE loans.return_date IS NULL AND loans.overdue
+			AND users.email <> '' AND COALESCE(p.overdue, true)
+			AND NOT EXISTS (SELECT 1 FROM notifications n WHERE n.channel = $4 AND n.dedup_key = 'overdue:' || loans.id)
+		UNION ALL
+		SELECT $2::text, 'hold_ready:' || holds.id, users.id, users.name, users.email, COALESCE(p.language, $3),
+			books.title, books.author, NULL, branches.name
+		FROM holds
+		JOIN users ON users.id = holds.user_id
+		JOIN books ON books.id = holds.book_id
+		JOIN branches ON branches.id = holds.pickup_branch_id
+		LEFT JOIN notification_preferences p ON p.user_id = users.id
+		WHERE holds.status = $5
+			AND users.email <> '' AND COALESCE(p.hold_ready, true)
+			AND NOT EXISTS (SELECT 1 FROM notifications n WHERE n.channel = $4 AND n.dedup_key = 'hold_ready:' || holds.id)`,
+		models.NotifyOverdue, models.NotifyHoldReady, models.LanguageRussian, channel, models.HoldReady)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notices []models.Notice
+	for rows.Next() {
+		var n models.Notice
+		var dueDate sql.NullTime
+		if err := rows.Scan(&n.Kind, &n.DedupKey, &n.UserID, &n.Name, &n.Email, &n.Language,
+			&n.Title, &n.Author, &dueDate, &n.Branch); err != nil {
+			return nil, err
+		}
+		n.DueDate = dueDate.Time
+		notices = append(notices, n)
+	}
+
+	return notices, rows.Err()
+}
+
+// EnqueueNotification кладет сообщение в очередь. Если сообщение о том же событии по
+// тому же каналу уже есть, ничего не делает и возвращает false.
+func (d *Database) EnqueueNotification(ctx context.Context, n models.Notification) (bool, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, `
+		INSERT INTO notifications (user_id, channel, kind, dedup_key, recipient, subject, text_body, html_body, status)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (channel, dedup_key) DO NOTHING`,
+		n.UserID, n.Channel, n.Kind, n.DedupKey, n.Recipient, n.Subject, n.Text, n.HTML, models.NotificationPending)
+	if err != nil {
+		return false, err
+	}
+	added, _ := res.RowsAffected()
+	return added > 0, nil
+}
+
+const notificationColumns = `id, user_id, channel, kind, dedup_key, recipient, subject, text_body, html_body,
+	status, attempts, last_error, created_at, sent_at`
+
+// GetNotifications возвращает сообщения из очереди с необязательными фильтрами по статусу и читателю.
+func (d *Database) GetNotifications(ctx context.Context, status string, userID, limit int) ([]models.Notification, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+notificationColumns+` FROM notifications
+		WHERE ($1 = '' OR status = $1) AND ($2 = 0 OR user_id = $2)
+		ORDER BY created_at DESC
+		LIMIT $3`, status, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+// GetOutgoingNotifications возвращает самые старые неотправленные сообщения канала.
+func (d *Database) GetOutgoingNotifications(ctx context.Context, channel string, limit int) ([]models.Notification, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+notificationColumns+` FROM notifications
+		WHERE status = $1 AND channel = $2
+		ORDER BY created_at
+		LIMIT $3`, models.NotificationPending, channel, limit)
+	if err != nil {
+		return nil, err
+	}
+	return scanNotifications(rows)
+}
+
+func scanNotifications(rows *sql.Rows) ([]models.Notification, error) {
+	defer rows.Close()
+
+	var notifications []models.Notification
+	for rows.Next() {
+		var n models.Notification
+		if err := rows.Scan(&n.ID, &n.UserID, &n.Channel, &n.Kind, &n.DedupKey, &n.Recipient, &n.Subject, &n.Text, &n.HTML,
+			&n.Status, &n.Attempts, &n.LastError, &n.CreatedAt, &n.SentAt); err != nil {
+			return nil, err
+		}
+		notifications = append(notifications, n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (d *Database) MarkNotificationSent(ctx context.Context, id int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	_, err := d.db.ExecContext(ctx, "UPDATE notifications SET status = $1, attempts = attempts + 1, last_error = NULL, sent_at = now() WHERE id = $2",
+		models.NotificationSent, id)
+	return err
+}
+
+// MarkNotificationFailed записывает неудачную попытку отправки; после maxAttempts
+// попыток сообщение помечается failed и больше не отправляется.
+func (d *Database) MarkNotificationFailed(ctx context.Context, id int, sendErr error, maxAttempts int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	_, err := d.db.ExecContext(ctx, `
+		UPDATE notifications SET attempts = attempts + 1, last_error = $1,
+			status = CASE WHEN attempts + 1 >= $2 THEN $3 ELSE status END
+		WHERE id = $4`, sendErr.Error(), maxAttempts, models.NotificationFailed, id)
+	return err
+}
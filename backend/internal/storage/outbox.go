@@ -0,0 +1,144 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+)
+
+// outboxSettled — условие «событие записано завершенной транзакцией, и все транзакции
+// с меньшим номером тоже завершены». Номера транзакций меньше xmin текущего снимка уже
+// не выдаются и не выполняются, поэтому позади позиции такого события новые события
+// появиться не могут. Долгая транзакция в базе задерживает доставку, но не теряет событий.
+const outboxSettled = `outbox.tx_id < pg_snapshot_xmin(pg_current_snapshot())::text::bigint`
+
+// recordEvent записывает доменное событие в outbox в транзакции изменения, так что
+// событие фиксируется тогда и только тогда, когда фиксируется само изменение. Номер
+// транзакции (tx_id) проставляется по умолчанию и задает порядок доставки.
+func recordEvent(ctx context.Context, tx *sql.Tx, eventType string, data any) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, "INSERT INTO outbox (type, data) VALUES ($1, $2)", eventType, payload)
+	return err
+}
+
+// GetOutboxEvents возвращает события после позиции after в порядке транзакций. События
+// еще не завершенных транзакций (и всех, что идут после них) не возвращаются.
+func (d *Database) GetOutboxEvents(ctx context.Context, after models.OutboxPosition, limit int) ([]models.OutboxEvent, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, tx_id, type, data, created_at FROM outbox
+		WHERE (tx_id, id) > ($1, $2) AND `+outboxSettled+`
+		ORDER BY tx_id, id LIMIT $3`, after.TxID, after.EventID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.OutboxEvent
+	for rows.Next() {
+		var e models.OutboxEvent
+		if err := rows.Scan(&e.ID, &e.TxID, &e.Type, &e.Data, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, rows.Err()
+}
+
+// GetConsumerOffset возвращает позицию последнего обработанного подписчиком события.
+// Новый подписчик начинает с текущего конца outbox и не получает историю.
+func (d *Database) GetConsumerOffset(ctx context.Context, consumer string) (models.OutboxPosition, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	head, err := d.GetOutboxHead(ctx)
+	if err != nil {
+		return models.OutboxPosition{}, err
+	}
+	_, err = d.db.ExecContext(ctx, `
+		INSERT INTO consumer_offsets (consumer, last_tx_id, last_event_id) VALUES ($1, $2, $3)
+		ON CONFLICT (consumer) DO NOTHING`, consumer, head.TxID, head.EventID)
+	if err != nil {
+		return models.OutboxPosition{}, err
+	}
+
+	var offset models.OutboxPosition
+	err = d.db.QueryRowContext(ctx, "SELECT last_tx_id, last_event_id FROM consumer_offsets WHERE consumer = $1", consumer).
+		Scan(&offset.TxID, &offset.EventID)
+	return offset, err
+}
+
+func (d *Database) SetConsumerOffset(ctx context.Context, consumer string, pos models.OutboxPosition) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	_, err := d.db.ExecContext(ctx, "UPDATE consumer_offsets SET last_tx_id = $1, last_event_id = $2, updated_at = now() WHERE consumer = $3",
+		pos.TxID, pos.EventID, consumer)
+	return err
+}
+
+// GetConsumerOffsets возвращает смещения подписчиков и число еще не обработанных ими событий.
+func (d *Database) GetConsumerOffsets(ctx context.Context) ([]models.ConsumerOffset, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT consumer, last_event_id, (SELECT COUNT(*) FROM outbox WHERE (outbox.tx_id, outbox.id) > (consumer_offsets.last_tx_id, consumer_offsets.last_event_id)), updated_at
+		FROM consumer_offsets ORDER BY consumer`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var offsets []models.ConsumerOffset
+	for rows.Next() {
+		var o models.ConsumerOffset
+		if err := rows.Scan(&o.Consumer, &o.LastEventID, &o.Lag, &o.UpdatedAt); err != nil {
+			return nil, err
+		}
+		offsets = append(offsets, o)
+	}
+
+	return offsets, rows.Err()
+}
+
+// PruneOutbox удаляет события старше retention, уже обработанные всеми подписчиками.
+func (d *Database) PruneOutbox(ctx context.Context, retention time.Duration) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, `
+		DELETE FROM outbox
+		WHERE created_at < now() - make_interval(secs => $1)
+			AND EXISTS (SELECT 1 FROM consumer_offsets)
+			AND (tx_id, id) <= ALL (SELECT last_tx_id, last_event_id FROM consumer_offsets)`, retention.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// GetOutboxHead возвращает позицию последнего события завершенных транзакций (нулевую,
+// если таких нет).
+func (d *Database) GetOutboxHead(ctx context.Context) (models.OutboxPosition, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var head models.OutboxPosition
+	err := d.db.QueryRowContext(ctx, `
+		SELECT tx_id, id FROM outbox WHERE `+outboxSettled+`
+		ORDER BY tx_id DESC, id DESC LIMIT 1`).Scan(&head.TxID, &head.EventID)
+	if err == sql.ErrNoRows {
+		return models.OutboxPosition{}, nil
+	}
+	return head, err
+}
@@ -0,0 +1,199 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/lib/pq"
+)
+
+// userColumns — поля читателя; BorrowedBooks собирается из открытых займов через userBorrowedJoin.
+const userColumns = `users.id, users.name, users.email, users.card_number, users.category, users.phone, users.address,
+	users.registered_at, users.expires_at, users.status, users.status_reason, users.guardian_id,
+	COALESCE(array_agg(books.title ORDER BY loans.borrow_date) FILTER (WHERE books.id IS NOT NULL), '{}')`
+
+const userBorrowedJoin = `
+	FROM users
+	LEFT JOIN loans ON loans.user_id = users.id AND loans.return_date IS NULL
+	LEFT JOIN books ON books.id = loans.book_id`
+
+// rowScanner общий интерфейс для *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// queryRower общий интерфейс для *sql.DB и *sql.Tx
+type queryRower interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var user models.User
+	err := row.Scan(&user.ID, &user.Name, &user.Email, &user.CardNumber, &user.Category, &user.Phone, &user.Address,
+		&user.RegisteredAt, &user.ExpiresAt, &user.Status, &user.StatusReason, &user.GuardianID, pq.Array(&user.BorrowedBooks))
+	return user, err
+}
+
+// getUser читает одного читателя по условию where
+func getUser(ctx context.Context, q queryRower, where string, args ...any) (models.User, error) {
+	user, err := scanUser(q.QueryRowContext(ctx, `SELECT `+userColumns+userBorrowedJoin+` WHERE `+where+` GROUP BY users.id`, args...))
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrNotFound
+	}
+	return user, err
+}
+
+// CardNumber формирует номер читательского билета по id: буквы и цифры, пригодные для Code 39.
+func CardNumber(userID int) string {
+	return fmt.Sprintf("LIB%08d", userID)
+}
+
+func (d *Database) GetUser(ctx context.Context, id int) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	return getUser(ctx, d.db, "users.id = $1", id)
+}
+
+func (d *Database) GetUserByCard(ctx context.Context, cardNumber string) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	return getUser(ctx, d.db, "users.card_number = $1", cardNumber)
+}
+
+// RenewUser продлевает билет на срок из политики категории, считая от текущей даты
+// окончания или от сегодняшнего дня, если билет уже истек.
+func (d *Database) RenewUser(ctx context.Context, id int) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE users SET expires_at = (GREATEST(users.expires_at, CURRENT_DATE) + make_interval(months => loan_policies.membership_months))::date
+		FROM loan_policies
+		WHERE loan_policies.category = users.category AND users.id = $1`, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.User{}, ErrNotFound
+	}
+
+	return d.GetUser(ctx, id)
+}
+
+// SetUserStatus блокирует, приостанавливает или разблокирует читателя с указанием причины.
+func (d *Database) SetUserStatus(ctx context.Context, id int, status, reason string) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "UPDATE users SET status = $1, status_reason = $2 WHERE id = $3", status, reason, id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return models.User{}, ErrNotFound
+	}
+
+	return d.GetUser(ctx, id)
+}
+
+// Операции для политик выдачи
+
+func (d *Database) GetLoanPolicies(ctx context.Context) ([]models.LoanPolicy, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT category, max_loans, loan_days, membership_months, daily_fine, restricted_categories FROM loan_policies ORDER BY category")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []models.LoanPolicy
+	for rows.Next() {
+		var policy models.LoanPolicy
+		if err := rows.Scan(&policy.Category, &policy.MaxLoans, &policy.LoanDays, &policy.MembershipMonths, &policy.DailyFine, pq.Array(&policy.RestrictedCategories)); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, rows.Err()
+}
+
+func (d *Database) SetLoanPolicy(ctx context.Context, policy models.LoanPolicy) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	_, err := d.db.ExecContext(ctx, `
+		INSERT INTO loan_policies (category, max_loans, loan_days, membership_months, daily_fine, restricted_categories)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (category) DO UPDATE
+		SET max_loans = EXCLUDED.max_loans, loan_days = EXCLUDED.loan_days, membership_months = EXCLUDED.membership_months,
+			daily_fine = EXCLUDED.daily_fine, restricted_categories = EXCLUDED.restricted_categories`,
+		policy.Category, policy.MaxLoans, policy.LoanDays, policy.MembershipMonths, policy.DailyFine, pq.Array(policy.RestrictedCategories))
+	return err
+}
+
+// checkLoanPolicy проверяет, что читатель может взять эту книгу, и возвращает срок возврата.
+// Строка читателя блокируется, чтобы параллельные выдачи не превысили лимит.
+func (d *Database) checkLoanPolicy(ctx context.Context, tx *sql.Tx, userID, bookID int) (string, error) {
+	// Даты считаются в базе по CURRENT_DATE, как и во всех остальных проверках сроков,
+	// чтобы не зависеть от часового пояса сервера приложения
+	var status, dueDate string
+	var expired bool
+	var maxLoans, openLoans int
+	err := tx.QueryRowContext(ctx, `
+		SELECT users.status, users.expires_at < CURRENT_DATE, loan_policies.max_loans,
+			to_char(CURRENT_DATE + loan_policies.loan_days, 'YYYY-MM-DD'),
+			(SELECT COUNT(*) FROM loans WHERE loans.user_id = users.id AND loans.return_date IS NULL)
+		FROM users
+		JOIN loan_policies ON loan_policies.category = users.category
+		WHERE users.id = $1
+		FOR UPDATE OF users`, userID).Scan(&status, &expired, &maxLoans, &dueDate, &openLoans)
+	if err == sql.ErrNoRows {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case status != models.UserActive:
+		return "", ErrPatronBlocked
+	case expired:
+		return "", ErrMembershipExpired
+	case openLoans >= maxLoans:
+		return "", ErrLoanLimit
+	}
+
+	if err := checkCategoryRestriction(ctx, tx, userID, bookID); err != nil {
+		return "", err
+	}
+
+	return dueDate, nil
+}
+
+// checkCategoryRestriction проверяет, что категория книги не закрыта для категории читателя
+// (например, «взрослые» разделы для детских билетов).
+func checkCategoryRestriction(ctx context.Context, q queryRower, userID, bookID int) error {
+	var restricted bool
+	err := q.QueryRowContext(ctx, `
+		SELECT books.category = ANY(loan_policies.restricted_categories)
+		FROM users
+		JOIN loan_policies ON loan_policies.category = users.category
+		CROSS JOIN books
+		WHERE users.id = $1 AND books.id = $2`, userID, bookID).Scan(&restricted)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if restricted {
+		return ErrRestrictedCategory
+	}
+	return nil
+}
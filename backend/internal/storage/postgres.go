@@ -2,31 +2,116 @@ package storage
 
 import (
 	"cmd/main.go/models"
+	"cmd/main.go/pkg/logger"
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	_ "github.com/lib/pq" // Подключаем драйвер PostgreSQL
+	"github.com/lib/pq" // Подключаем драйвер PostgreSQL
+	"go.uber.org/zap"
 	"strconv"
 	"time"
 )
 
+var (
+	// ErrNotFound возвращается, когда запись не найдена или уже в конечном состоянии.
+	ErrNotFound = errors.New("not found")
+	// ErrNoCopyAvailable возвращается, когда нет свободного экземпляра книги.
+	ErrNoCopyAvailable = errors.New("no available copy")
+	// ErrInvalidState возвращается при недопустимом переходе статуса.
+	ErrInvalidState = errors.New("invalid state transition")
+	// ErrUnknownCategory возвращается для категории читателя без политики выдачи.
+	ErrUnknownCategory = errors.New("unknown patron category")
+	// ErrPatronBlocked возвращается, если читатель заблокирован или приостановлен.
+	ErrPatronBlocked = errors.New("patron account is blocked")
+	// ErrMembershipExpired возвращается, если срок действия билета истек.
+	ErrMembershipExpired = errors.New("membership expired")
+	// ErrLoanLimit возвращается, если читатель исчерпал лимит выдач своей категории.
+	ErrLoanLimit = errors.New("loan limit reached")
+	// ErrRestrictedCategory возвращается, если категория книги закрыта для категории читателя.
+	ErrRestrictedCategory = errors.New("book category is restricted for this patron")
+	// ErrGuardianRequired возвращается для детского билета без ответственного взрослого.
+	ErrGuardianRequired = errors.New("child patrons must have an adult guardian")
+	// ErrInvalidGuardian возвращается, если ответственный не найден, не активен или сам ребенок.
+	ErrInvalidGuardian = errors.New("guardian must be an active adult patron")
+	// ErrSelfGuardian возвращается при попытке назначить читателя ответственным за самого себя.
+	ErrSelfGuardian = errors.New("patron cannot be their own guardian")
+	// ErrNoReplacementCost возвращается, если не задана ни цена экземпляра, ни стоимость для категории.
+	ErrNoReplacementCost = errors.New("no replacement cost for copy or category")
+)
+
 type Database struct {
-	db *sql.DB
+	db           *sql.DB
+	queryTimeout time.Duration
 }
 
-// NewDatabase инициализирует подключение к базе данных PostgreSQL.
-func NewDatabase(dataSourceName string) Database {
+// Options задает пул соединений и ограничение времени запросов. Нулевые значения
+// оставляют настройки database/sql по умолчанию (без ограничений).
+type Options struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+	// QueryTimeout ограничивает один вызов метода Database, включая транзакцию целиком.
+	// Миграции и пересчет агрегатов им не ограничены.
+	QueryTimeout time.Duration
+}
+
+const (
+	// connectBackoff — пауза перед повторным подключением; удваивается до connectMaxBackoff
+	connectBackoff    = 500 * time.Millisecond
+	connectMaxBackoff = 30 * time.Second
+	// pingTimeout ограничивает одну попытку подключения
+	pingTimeout = 5 * time.Second
+)
+
+// NewDatabase подключается к PostgreSQL. Пока база недоступна (например, ее контейнер
+// еще стартует), подключение повторяется с экспоненциальной задержкой до отмены ctx.
+func NewDatabase(ctx context.Context, dataSourceName string, opts Options) (Database, error) {
 	db, err := sql.Open("postgres", dataSourceName)
 	if err != nil {
-		fmt.Println("Error opening database:", err)
-		return Database{}
+		return Database{}, err
+	}
+	db.SetMaxOpenConns(opts.MaxOpenConns)
+	if opts.MaxIdleConns > 0 {
+		db.SetMaxIdleConns(opts.MaxIdleConns)
 	}
+	db.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
+
+	log := logger.FromContext(ctx)
+	backoff := connectBackoff
+	for attempt := 1; ; attempt++ {
+		pingCtx, cancel := context.WithTimeout(ctx, pingTimeout)
+		err = db.PingContext(pingCtx)
+		cancel()
+		if err == nil {
+			return Database{db: db, queryTimeout: opts.QueryTimeout}, nil
+		}
 
-	if err = db.Ping(); err != nil {
-		panic(fmt.Sprintf("Error pinging database:", err))
-		return Database{}
+		log.Warn("Database is not available, retrying", zap.Int("attempt", attempt), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			db.Close()
+			return Database{}, fmt.Errorf("connect to database: %w", err)
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, connectMaxBackoff)
 	}
+}
 
-	return Database{db: db}
+// withTimeout ограничивает ctx временем QueryTimeout. Отмена ctx (например, клиент закрыл
+// соединение) прерывает запрос: lib/pq отправляет серверу запрос отмены.
+func (d *Database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if d.queryTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, d.queryTimeout)
+}
+
+// Ping проверяет, что база отвечает.
+func (d *Database) Ping(ctx context.Context) error {
+	return d.db.PingContext(ctx)
 }
 
 // Close закрывает подключение к базе данных.
@@ -34,44 +119,372 @@ func (d *Database) Close() error {
 	return d.db.Close()
 }
 
-// Migrate применяет схему для приложения.
-func (d *Database) Migrate() error {
-	queries := []string{
-		`CREATE TABLE IF NOT EXISTS books (
-			id SERIAL PRIMARY KEY,
-			title TEXT NOT NULL,
-			author TEXT NOT NULL,
-			category TEXT NOT NULL
-		);`,
-		`CREATE TABLE IF NOT EXISTS users (
-			id SERIAL PRIMARY KEY,
-			name TEXT NOT NULL,
-			email TEXT NOT NULL UNIQUE
-		);`,
-		`CREATE TABLE IF NOT EXISTS loans (
-			id SERIAL PRIMARY KEY,
-			user_id INT NOT NULL,
-			book_id INT NOT NULL,
-			borrow_date DATE NOT NULL,
-			return_date DATE,
-			FOREIGN KEY (user_id) REFERENCES users(id),
-			FOREIGN KEY (book_id) REFERENCES books(id)
-		);`,
-	}
-
-	for _, query := range queries {
-		if _, err := d.db.Exec(query); err != nil {
+// Stats возвращает состояние пула соединений.
+func (d *Database) Stats() sql.DBStats {
+	return d.db.Stats()
+}
+
+// migrationLockKey — ключ транзакционной advisory-блокировки миграций
+const migrationLockKey = 0x6d696772617465 // "migrate"
+
+// migrations — схема приложения. Миграции только дописываются в конец списка: номер
+// миграции — ее позиция, версия схемы — число примененных миграций.
+var migrations = []string{
+	`CREATE TABLE IF NOT EXISTS books (
+		id SERIAL PRIMARY KEY,
+		title TEXT NOT NULL,
+		author TEXT NOT NULL,
+		category TEXT NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS users (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		email TEXT NOT NULL UNIQUE
+	);`,
+	`CREATE TABLE IF NOT EXISTS loans (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL,
+		book_id INT NOT NULL,
+		borrow_date DATE NOT NULL,
+		return_date DATE,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (book_id) REFERENCES books(id)
+	);`,
+	`CREATE TABLE IF NOT EXISTS branches (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		address TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE IF NOT EXISTS copies (
+		id SERIAL PRIMARY KEY,
+		book_id INT NOT NULL REFERENCES books(id) ON DELETE CASCADE,
+		barcode TEXT NOT NULL UNIQUE,
+		home_branch_id INT NOT NULL REFERENCES branches(id),
+		current_branch_id INT NOT NULL REFERENCES branches(id),
+		status TEXT NOT NULL DEFAULT 'available'
+	);`,
+	`ALTER TABLE loans
+		ADD COLUMN IF NOT EXISTS copy_id INT REFERENCES copies(id),
+		ADD COLUMN IF NOT EXISTS branch_id INT REFERENCES branches(id);`,
+	`CREATE TABLE IF NOT EXISTS holds (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id),
+		book_id INT NOT NULL REFERENCES books(id),
+		pickup_branch_id INT NOT NULL REFERENCES branches(id),
+		copy_id INT REFERENCES copies(id),
+		status TEXT NOT NULL DEFAULT 'waiting',
+		created_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`CREATE TABLE IF NOT EXISTS transfers (
+		id SERIAL PRIMARY KEY,
+		copy_id INT NOT NULL REFERENCES copies(id),
+		from_branch_id INT NOT NULL REFERENCES branches(id),
+		to_branch_id INT NOT NULL REFERENCES branches(id),
+		hold_id INT REFERENCES holds(id),
+		status TEXT NOT NULL DEFAULT 'requested',
+		requested_at TIMESTAMP NOT NULL DEFAULT now(),
+		shipped_at TIMESTAMP,
+		received_at TIMESTAMP
+	);`,
+	`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS card_number TEXT UNIQUE,
+		ADD COLUMN IF NOT EXISTS category TEXT NOT NULL DEFAULT 'adult',
+		ADD COLUMN IF NOT EXISTS phone TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS address TEXT NOT NULL DEFAULT '',
+		ADD COLUMN IF NOT EXISTS registered_at DATE NOT NULL DEFAULT CURRENT_DATE,
+		ADD COLUMN IF NOT EXISTS expires_at DATE NOT NULL DEFAULT (CURRENT_DATE + INTERVAL '1 year')::date,
+		ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'active',
+		ADD COLUMN IF NOT EXISTS status_reason TEXT NOT NULL DEFAULT '';`,
+	`UPDATE users SET card_number = 'LIB' || lpad(id::text, 8, '0') WHERE card_number IS NULL;`,
+	`CREATE TABLE IF NOT EXISTS loan_policies (
+		category TEXT PRIMARY KEY,
+		max_loans INT NOT NULL,
+		loan_days INT NOT NULL,
+		membership_months INT NOT NULL
+	);`,
+	`INSERT INTO loan_policies (category, max_loans, loan_days, membership_months) VALUES
+		('adult', 5, 21, 12),
+		('child', 3, 14, 12),
+		('student', 7, 28, 12),
+		('staff', 10, 42, 24)
+	ON CONFLICT (category) DO NOTHING;`,
+	`ALTER TABLE loans ADD COLUMN IF NOT EXISTS due_date DATE;`,
+	`ALTER TABLE users ADD COLUMN IF NOT EXISTS guardian_id INT REFERENCES users(id);`,
+	`ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS restricted_categories TEXT[] NOT NULL DEFAULT '{}';`,
+	`CREATE TABLE IF NOT EXISTS fines (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id),
+		loan_id INT REFERENCES loans(id),
+		amount NUMERIC(10, 2) NOT NULL,
+		reason TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`ALTER TABLE copies ADD COLUMN IF NOT EXISTS price NUMERIC(10, 2);`,
+	`ALTER TABLE loans ADD COLUMN IF NOT EXISTS outcome TEXT;`,
+	`CREATE TABLE IF NOT EXISTS replacement_costs (
+		category TEXT PRIMARY KEY,
+		amount NUMERIC(10, 2) NOT NULL
+	);`,
+	`ALTER TABLE copies ADD COLUMN IF NOT EXISTS shelf TEXT NOT NULL DEFAULT '';`,
+	`CREATE TABLE IF NOT EXISTS stocktakes (
+		id SERIAL PRIMARY KEY,
+		branch_id INT NOT NULL REFERENCES branches(id),
+		shelf_from TEXT NOT NULL DEFAULT '',
+		shelf_to TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'open',
+		opened_at TIMESTAMP NOT NULL DEFAULT now(),
+		closed_at TIMESTAMP
+	);`,
+	`CREATE TABLE IF NOT EXISTS stocktake_scans (
+		stocktake_id INT NOT NULL REFERENCES stocktakes(id) ON DELETE CASCADE,
+		barcode TEXT NOT NULL,
+		scanned_at TIMESTAMP NOT NULL DEFAULT now(),
+		PRIMARY KEY (stocktake_id, barcode)
+	);`,
+	`ALTER TABLE books ADD COLUMN IF NOT EXISTS isbn TEXT UNIQUE;`,
+	`CREATE TABLE IF NOT EXISTS vendors (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL UNIQUE,
+		email TEXT NOT NULL DEFAULT '',
+		phone TEXT NOT NULL DEFAULT ''
+	);`,
+	`CREATE TABLE IF NOT EXISTS funds (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		year INT NOT NULL,
+		budget NUMERIC(12, 2) NOT NULL,
+		UNIQUE (name, year)
+	);`,
+	`CREATE TABLE IF NOT EXISTS purchase_orders (
+		id SERIAL PRIMARY KEY,
+		vendor_id INT NOT NULL REFERENCES vendors(id),
+		fund_id INT NOT NULL REFERENCES funds(id),
+		status TEXT NOT NULL DEFAULT 'draft',
+		created_at TIMESTAMP NOT NULL DEFAULT now(),
+		ordered_at TIMESTAMP
+	);`,
+	`CREATE TABLE IF NOT EXISTS order_lines (
+		id SERIAL PRIMARY KEY,
+		order_id INT NOT NULL REFERENCES purchase_orders(id) ON DELETE CASCADE,
+		title TEXT NOT NULL,
+		author TEXT NOT NULL,
+		category TEXT NOT NULL,
+		isbn TEXT NOT NULL DEFAULT '',
+		quantity INT NOT NULL CHECK (quantity > 0),
+		unit_price NUMERIC(10, 2) NOT NULL,
+		received INT NOT NULL DEFAULT 0,
+		book_id INT REFERENCES books(id)
+	);`,
+	`ALTER TABLE copies
+		ADD COLUMN IF NOT EXISTS condition TEXT NOT NULL DEFAULT 'good',
+		ADD COLUMN IF NOT EXISTS acquired_at DATE NOT NULL DEFAULT CURRENT_DATE;`,
+	`CREATE TABLE IF NOT EXISTS withdrawals (
+		id SERIAL PRIMARY KEY,
+		copy_id INT NOT NULL UNIQUE REFERENCES copies(id),
+		book_id INT NOT NULL,
+		branch_id INT NOT NULL,
+		barcode TEXT NOT NULL,
+		title TEXT NOT NULL,
+		category TEXT NOT NULL,
+		reason TEXT NOT NULL,
+		total_loans INT NOT NULL,
+		withdrawn_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`ALTER TABLE holds ADD COLUMN IF NOT EXISTS ready_at TIMESTAMP;`,
+	`ALTER TABLE loans ADD COLUMN IF NOT EXISTS overdue BOOLEAN NOT NULL DEFAULT false;`,
+	`CREATE TABLE IF NOT EXISTS job_runs (
+		id SERIAL PRIMARY KEY,
+		job TEXT NOT NULL,
+		attempt INT NOT NULL,
+		status TEXT NOT NULL,
+		started_at TIMESTAMP NOT NULL DEFAULT now(),
+		finished_at TIMESTAMP,
+		error TEXT
+	);`,
+	`CREATE INDEX IF NOT EXISTS job_runs_job_started_idx ON job_runs (job, started_at DESC);`,
+	`CREATE TABLE IF NOT EXISTS statistics_snapshots (
+		id SERIAL PRIMARY KEY,
+		taken_at TIMESTAMP NOT NULL DEFAULT now(),
+		data JSONB NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS notification_preferences (
+		user_id INT PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+		language TEXT NOT NULL DEFAULT 'ru',
+		overdue BOOLEAN NOT NULL DEFAULT true,
+		hold_ready BOOLEAN NOT NULL DEFAULT true
+	);`,
+	`CREATE TABLE IF NOT EXISTS notifications (
+		id SERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		channel TEXT NOT NULL,
+		kind TEXT NOT NULL,
+		dedup_key TEXT NOT NULL,
+		recipient TEXT NOT NULL,
+		subject TEXT NOT NULL,
+		text_body TEXT NOT NULL,
+		html_body TEXT NOT NULL DEFAULT '',
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		last_error TEXT,
+		created_at TIMESTAMP NOT NULL DEFAULT now(),
+		sent_at TIMESTAMP,
+		UNIQUE (channel, dedup_key)
+	);`,
+	`CREATE INDEX IF NOT EXISTS notifications_pending_idx ON notifications (created_at) WHERE status = 'pending';`,
+	`CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		events TEXT[] NOT NULL,
+		secret TEXT NOT NULL,
+		active BOOLEAN NOT NULL DEFAULT true,
+		created_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`CREATE TABLE IF NOT EXISTS webhook_events (
+		id SERIAL PRIMARY KEY,
+		type TEXT NOT NULL,
+		data JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		subscription_id INT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+		event_id INT NOT NULL REFERENCES webhook_events(id),
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT now(),
+		created_at TIMESTAMP NOT NULL DEFAULT now(),
+		delivered_at TIMESTAMP
+	);`,
+	`CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';`,
+	`CREATE TABLE IF NOT EXISTS webhook_attempts (
+		id SERIAL PRIMARY KEY,
+		delivery_id INT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		attempted_at TIMESTAMP NOT NULL DEFAULT now(),
+		status_code INT,
+		error TEXT,
+		duration_ms INT NOT NULL
+	);`,
+	`CREATE TABLE IF NOT EXISTS outbox (
+		id BIGSERIAL PRIMARY KEY,
+		type TEXT NOT NULL,
+		data JSONB NOT NULL,
+		created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	);`,
+	`CREATE TABLE IF NOT EXISTS consumer_offsets (
+		consumer TEXT PRIMARY KEY,
+		last_event_id BIGINT NOT NULL,
+		updated_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`ALTER TABLE webhook_events ADD COLUMN IF NOT EXISTS outbox_id BIGINT UNIQUE;`,
+	`CREATE TABLE IF NOT EXISTS loan_stats_daily (
+		day DATE NOT NULL,
+		category TEXT NOT NULL,
+		branch_id INT NOT NULL,
+		patron_category TEXT NOT NULL,
+		issued INT NOT NULL DEFAULT 0,
+		returned INT NOT NULL DEFAULT 0,
+		PRIMARY KEY (day, category, branch_id, patron_category)
+	);`,
+	// Первичное заполнение: при пустой таблице сводки считаются по истории выдач
+	loanStatsFill,
+	`CREATE TABLE IF NOT EXISTS loan_contacts (
+		id SERIAL PRIMARY KEY,
+		loan_id INT NOT NULL REFERENCES loans(id),
+		method TEXT NOT NULL,
+		note TEXT NOT NULL DEFAULT '',
+		staff TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT now()
+	);`,
+	`CREATE INDEX IF NOT EXISTS loan_contacts_loan_idx ON loan_contacts (loan_id, created_at);`,
+	// Подписчик statistics-cache удален: снимок статистики устаревает по возрасту. Его
+	// смещение больше не сдвигается и иначе не дало бы чистить outbox.
+	`DELETE FROM consumer_offsets WHERE consumer = 'statistics-cache';`,
+	// Порядок outbox — по транзакции, записавшей событие (см. outboxSettled). Прежние
+	// события получают tx_id 0 и идут раньше новых, в порядке id, как и раньше.
+	`ALTER TABLE outbox ADD COLUMN IF NOT EXISTS tx_id BIGINT NOT NULL DEFAULT 0;`,
+	`ALTER TABLE outbox ALTER COLUMN tx_id SET DEFAULT pg_current_xact_id()::text::bigint;`,
+	`CREATE INDEX IF NOT EXISTS outbox_position_idx ON outbox (tx_id, id);`,
+	`ALTER TABLE consumer_offsets ADD COLUMN IF NOT EXISTS last_tx_id BIGINT NOT NULL DEFAULT 0;`,
+	`ALTER TABLE loan_policies ADD COLUMN IF NOT EXISTS daily_fine NUMERIC(10, 2) NOT NULL DEFAULT 10;`,
+}
+
+// SchemaVersion возвращает версию схемы, с которой работает этот код.
+//
+// Миграции только расширяют схему: новые таблицы, индексы и колонки со значением по
+// умолчанию. Поэтому код работает и с более новой схемой — при поэтапном обновлении
+// реплики предыдущей версии продолжают обслуживать запросы, пока их не заменят.
+func SchemaVersion() int {
+	return len(migrations)
+}
+
+// Migrate применяет недостающие миграции в одной транзакции. Реплики, стартующие
+// одновременно, применяют их по очереди; база, уже обновленная более новой версией
+// приложения, не трогается.
+//
+// Базы, созданные до учета версий, начинают с версии 0: все миграции идемпотентны,
+// поэтому повторное применение ничего не меняет.
+func (d *Database) Migrate(ctx context.Context) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", migrationLockKey); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INT PRIMARY KEY,
+		applied_at TIMESTAMP NOT NULL DEFAULT now()
+	);`)
+	if err != nil {
+		return err
+	}
+
+	var current int
+	if err := tx.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&current); err != nil {
+		return err
+	}
+	if current > len(migrations) {
+		logger.FromContext(ctx).Warn("Database schema is newer than this version of the application, leaving it as is",
+			zap.Int("schemaVersion", current), zap.Int("supportedVersion", len(migrations)))
+		return tx.Commit()
+	}
+
+	for i := current; i < len(migrations); i++ {
+		if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+			return fmt.Errorf("migration %d: %w", i+1, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", i+1); err != nil {
 			return err
 		}
 	}
 
-	return nil
+	return tx.Commit()
+}
+
+// GetSchemaVersion возвращает версию схемы базы — число примененных миграций.
+func (d *Database) GetSchemaVersion(ctx context.Context) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	var version int
+	err := d.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version)
+	return version, err
 }
 
 // CRUD операции для книг
 
-func (d *Database) GetBooks() ([]models.Book, error) {
-	rows, err := d.db.Query("SELECT id, title, author, category FROM books")
+// GetBooks возвращает книги; если branchID не 0 — только те, у которых есть экземпляры в филиале.
+func (d *Database) GetBooks(ctx context.Context, branchID int) ([]models.Book, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, title, author, category, isbn FROM books
+		WHERE $1 = 0 OR EXISTS (
+			SELECT 1 FROM copies WHERE copies.book_id = books.id AND copies.current_branch_id = $1
+		)`, branchID)
 	if err != nil {
 		return nil, err
 	}
@@ -80,34 +493,72 @@ func (d *Database) GetBooks() ([]models.Book, error) {
 	var books []models.Book
 	for rows.Next() {
 		var book models.Book
-		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category); err != nil {
+		if err := rows.Scan(&book.ID, &book.Title, &book.Author, &book.Category, &book.ISBN); err != nil {
 			return nil, err
 		}
 		books = append(books, book)
 	}
 
-	return books, nil
+	return books, rows.Err()
 }
 
-func (d *Database) AddBook(book models.Book) (int, error) {
-	var id int
-	err := d.db.QueryRow("INSERT INTO books (title, author, category) VALUES ($1, $2, $3) RETURNING id", book.Title, book.Author, book.Category).Scan(&id)
+func (d *Database) AddBook(ctx context.Context, book models.Book) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
 	if err != nil {
 		return 0, err
 	}
+	defer tx.Rollback()
 
-	return id, nil
+	err = tx.QueryRowContext(ctx, "INSERT INTO books (title, author, category, isbn) VALUES ($1, $2, $3, $4) RETURNING id", book.Title, book.Author, book.Category, book.ISBN).Scan(&book.ID)
+	if err != nil {
+		return 0, err
+	}
+	if err := recordEvent(ctx, tx, models.EventBookAdded, book); err != nil {
+		return 0, err
+	}
+
+	return book.ID, tx.Commit()
 }
 
-func (d *Database) DeleteBook(id int) error {
-	_, err := d.db.Exec("DELETE FROM books WHERE id = $1", id)
-	return err
+func (d *Database) DeleteBook(ctx context.Context, id int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	return d.deleteEntity(ctx, "books", models.EventBookDeleted, id)
+}
+
+// deleteEntity удаляет строку таблицы и, если она была, записывает событие удаления
+func (d *Database) deleteEntity(ctx context.Context, table, eventType string, id int) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n > 0 {
+		if err := recordEvent(ctx, tx, eventType, models.EntityDeleted{ID: id}); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
 // CRUD операции для пользователей
 
-func (d *Database) GetUsers() ([]models.User, error) {
-	rows, err := d.db.Query("SELECT id, name, email FROM users")
+// GetUsers возвращает пользователей вместе с названиями книг на руках (одним запросом).
+func (d *Database) GetUsers(ctx context.Context) ([]models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+userColumns+userBorrowedJoin+` GROUP BY users.id ORDER BY users.id`)
 	if err != nil {
 		return nil, err
 	}
@@ -115,35 +566,88 @@ func (d *Database) GetUsers() ([]models.User, error) {
 
 	var users []models.User
 	for rows.Next() {
-		var user models.User
-		if err := rows.Scan(&user.ID, &user.Name, &user.Email); err != nil {
+		user, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		users = append(users, user)
 	}
 
-	return users, nil
+	return users, rows.Err()
 }
 
-func (d *Database) AddUser(user models.User) (models.User, error) {
+// AddUser регистрирует читателя: номер читательского билета генерируется по id,
+// если не передан явно, срок действия берется из политики категории.
+func (d *Database) AddUser(ctx context.Context, user models.User) (models.User, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, err
+	}
+	defer tx.Rollback()
+
+	if err := checkGuardian(ctx, tx, user.Category, user.GuardianID); err != nil {
+		return models.User{}, err
+	}
+
 	var id int
-	err := d.db.QueryRow("INSERT INTO users (name, email) VALUES ($1, $2) RETURNING id", user.Name, user.Email).Scan(&id)
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO users (name, email, card_number, category, phone, address, guardian_id, registered_at, expires_at)
+		SELECT $1, $2, NULLIF($3, ''), $4, $5, $6, $7, CURRENT_DATE,
+			(CURRENT_DATE + make_interval(months => loan_policies.membership_months))::date
+		FROM loan_policies WHERE loan_policies.category = $4
+		RETURNING id`,
+		user.Name, user.Email, user.CardNumber, user.Category, user.Phone, user.Address, user.GuardianID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return models.User{}, ErrUnknownCategory
+	}
 	if err != nil {
 		return models.User{}, err
 	}
 
-	user.ID = id
-	return user, nil
+	if user.CardNumber == "" {
+		if _, err := tx.ExecContext(ctx, "UPDATE users SET card_number = $1 WHERE id = $2", CardNumber(id), id); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	user, err = getUser(ctx, tx, "users.id = $1", id)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := recordEvent(ctx, tx, models.EventUserAdded, user); err != nil {
+		return models.User{}, err
+	}
+
+	return user, tx.Commit()
 }
 
-func (d *Database) DeleteUser(id int) error {
-	_, err := d.db.Exec("DELETE FROM users WHERE id = $1", id)
-	return err
+func (d *Database) DeleteUser(ctx context.Context, id int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	return d.deleteEntity(ctx, "users", models.EventUserDeleted, id)
 }
 
 // CRUD операции для займов
-func (d *Database) GetLoans() ([]models.Loan, error) {
-	rows, err := d.db.Query("SELECT id, user_id, book_id, borrow_date, return_date FROM loans")
+
+const loanColumns = "id, user_id, book_id, copy_id, branch_id, borrow_date, due_date, return_date, outcome, overdue"
+
+func scanLoan(row rowScanner) (models.Loan, error) {
+	var loan models.Loan
+	// Используем time.Time для полей даты
+	err := row.Scan(&loan.ID, &loan.UserID, &loan.BookID, &loan.CopyID, &loan.BranchID, &loan.BorrowDate, &loan.DueDate, &loan.ReturnDate, &loan.Outcome, &loan.Overdue)
+	return loan, err
+}
+
+// GetLoans возвращает займы; если branchID не 0 — только выданные в этом филиале.
+func (d *Database) GetLoans(ctx context.Context, branchID int) ([]models.Loan, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE $1 = 0 OR branch_id = $1`, branchID)
 	if err != nil {
 		return nil, err
 	}
@@ -151,73 +655,193 @@ func (d *Database) GetLoans() ([]models.Loan, error) {
 
 	var loans []models.Loan
 	for rows.Next() {
-		var loan models.Loan
-		// Используем time.Time для полей даты
-		if err := rows.Scan(&loan.ID, &loan.UserID, &loan.BookID, &loan.BorrowDate, &loan.ReturnDate); err != nil {
+		loan, err := scanLoan(rows)
+		if err != nil {
 			return nil, err
 		}
 		loans = append(loans, loan)
 	}
 
-	return loans, nil
+	return loans, rows.Err()
 }
 
-func (d *Database) IssueLoan(userID, bookID int) (models.Loan, error) {
+// IssueLoan выдает книгу. Если у книги есть экземпляры, выбирается свободный экземпляр
+// (в филиале branchID, если он указан) либо экземпляр, отложенный для этого читателя.
+// Книги без экземпляров выдаются как раньше, без привязки к экземпляру.
+func (d *Database) IssueLoan(ctx context.Context, userID, bookID, branchID int) (models.Loan, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Loan{}, err
+	}
+	defer tx.Rollback()
+
+	dueDate, err := d.checkLoanPolicy(ctx, tx, userID, bookID)
+	if err != nil {
+		return models.Loan{}, err
+	}
+
+	var hasCopies bool
+	if err := tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM copies WHERE book_id = $1)", bookID).Scan(&hasCopies); err != nil {
+		return models.Loan{}, err
+	}
+
+	var copyID, loanBranchID *int
+	if hasCopies {
+		picked, err := d.pickCopyForLoan(ctx, tx, userID, bookID, branchID)
+		if err != nil {
+			return models.Loan{}, err
+		}
+		if _, err := tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = $2", models.CopyOnLoan, picked.ID); err != nil {
+			return models.Loan{}, err
+		}
+		copyID, loanBranchID = &picked.ID, &picked.CurrentBranchID
+	}
+
 	var id int
 	borrowDate := time.Now() // Используем текущую дату и время
-	err := d.db.QueryRow("INSERT INTO loans (user_id, book_id, borrow_date) VALUES ($1, $2, $3) RETURNING id", userID, bookID, borrowDate).Scan(&id)
+	err = tx.QueryRowContext(ctx, "INSERT INTO loans (user_id, book_id, copy_id, branch_id, borrow_date, due_date) VALUES ($1, $2, $3, $4, $5, $6) RETURNING id",
+		userID, bookID, copyID, loanBranchID, borrowDate, dueDate).Scan(&id)
 	if err != nil {
 		return models.Loan{}, err
 	}
 
 	// Возвращаем структуру с типом time.Time для даты
-	return models.Loan{
+	loan := models.Loan{
 		ID:         id,
 		UserID:     strconv.Itoa(userID),
 		BookID:     strconv.Itoa(bookID),
+		CopyID:     copyID,
+		BranchID:   loanBranchID,
 		BorrowDate: borrowDate.String(), // Используем тип time.Time
-	}, nil
+		DueDate:    &dueDate,
+	}
+	if err := countLoanStats(ctx, tx, id, false); err != nil {
+		return models.Loan{}, err
+	}
+	if err := recordEvent(ctx, tx, models.EventLoanIssued, loan); err != nil {
+		return models.Loan{}, err
+	}
+
+	return loan, tx.Commit()
+}
+
+// pickCopyForLoan выбирает экземпляр для выдачи: сначала готовую бронь читателя,
+// затем свободный экземпляр. Выбранная строка блокируется до конца транзакции.
+func (d *Database) pickCopyForLoan(ctx context.Context, tx *sql.Tx, userID, bookID, branchID int) (models.Copy, error) {
+	var picked models.Copy
+	var holdID int
+	err := tx.QueryRowContext(ctx, `
+		SELECT holds.id, copies.id, copies.current_branch_id
+		FROM holds
+		JOIN copies ON copies.id = holds.copy_id
+		WHERE holds.user_id = $1 AND holds.book_id = $2 AND holds.status = $3
+			AND ($4 = 0 OR copies.current_branch_id = $4)
+		ORDER BY holds.created_at
+		LIMIT 1
+		FOR UPDATE OF holds, copies`, userID, bookID, models.HoldReady, branchID).Scan(&holdID, &picked.ID, &picked.CurrentBranchID)
+	if err == nil {
+		_, err = tx.ExecContext(ctx, "UPDATE holds SET status = $1 WHERE id = $2", models.HoldFulfilled, holdID)
+		return picked, err
+	}
+	if err != sql.ErrNoRows {
+		return picked, err
+	}
+
+	err = tx.QueryRowContext(ctx, `
+		SELECT id, current_branch_id FROM copies
+		WHERE book_id = $1 AND status = $2 AND ($3 = 0 OR current_branch_id = $3)
+		ORDER BY id
+		LIMIT 1
+		FOR UPDATE SKIP LOCKED`, bookID, models.CopyAvailable, branchID).Scan(&picked.ID, &picked.CurrentBranchID)
+	if err == sql.ErrNoRows {
+		return picked, ErrNoCopyAvailable
+	}
+	return picked, err
 }
 
-func (d *Database) ReturnLoan(loanID int) (models.Loan, error) {
+// ReturnLoan закрывает займ. Возвращенный экземпляр сразу откладывается под ожидающую
+// бронь в том же филиале, иначе становится доступным.
+func (d *Database) ReturnLoan(ctx context.Context, loanID int) (models.Loan, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return models.Loan{}, err
+	}
+	defer tx.Rollback()
+
 	// Обновляем returnDate с использованием текущей даты
 	returnDate := time.Now() // Используем time.Now(), чтобы получить текущую дату
-	_, err := d.db.Exec("UPDATE loans SET return_date = $1 WHERE id = $2", returnDate, loanID)
+	var copyID *int
+	err = tx.QueryRowContext(ctx, "UPDATE loans SET return_date = $1 WHERE id = $2 AND return_date IS NULL RETURNING copy_id", returnDate, loanID).Scan(&copyID)
+	if err == sql.ErrNoRows {
+		return models.Loan{}, ErrNotFound
+	}
+	if err != nil {
+		return models.Loan{}, err
+	}
+
+	if copyID != nil {
+		if err := d.releaseCopy(ctx, tx, *copyID); err != nil {
+			return models.Loan{}, err
+		}
+	}
+
+	if err := countLoanStats(ctx, tx, loanID, true); err != nil {
+		return models.Loan{}, err
+	}
+
+	loan, err := scanLoan(tx.QueryRowContext(ctx, `SELECT `+loanColumns+` FROM loans WHERE id = $1`, loanID))
 	if err != nil {
 		return models.Loan{}, err
 	}
-	var date string = returnDate.String()
-	// Возвращаем структуру с правильным типом для ReturnDate
-	return models.Loan{
-		ID:         loanID,
-		ReturnDate: &date, // Используем указатель на time.Time для nullable даты
-	}, nil
+	if err := recordEvent(ctx, tx, models.EventLoanReturned, loan); err != nil {
+		return models.Loan{}, err
+	}
+
+	return loan, tx.Commit()
 }
 
 // GetStats собирает статистику по библиотеке
-func (r *Database) GetStats() (*models.Statistics, error) {
+func (r *Database) GetStats(ctx context.Context) (*models.Statistics, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
 	stats := &models.Statistics{}
 
-	// Получаем общее количество книг
-	err := r.db.QueryRow("SELECT COUNT(*) FROM books").Scan(&stats.TotalBooks)
+	// Получаем общее количество книг; книги, все экземпляры которых утеряны или списаны, не учитываются
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM books
+		WHERE NOT EXISTS (SELECT 1 FROM copies WHERE copies.book_id = books.id)
+			OR EXISTS (SELECT 1 FROM copies WHERE copies.book_id = books.id AND copies.status <> ALL($1))`,
+		pq.Array([]string{models.CopyLost, models.CopyWithdrawn})).Scan(&stats.TotalBooks)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching total books: %v", err)
 	}
 
 	// Получаем общее количество пользователей
-	err = r.db.QueryRow("SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM users").Scan(&stats.TotalUsers)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching total users: %v", err)
 	}
 
 	// Получаем общее количество займов
-	err = r.db.QueryRow("SELECT COUNT(*) FROM loans WHERE return_date IS NULL").Scan(&stats.TotalLoans)
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM loans WHERE return_date IS NULL").Scan(&stats.TotalLoans)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching total loans: %v", err)
 	}
 
+	// Получаем количество списанных экземпляров
+	err = r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM withdrawals").Scan(&stats.TotalWithdrawn)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching total withdrawn: %v", err)
+	}
+
 	// Получаем статистику по категориям
-	rows, err := r.db.Query(`
+	rows, err := r.db.QueryContext(ctx, `
 		SELECT category, COUNT(loans.id) 
 		FROM books
 		LEFT JOIN loans ON books.id = loans.book_id 
@@ -236,9 +860,12 @@ func (r *Database) GetStats() (*models.Statistics, error) {
 		}
 		stats.PopularCategories = append(stats.PopularCategories, categoryStats)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching category stats: %v", err)
+	}
 
 	// Получаем статистику по пользователям
-	rows, err = r.db.Query(`
+	rows, err = r.db.QueryContext(ctx, `
 		SELECT users.name, COUNT(loans.id)
 		FROM users
 		LEFT JOIN loans ON users.id = loans.user_id
@@ -257,6 +884,34 @@ func (r *Database) GetStats() (*models.Statistics, error) {
 		}
 		stats.ActiveUsers = append(stats.ActiveUsers, userStats)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching user stats: %v", err)
+	}
+
+	// Получаем статистику по филиалам
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT branches.name,
+			(SELECT COUNT(*) FROM copies WHERE copies.current_branch_id = branches.id AND copies.status <> ALL($2)),
+			(SELECT COUNT(*) FROM loans WHERE loans.branch_id = branches.id AND loans.return_date IS NULL),
+			(SELECT COUNT(*) FROM transfers WHERE transfers.to_branch_id = branches.id AND transfers.status = $1)
+		FROM branches
+		ORDER BY branches.name`, models.TransferInTransit, pq.Array([]string{models.CopyLost, models.CopyWithdrawn}))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching branch stats: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var branchStats models.BranchStats
+		err := rows.Scan(&branchStats.Name, &branchStats.TotalCopies, &branchStats.ActiveLoans, &branchStats.InTransit)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning branch stats: %v", err)
+		}
+		stats.Branches = append(stats.Branches, branchStats)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error fetching branch stats: %v", err)
+	}
 
 	// Возвращаем собранную статистику
 	return stats, nil
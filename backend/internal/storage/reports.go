@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+
+	"github.com/lib/pq"
+)
+
+// defaultLoanDays — срок выдачи для займа без срока возврата, если у категории читателя
+// нет политики выдачи
+const defaultLoanDays = 14
+
+// GetOverdueReport собирает просроченные займы по фильтру, сгруппированные по читателям.
+// Просрочка считается от срока возврата на текущую дату, не дожидаясь задачи отметки
+// просрочек; у старых займов без срока он считается от даты выдачи по сроку выдачи
+// категории читателя. Начисленный штраф — дни просрочки, умноженные на дневной штраф
+// категории. Читатели с наибольшей просрочкой идут первыми.
+func (d *Database) GetOverdueReport(ctx context.Context, filter models.OverdueFilter) (models.OverdueReport, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	report := models.OverdueReport{Patrons: []models.OverduePatron{}}
+	if err := d.db.QueryRowContext(ctx, "SELECT to_char(CURRENT_DATE, 'YYYY-MM-DD')").Scan(&report.Date); err != nil {
+		return report, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT users.id, users.name, users.card_number, users.category, users.email, users.phone, users.address,
+			(SELECT COALESCE(SUM(amount), 0) FROM fines WHERE fines.user_id = users.id),
+			loans.id, books.id, books.title, books.author, COALESCE(copies.barcode, ''), loans.branch_id,
+			to_char(loans.borrow_date, 'YYYY-MM-DD'), to_char(due.date, 'YYYY-MM-DD'), loans.due_date IS NULL,
+			CURRENT_DATE - due.date, (CURRENT_DATE - due.date) * COALESCE(loan_policies.daily_fine, 0),
+			MAX(CURRENT_DATE - due.date) OVER (PARTITION BY users.id) AS worst
+		FROM loans
+		JOIN users ON users.id = loans.user_id
+		JOIN books ON books.id = loans.book_id
+		LEFT JOIN copies ON copies.id = loans.copy_id
+		LEFT JOIN loan_policies ON loan_policies.category = users.category
+		CROSS JOIN LATERAL (
+			SELECT COALESCE(loans.due_date, loans.borrow_date::date + COALESCE(loan_policies.loan_days, $4)) AS date
+		) due
+		WHERE loans.return_date IS NULL
+			AND CURRENT_DATE - due.date >= GREATEST($1, 1)
+			AND ($2 = 0 OR loans.branch_id = $2)
+			AND ($3 = '' OR users.category = $3)
+		ORDER BY worst DESC, users.name, users.id, due.date, loans.id`,
+		filter.MinDays, filter.BranchID, filter.Category, defaultLoanDays)
+	if err != nil {
+		return report, err
+	}
+	defer rows.Close()
+
+	var loanIDs []int64
+	for rows.Next() {
+		var p models.OverduePatron
+		var l models.OverdueLoan
+		var worst int
+		err := rows.Scan(&p.UserID, &p.Name, &p.CardNumber, &p.Category, &p.Email, &p.Phone, &p.Address, &p.Balance,
+			&l.LoanID, &l.BookID, &l.Title, &l.Author, &l.Barcode, &l.BranchID, &l.BorrowDate, &l.DueDate, &l.DueEstimated,
+			&l.DaysOverdue, &l.AccruedFine, &worst)
+		if err != nil {
+			return report, err
+		}
+		l.Contacts = []models.ContactAttempt{}
+
+		if n := len(report.Patrons); n == 0 || report.Patrons[n-1].UserID != p.UserID {
+			report.Patrons = append(report.Patrons, p)
+		}
+		patron := &report.Patrons[len(report.Patrons)-1]
+		patron.Loans = append(patron.Loans, l)
+		patron.AccruedFines += l.AccruedFine
+		loanIDs = append(loanIDs, int64(l.LoanID))
+	}
+	if err := rows.Err(); err != nil {
+		return report, err
+	}
+	if len(loanIDs) == 0 {
+		return report, nil
+	}
+
+	// Указатели берутся после того, как срезы перестали расти
+	loansByID := make(map[int]*models.OverdueLoan, len(loanIDs))
+	for i := range report.Patrons {
+		for j := range report.Patrons[i].Loans {
+			loan := &report.Patrons[i].Loans[j]
+			loansByID[loan.LoanID] = loan
+		}
+	}
+
+	contacts, err := d.getContactAttempts(ctx, loanIDs)
+	if err != nil {
+		return report, err
+	}
+	for _, contact := range contacts {
+		if loan, ok := loansByID[contact.LoanID]; ok {
+			loan.Contacts = append(loan.Contacts, contact)
+		}
+	}
+
+	return report, nil
+}
+
+// GetContactAttempts возвращает попытки связаться с читателем по займу.
+func (d *Database) GetContactAttempts(ctx context.Context, loanID int) ([]models.ContactAttempt, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	return d.getContactAttempts(ctx, []int64{int64(loanID)})
+}
+
+func (d *Database) getContactAttempts(ctx context.Context, loanIDs []int64) ([]models.ContactAttempt, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT id, loan_id, method, note, staff, created_at FROM loan_contacts
+		WHERE loan_id = ANY($1)
+		ORDER BY created_at, id`, pq.Array(loanIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	contacts := []models.ContactAttempt{}
+	for rows.Next() {
+		var c models.ContactAttempt
+		if err := rows.Scan(&c.ID, &c.LoanID, &c.Method, &c.Note, &c.Staff, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		contacts = append(contacts, c)
+	}
+
+	return contacts, rows.Err()
+}
+
+// AddContactAttempt записывает попытку связаться с читателем по займу. Записать ее можно
+// только по открытому займу.
+func (d *Database) AddContactAttempt(ctx context.Context, attempt models.ContactAttempt) (models.ContactAttempt, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, `
+		INSERT INTO loan_contacts (loan_id, method, note, staff)
+		SELECT id, $2, $3, $4 FROM loans WHERE id = $1 AND return_date IS NULL
+		RETURNING id, created_at`, attempt.LoanID, attempt.Method, attempt.Note, attempt.Staff).Scan(&attempt.ID, &attempt.CreatedAt)
+	if err == sql.ErrNoRows {
+		return models.ContactAttempt{}, ErrNotFound
+	}
+	return attempt, err
+}
@@ -0,0 +1,160 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// loanStatsFill заполняет дневные сводки выдач по всей истории, если таблица пуста.
+// Выдача учитывается в день выдачи, возврат (в том числе закрытие утерей) — в день возврата.
+const loanStatsFill = `
+	INSERT INTO loan_stats_daily (day, category, branch_id, patron_category, issued, returned)
+	SELECT day, category, branch_id, patron_category, SUM(issued), SUM(returned)
+	FROM (
+		SELECT loans.borrow_date AS day, books.category, COALESCE(loans.branch_id, 0) AS branch_id,
+			users.category AS patron_category, 1 AS issued, 0 AS returned
+		FROM loans
+		JOIN books ON books.id = loans.book_id
+		JOIN users ON users.id = loans.user_id
+		UNION ALL
+		SELECT loans.return_date, books.category, COALESCE(loans.branch_id, 0), users.category, 0, 1
+		FROM loans
+		JOIN books ON books.id = loans.book_id
+		JOIN users ON users.id = loans.user_id
+		WHERE loans.return_date IS NOT NULL
+	) events
+	WHERE NOT EXISTS (SELECT 1 FROM loan_stats_daily)
+	GROUP BY day, category, branch_id, patron_category`
+
+// countLoanStats учитывает выдачу (или ее закрытие, если closed) в дневной сводке в той же
+// транзакции. Категории книги и читателя берутся на момент события; если их потом изменят,
+// сводки разойдутся с историей до пересборки.
+func countLoanStats(ctx context.Context, tx *sql.Tx, loanID int, closed bool) error {
+	_, err := tx.ExecContext(ctx, `
+		INSERT INTO loan_stats_daily AS s (day, category, branch_id, patron_category, issued, returned)
+		SELECT CASE WHEN $2 THEN loans.return_date ELSE loans.borrow_date END,
+			books.category, COALESCE(loans.branch_id, 0), users.category,
+			CASE WHEN $2 THEN 0 ELSE 1 END, CASE WHEN $2 THEN 1 ELSE 0 END
+		FROM loans
+		JOIN books ON books.id = loans.book_id
+		JOIN users ON users.id = loans.user_id
+		WHERE loans.id = $1
+		ON CONFLICT (day, category, branch_id, patron_category) DO UPDATE
+			SET issued = s.issued + EXCLUDED.issued, returned = s.returned + EXCLUDED.returned`, loanID, closed)
+	return err
+}
+
+// RebuildLoanStats пересчитывает дневные сводки с нуля и возвращает число строк.
+// Блокировка таблицы дожидается выдач, уже учтенных в сводке, и задерживает новые до
+// конца пересборки, так что ни одна выдача не теряется и не учитывается дважды.
+func (d *Database) RebuildLoanStats(ctx context.Context) (int, error) {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "LOCK TABLE loan_stats_daily IN EXCLUSIVE MODE"); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM loan_stats_daily"); err != nil {
+		return 0, err
+	}
+	res, err := tx.ExecContext(ctx, loanStatsFill)
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+
+	return int(n), tx.Commit()
+}
+
+// GetRollupStatistics собирает статистику за период по дневным сводкам; если branchID
+// не 0 — только по выдачам этого филиала. Open — выдачи, не закрытые на конец периода.
+func (d *Database) GetRollupStatistics(ctx context.Context, period models.StatisticsPeriod, branchID int) (models.RollupStatistics, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	stats := models.RollupStatistics{
+		From:             period.From.Format("2006-01-02"),
+		To:               period.To.Format("2006-01-02"),
+		Granularity:      period.Granularity,
+		Series:           []models.RollupPoint{},
+		Categories:       []models.RollupGroup{},
+		Branches:         []models.RollupGroup{},
+		PatronCategories: []models.RollupGroup{},
+	}
+	from, to := stats.From, stats.To
+
+	rows, err := d.db.QueryContext(ctx, `
+		WITH totals AS (
+			SELECT date_trunc($3, day::timestamp) AS period, SUM(issued) AS issued, SUM(returned) AS returned
+			FROM loan_stats_daily
+			WHERE day BETWEEN $1::date AND $2::date AND ($4 = 0 OR branch_id = $4)
+			GROUP BY 1
+		)
+		SELECT to_char(p.period, 'YYYY-MM-DD'), COALESCE(totals.issued, 0), COALESCE(totals.returned, 0)
+		FROM generate_series(date_trunc($3, $1::timestamp), date_trunc($3, $2::timestamp), ('1 ' || $3)::interval) AS p(period)
+		LEFT JOIN totals ON totals.period = p.period
+		ORDER BY p.period`, from, to, period.Granularity, branchID)
+	if err != nil {
+		return stats, fmt.Errorf("error fetching rollup series: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.RollupPoint
+		if err := rows.Scan(&p.Period, &p.Issued, &p.Returned); err != nil {
+			return stats, fmt.Errorf("error scanning rollup series: %v", err)
+		}
+		stats.Series = append(stats.Series, p)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	if stats.Categories, err = d.getRollupGroups(ctx, "loan_stats_daily.category", "", from, to, branchID); err != nil {
+		return stats, err
+	}
+	if stats.Branches, err = d.getRollupGroups(ctx, "COALESCE(branches.name, '')",
+		"LEFT JOIN branches ON branches.id = loan_stats_daily.branch_id", from, to, branchID); err != nil {
+		return stats, err
+	}
+	if stats.PatronCategories, err = d.getRollupGroups(ctx, "loan_stats_daily.patron_category", "", from, to, branchID); err != nil {
+		return stats, err
+	}
+
+	return stats, nil
+}
+
+// getRollupGroups группирует сводки по выражению key: выдачи и возвраты за период и
+// выдачи, открытые на его конец. Группы без движения и без открытых выдач пропускаются.
+func (d *Database) getRollupGroups(ctx context.Context, key, join, from, to string, branchID int) ([]models.RollupGroup, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+key+`,
+			COALESCE(SUM(issued) FILTER (WHERE day >= $1::date), 0),
+			COALESCE(SUM(returned) FILTER (WHERE day >= $1::date), 0),
+			SUM(issued - returned)
+		FROM loan_stats_daily `+join+`
+		WHERE day <= $2::date AND ($3 = 0 OR loan_stats_daily.branch_id = $3)
+		GROUP BY 1
+		HAVING SUM(issued + returned) FILTER (WHERE day >= $1::date) > 0 OR SUM(issued - returned) <> 0
+		ORDER BY 2 DESC, 1`, from, to, branchID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching rollup groups: %v", err)
+	}
+	defer rows.Close()
+
+	groups := []models.RollupGroup{}
+	for rows.Next() {
+		var g models.RollupGroup
+		if err := rows.Scan(&g.Name, &g.Issued, &g.Returned, &g.Open); err != nil {
+			return nil, fmt.Errorf("error scanning rollup groups: %v", err)
+		}
+		groups = append(groups, g)
+	}
+
+	return groups, rows.Err()
+}
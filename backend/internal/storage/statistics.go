@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"fmt"
+)
+
+// GetTrendStatistics считает выдачи, возвраты и новых читателей по интервалам периода
+// и рейтинги за период. Считается вся история выдач, а не только открытые выдачи.
+func (r *Database) GetTrendStatistics(ctx context.Context, period models.StatisticsPeriod, limit int) (models.TrendStatistics, error) {
+	ctx, cancel := r.withTimeout(ctx)
+	defer cancel()
+
+	stats := models.TrendStatistics{
+		From:          period.From.Format("2006-01-02"),
+		To:            period.To.Format("2006-01-02"),
+		Granularity:   period.Granularity,
+		Series:        []models.TrendPoint{},
+		TopTitles:     []models.TitleTrend{},
+		TopCategories: []models.CategoryTrend{},
+		TopPatrons:    []models.PatronTrend{},
+	}
+	from, to, unit := stats.From, stats.To, period.Granularity
+
+	// Интервалы без событий тоже попадают в ряд — с нулями
+	rows, err := r.db.QueryContext(ctx, `
+		WITH issued AS (
+			SELECT date_trunc($3, borrow_date::timestamp) AS period, COUNT(*) AS n
+			FROM loans WHERE borrow_date BETWEEN $1::date AND $2::date GROUP BY 1
+		), returned AS (
+			SELECT date_trunc($3, return_date::timestamp) AS period, COUNT(*) AS n
+			FROM loans WHERE return_date BETWEEN $1::date AND $2::date GROUP BY 1
+		), patrons AS (
+			SELECT date_trunc($3, registered_at::timestamp) AS period, COUNT(*) AS n
+			FROM users WHERE registered_at BETWEEN $1::date AND $2::date GROUP BY 1
+		)
+		SELECT to_char(p.period, 'YYYY-MM-DD'), COALESCE(issued.n, 0), COALESCE(returned.n, 0), COALESCE(patrons.n, 0)
+		FROM generate_series(date_trunc($3, $1::timestamp), date_trunc($3, $2::timestamp), ('1 ' || $3)::interval) AS p(period)
+		LEFT JOIN issued ON issued.period = p.period
+		LEFT JOIN returned ON returned.period = p.period
+		LEFT JOIN patrons ON patrons.period = p.period
+		ORDER BY p.period`, from, to, unit)
+	if err != nil {
+		return stats, fmt.Errorf("error fetching statistics series: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.TrendPoint
+		if err := rows.Scan(&p.Period, &p.Issued, &p.Returned, &p.NewPatrons); err != nil {
+			return stats, fmt.Errorf("error scanning statistics series: %v", err)
+		}
+		stats.Series = append(stats.Series, p)
+		stats.Totals.Issued += p.Issued
+		stats.Totals.Returned += p.Returned
+		stats.Totals.NewPatrons += p.NewPatrons
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	// Самые популярные книги за период
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT books.id, books.title, books.author, COUNT(*)
+		FROM loans JOIN books ON books.id = loans.book_id
+		WHERE loans.borrow_date BETWEEN $1::date AND $2::date
+		GROUP BY books.id
+		ORDER BY COUNT(*) DESC, books.title
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return stats, fmt.Errorf("error fetching top titles: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var t models.TitleTrend
+		if err := rows.Scan(&t.BookID, &t.Title, &t.Author, &t.Loans); err != nil {
+			return stats, fmt.Errorf("error scanning top titles: %v", err)
+		}
+		stats.TopTitles = append(stats.TopTitles, t)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	// Самые популярные категории за период
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT books.category, COUNT(*)
+		FROM loans JOIN books ON books.id = loans.book_id
+		WHERE loans.borrow_date BETWEEN $1::date AND $2::date
+		GROUP BY books.category
+		ORDER BY COUNT(*) DESC, books.category
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return stats, fmt.Errorf("error fetching top categories: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var c models.CategoryTrend
+		if err := rows.Scan(&c.Name, &c.Loans); err != nil {
+			return stats, fmt.Errorf("error scanning top categories: %v", err)
+		}
+		stats.TopCategories = append(stats.TopCategories, c)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	// Самые активные читатели за период
+	rows, err = r.db.QueryContext(ctx, `
+		SELECT users.id, users.name, COUNT(*)
+		FROM loans JOIN users ON users.id = loans.user_id
+		WHERE loans.borrow_date BETWEEN $1::date AND $2::date
+		GROUP BY users.id
+		ORDER BY COUNT(*) DESC, users.name
+		LIMIT $3`, from, to, limit)
+	if err != nil {
+		return stats, fmt.Errorf("error fetching top patrons: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var p models.PatronTrend
+		if err := rows.Scan(&p.UserID, &p.Name, &p.Loans); err != nil {
+			return stats, fmt.Errorf("error scanning top patrons: %v", err)
+		}
+		stats.TopPatrons = append(stats.TopPatrons, p)
+	}
+
+	return stats, rows.Err()
+}
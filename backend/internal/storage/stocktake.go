@@ -0,0 +1,244 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+// stocktakeLocation — условие «экземпляр числится в зоне инвентаризации st»
+const stocktakeLocation = `copies.current_branch_id = st.branch_id
+	AND (st.shelf_from = '' OR copies.shelf >= st.shelf_from)
+	AND (st.shelf_to = '' OR copies.shelf <= st.shelf_to)`
+
+// stocktakeScanned — условие «штрихкод экземпляра отсканирован в сессии st»
+const stocktakeScanned = `EXISTS (
+	SELECT 1 FROM stocktake_scans
+	WHERE stocktake_scans.stocktake_id = st.id AND stocktake_scans.barcode = copies.barcode)`
+
+// onShelfStatuses — статусы экземпляров, которые должны стоять на полке
+var onShelfStatuses = pq.Array([]string{models.CopyAvailable, models.CopyOnHold, models.CopyDamaged})
+
+func (d *Database) GetStocktakes(ctx context.Context) ([]models.Stocktake, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id, branch_id, shelf_from, shelf_to, status, opened_at, closed_at FROM stocktakes ORDER BY opened_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var stocktakes []models.Stocktake
+	for rows.Next() {
+		var st models.Stocktake
+		if err := rows.Scan(&st.ID, &st.BranchID, &st.ShelfFrom, &st.ShelfTo, &st.Status, &st.OpenedAt, &st.ClosedAt); err != nil {
+			return nil, err
+		}
+		stocktakes = append(stocktakes, st)
+	}
+
+	return stocktakes, rows.Err()
+}
+
+// OpenStocktake открывает сессию инвентаризации филиала (и диапазона полок, если он задан).
+func (d *Database) OpenStocktake(ctx context.Context, st models.Stocktake) (models.Stocktake, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	st.Status = models.StocktakeOpen
+	err := d.db.QueryRowContext(ctx, `
+		INSERT INTO stocktakes (branch_id, shelf_from, shelf_to, status) VALUES ($1, $2, $3, $4)
+		RETURNING id, opened_at`, st.BranchID, st.ShelfFrom, st.ShelfTo, st.Status).Scan(&st.ID, &st.OpenedAt)
+	if err != nil {
+		return models.Stocktake{}, err
+	}
+	return st, nil
+}
+
+// AddStocktakeScans сохраняет пачку отсканированных штрихкодов. Повторные сканы
+// игнорируются; возвращается число новых штрихкодов.
+func (d *Database) AddStocktakeScans(ctx context.Context, stocktakeID int, barcodes []string) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(ctx, tx, stocktakeID); err != nil {
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `
+		INSERT INTO stocktake_scans (stocktake_id, barcode)
+		SELECT $1, barcode FROM unnest($2::text[]) AS barcode
+		ON CONFLICT DO NOTHING`, stocktakeID, pq.Array(barcodes))
+	if err != nil {
+		return 0, err
+	}
+	added, _ := res.RowsAffected()
+
+	return int(added), tx.Commit()
+}
+
+func lockOpenStocktake(ctx context.Context, tx *sql.Tx, stocktakeID int) error {
+	var status string
+	err := tx.QueryRowContext(ctx, "SELECT status FROM stocktakes WHERE id = $1 FOR UPDATE", stocktakeID).Scan(&status)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if status != models.StocktakeOpen {
+		return ErrInvalidState
+	}
+	return nil
+}
+
+// GetStocktakeReport сверяет отсканированные штрихкоды с каталогом: что должно стоять
+// на полках, но не найдено; что найдено, но числится выданным; что найдено не на своем месте;
+// что найдено, хотя числится утерянным или списанным.
+func (d *Database) GetStocktakeReport(ctx context.Context, stocktakeID int) (models.StocktakeReport, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	report := models.StocktakeReport{StocktakeID: stocktakeID}
+
+	err := d.db.QueryRowContext(ctx, `
+		SELECT
+			(SELECT COUNT(*) FROM copies WHERE `+stocktakeLocation+` AND copies.status = ANY($2)),
+			(SELECT COUNT(*) FROM stocktake_scans WHERE stocktake_scans.stocktake_id = st.id)
+		FROM stocktakes st WHERE st.id = $1`, stocktakeID, onShelfStatuses).Scan(&report.Expected, &report.Scanned)
+	if err == sql.ErrNoRows {
+		return models.StocktakeReport{}, ErrNotFound
+	}
+	if err != nil {
+		return models.StocktakeReport{}, err
+	}
+
+	if report.Missing, err = d.stocktakeCopies(ctx, stocktakeID,
+		stocktakeLocation+` AND copies.status = ANY($2) AND NOT `+stocktakeScanned, onShelfStatuses); err != nil {
+		return models.StocktakeReport{}, err
+	}
+	if report.OnLoan, err = d.stocktakeCopies(ctx, stocktakeID,
+		stocktakeScanned+` AND copies.status = $2`, models.CopyOnLoan); err != nil {
+		return models.StocktakeReport{}, err
+	}
+	if report.WrongLocation, err = d.stocktakeCopies(ctx, stocktakeID,
+		stocktakeScanned+` AND copies.status <> ALL($2) AND NOT (`+stocktakeLocation+`)`,
+		pq.Array([]string{models.CopyOnLoan, models.CopyLost, models.CopyWithdrawn})); err != nil {
+		return models.StocktakeReport{}, err
+	}
+	if report.Found, err = d.stocktakeCopies(ctx, stocktakeID,
+		stocktakeScanned+` AND copies.status = ANY($2)`, pq.Array([]string{models.CopyLost, models.CopyWithdrawn})); err != nil {
+		return models.StocktakeReport{}, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT barcode FROM stocktake_scans
+		WHERE stocktake_id = $1 AND NOT EXISTS (SELECT 1 FROM copies WHERE copies.barcode = stocktake_scans.barcode)
+		ORDER BY barcode`, stocktakeID)
+	if err != nil {
+		return models.StocktakeReport{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var barcode string
+		if err := rows.Scan(&barcode); err != nil {
+			return models.StocktakeReport{}, err
+		}
+		report.Unknown = append(report.Unknown, barcode)
+	}
+
+	return report, rows.Err()
+}
+
+// stocktakeCopies выбирает экземпляры по условию where, в котором доступна сессия st ($1)
+func (d *Database) stocktakeCopies(ctx context.Context, stocktakeID int, where string, arg any) ([]models.Copy, error) {
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+copyColumns+`
+		FROM copies, stocktakes st
+		WHERE st.id = $1 AND `+where+`
+		ORDER BY copies.shelf, copies.barcode`, stocktakeID, arg)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	return scanCopies(rows)
+}
+
+// MarkStocktakeMissingLost списывает как утерянные все экземпляры, которые должны были
+// стоять на полках, но не были отсканированы, и публикует copy.lost для каждого из них.
+// Возвращает число списанных экземпляров.
+func (d *Database) MarkStocktakeMissingLost(ctx context.Context, stocktakeID int) (int, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	if err := lockOpenStocktake(ctx, tx, stocktakeID); err != nil {
+		return 0, err
+	}
+
+	// Под бронь отложенные экземпляры тоже пропали: бронь возвращается в очередь
+	_, err = tx.ExecContext(ctx, `
+		UPDATE holds SET status = $2, copy_id = NULL
+		FROM copies, stocktakes st
+		WHERE st.id = $1 AND holds.copy_id = copies.id AND holds.status = $3
+			AND `+stocktakeLocation+` AND copies.status = $4 AND NOT `+stocktakeScanned,
+		stocktakeID, models.HoldWaiting, models.HoldReady, models.CopyOnHold)
+	if err != nil {
+		return 0, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		UPDATE copies SET status = $2
+		FROM stocktakes st
+		WHERE st.id = $1 AND `+stocktakeLocation+` AND copies.status = ANY($3) AND NOT `+stocktakeScanned+`
+		RETURNING `+copyColumns,
+		stocktakeID, models.CopyLost, onShelfStatuses)
+	if err != nil {
+		return 0, err
+	}
+	lost, err := scanCopies(rows)
+	rows.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	for _, c := range lost {
+		if err := recordEvent(ctx, tx, models.EventCopyLost, c); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(lost), tx.Commit()
+}
+
+// CloseStocktake закрывает сессию; после закрытия сканы и списание не принимаются.
+func (d *Database) CloseStocktake(ctx context.Context, stocktakeID int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "UPDATE stocktakes SET status = $1, closed_at = $2 WHERE id = $3 AND status = $4",
+		models.StocktakeClosed, time.Now(), stocktakeID, models.StocktakeOpen)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrInvalidState
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+	"time"
+)
+
+// Операции для вебхуков
+
+func (d *Database) GetWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, "SELECT id, url, events, active, created_at FROM webhook_subscriptions ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		var sub models.WebhookSubscription
+		if err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&sub.Events), &sub.Active, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+
+	return subs, rows.Err()
+}
+
+func (d *Database) AddWebhook(ctx context.Context, sub models.WebhookSubscription) (models.WebhookSubscription, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	err := d.db.QueryRowContext(ctx, `
+		INSERT INTO webhook_subscriptions (url, events, secret, active) VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at`, sub.URL, pq.Array(sub.Events), sub.Secret, sub.Active).Scan(&sub.ID, &sub.CreatedAt)
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	return sub, nil
+}
+
+func (d *Database) DeleteWebhook(ctx context.Context, id int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = $1", id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// RecordWebhookEvent сохраняет событие outbox и ставит его доставку в очередь каждой
+// активной подписке на этот тип событий. Повторная запись того же события ничего не делает.
+func (d *Database) RecordWebhookEvent(ctx context.Context, event models.OutboxEvent) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var eventID int
+	err = tx.QueryRowContext(ctx, `
+		INSERT INTO webhook_events (outbox_id, type, data) VALUES ($1, $2, $3)
+		ON CONFLICT (outbox_id) DO NOTHING
+		RETURNING id`, event.ID, event.Type, []byte(event.Data)).Scan(&eventID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries (subscription_id, event_id)
+		SELECT id, $1 FROM webhook_subscriptions WHERE active AND $2 = ANY(events)`, eventID, event.Type)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetDueWebhooks возвращает доставки, время очередной попытки которых наступило.
+func (d *Database) GetDueWebhooks(ctx context.Context, limit int) ([]models.OutgoingWebhook, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT webhook_deliveries.id, webhook_deliveries.attempts, webhook_subscriptions.url, webhook_subscriptions.secret,
+			webhook_events.id, webhook_events.type, webhook_events.data, webhook_events.created_at
+		FROM webhook_deliveries
+		JOIN webhook_subscriptions ON webhook_subscriptions.id = webhook_deliveries.subscription_id
+		JOIN webhook_events ON webhook_events.id = webhook_deliveries.event_id
+		WHERE webhook_deliveries.status = $1 AND webhook_deliveries.next_attempt_at <= now()
+		ORDER BY webhook_deliveries.next_attempt_at, webhook_deliveries.id
+		LIMIT $2`, models.DeliveryPending, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var due []models.OutgoingWebhook
+	for rows.Next() {
+		var w models.OutgoingWebhook
+		if err := rows.Scan(&w.DeliveryID, &w.Attempts, &w.URL, &w.Secret, &w.EventID, &w.EventType, &w.Data, &w.OccurredAt); err != nil {
+			return nil, err
+		}
+		due = append(due, w)
+	}
+
+	return due, rows.Err()
+}
+
+// RecordWebhookAttempt записывает попытку доставки в журнал. Успешная доставка
+// закрывается; неуспешная переносится на retryAfter, а если retryAfter == 0 —
+// помечается failed.
+func (d *Database) RecordWebhookAttempt(ctx context.Context, deliveryID int, attempt models.WebhookAttempt, delivered bool, retryAfter time.Duration) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO webhook_attempts (delivery_id, status_code, error, duration_ms) VALUES ($1, $2, $3, $4)",
+		deliveryID, attempt.StatusCode, attempt.Error, attempt.DurationMs)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case delivered:
+		_, err = tx.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1, delivered_at = now() WHERE id = $2",
+			models.DeliveryDelivered, deliveryID)
+	case retryAfter > 0:
+		_, err = tx.ExecContext(ctx, `
+			UPDATE webhook_deliveries SET attempts = attempts + 1, next_attempt_at = now() + make_interval(secs => $1)
+			WHERE id = $2`, retryAfter.Seconds(), deliveryID)
+	default:
+		_, err = tx.ExecContext(ctx, "UPDATE webhook_deliveries SET status = $1, attempts = attempts + 1 WHERE id = $2",
+			models.DeliveryFailed, deliveryID)
+	}
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const deliveryColumns = `webhook_deliveries.id, webhook_deliveries.subscription_id, webhook_deliveries.event_id, webhook_events.type,
+	webhook_deliveries.status, webhook_deliveries.attempts, webhook_deliveries.next_attempt_at,
+	webhook_deliveries.created_at, webhook_deliveries.delivered_at`
+
+func scanDelivery(row rowScanner) (models.WebhookDelivery, error) {
+	var dl models.WebhookDelivery
+	err := row.Scan(&dl.ID, &dl.SubscriptionID, &dl.EventID, &dl.EventType, &dl.Status, &dl.Attempts, &dl.NextAttemptAt,
+		&dl.CreatedAt, &dl.DeliveredAt)
+	return dl, err
+}
+
+// GetWebhookDeliveries возвращает последние доставки подписки; если status не пуст — только в этом статусе.
+func (d *Database) GetWebhookDeliveries(ctx context.Context, subscriptionID int, status string, limit int) ([]models.WebhookDelivery, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries JOIN webhook_events ON webhook_events.id = webhook_deliveries.event_id
+		WHERE webhook_deliveries.subscription_id = $1 AND ($2 = '' OR webhook_deliveries.status = $2)
+		ORDER BY webhook_deliveries.id DESC
+		LIMIT $3`, subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		dl, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, dl)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// GetWebhookDelivery возвращает доставку вместе с журналом попыток.
+func (d *Database) GetWebhookDelivery(ctx context.Context, id int) (models.WebhookDelivery, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	dl, err := scanDelivery(d.db.QueryRowContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries JOIN webhook_events ON webhook_events.id = webhook_deliveries.event_id
+		WHERE webhook_deliveries.id = $1`, id))
+	if err == sql.ErrNoRows {
+		return models.WebhookDelivery{}, ErrNotFound
+	}
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT attempted_at, status_code, error, duration_ms FROM webhook_attempts
+		WHERE delivery_id = $1 ORDER BY attempted_at`, id)
+	if err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var a models.WebhookAttempt
+		if err := rows.Scan(&a.AttemptedAt, &a.StatusCode, &a.Error, &a.DurationMs); err != nil {
+			return models.WebhookDelivery{}, err
+		}
+		dl.Log = append(dl.Log, a)
+	}
+
+	return dl, rows.Err()
+}
+
+// RedeliverWebhook возвращает доставку в очередь с обнуленным счетчиком попыток;
+// журнал прежних попыток сохраняется.
+func (d *Database) RedeliverWebhook(ctx context.Context, id int) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, `
+		UPDATE webhook_deliveries SET status = $1, attempts = 0, next_attempt_at = now(), delivered_at = NULL
+		WHERE id = $2`, models.DeliveryPending, id)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
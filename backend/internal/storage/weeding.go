@@ -0,0 +1,150 @@
+package storage
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"database/sql"
+	"github.com/lib/pq"
+)
+
+// GetWeedingCandidates возвращает экземпляры на полках, которые не выдавались последние
+// filter.Months месяцев, с учетом категории, возраста экземпляра и его состояния.
+func (d *Database) GetWeedingCandidates(ctx context.Context, filter models.WeedingFilter) ([]models.WeedingCandidate, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+copyColumns+`, books.title, books.author, books.category,
+			COUNT(loans.id), MAX(loans.borrow_date)
+		FROM copies
+		JOIN books ON books.id = copies.book_id
+		LEFT JOIN loans ON loans.copy_id = copies.id
+		WHERE copies.status = ANY($1)
+			AND ($2 = '' OR books.category = $2)
+			AND copies.acquired_at <= CURRENT_DATE - make_interval(years => $3)
+			AND ($4 = '' OR copies.condition = $4)
+			AND ($5 = 0 OR copies.current_branch_id = $5)
+		GROUP BY copies.id, books.id
+		HAVING MAX(loans.borrow_date) IS NULL OR MAX(loans.borrow_date) < CURRENT_DATE - make_interval(months => $6)
+		ORDER BY MAX(loans.borrow_date) NULLS FIRST, copies.acquired_at`,
+		pq.Array([]string{models.CopyAvailable, models.CopyDamaged}),
+		filter.Category, filter.MinAgeYears, filter.Condition, filter.BranchID, filter.Months)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var candidates []models.WeedingCandidate
+	for rows.Next() {
+		var wc models.WeedingCandidate
+		c := &wc.Copy
+		if err := rows.Scan(&c.ID, &c.BookID, &c.Barcode, &c.HomeBranchID, &c.CurrentBranchID, &c.Status, &c.Price, &c.Shelf,
+			&c.Condition, &c.AcquiredAt, &wc.Title, &wc.Author, &wc.Category, &wc.TotalLoans, &wc.LastLoan); err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, wc)
+	}
+
+	return candidates, rows.Err()
+}
+
+// WithdrawCopies списывает экземпляры: статус меняется на withdrawn, а сведения об
+// экземпляре и его книговыдаче сохраняются в withdrawals для статистики. Строки books
+// не удаляются. Экземпляры на руках, под бронью или в пути списать нельзя.
+func (d *Database) WithdrawCopies(ctx context.Context, copyIDs []int, reason string) ([]models.Withdrawal, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var lockable int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM copies WHERE id = ANY($1) AND status = ANY($2) FOR UPDATE
+		) AS locked`, pq.Array(copyIDs), pq.Array([]string{models.CopyAvailable, models.CopyDamaged})).Scan(&lockable)
+	if err != nil {
+		return nil, err
+	}
+	if lockable != len(copyIDs) {
+		return nil, ErrInvalidState
+	}
+
+	rows, err := tx.QueryContext(ctx, `
+		INSERT INTO withdrawals (copy_id, book_id, branch_id, barcode, title, category, reason, total_loans)
+		SELECT copies.id, copies.book_id, copies.current_branch_id, copies.barcode, books.title, books.category, $2,
+			(SELECT COUNT(*) FROM loans WHERE loans.copy_id = copies.id)
+		FROM copies
+		JOIN books ON books.id = copies.book_id
+		WHERE copies.id = ANY($1)
+		RETURNING `+withdrawalColumns, pq.Array(copyIDs), reason)
+	if err != nil {
+		return nil, err
+	}
+	withdrawals, err := scanWithdrawals(rows)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE copies SET status = $1 WHERE id = ANY($2)", models.CopyWithdrawn, pq.Array(copyIDs)); err != nil {
+		return nil, err
+	}
+	for _, w := range withdrawals {
+		if err := recordEvent(ctx, tx, models.EventCopyWithdrawn, w); err != nil {
+			return nil, err
+		}
+	}
+
+	return withdrawals, tx.Commit()
+}
+
+// GetWithdrawals возвращает журнал списаний; если year не 0 — только за этот год.
+func (d *Database) GetWithdrawals(ctx context.Context, year int) ([]models.Withdrawal, error) {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	rows, err := d.db.QueryContext(ctx, `
+		SELECT `+withdrawalColumns+` FROM withdrawals
+		WHERE $1 = 0 OR EXTRACT(YEAR FROM withdrawn_at) = $1
+		ORDER BY withdrawn_at DESC`, year)
+	if err != nil {
+		return nil, err
+	}
+	return scanWithdrawals(rows)
+}
+
+const withdrawalColumns = "id, copy_id, book_id, branch_id, barcode, title, category, reason, total_loans, withdrawn_at"
+
+func scanWithdrawals(rows *sql.Rows) ([]models.Withdrawal, error) {
+	defer rows.Close()
+
+	var withdrawals []models.Withdrawal
+	for rows.Next() {
+		var w models.Withdrawal
+		if err := rows.Scan(&w.ID, &w.CopyID, &w.BookID, &w.BranchID, &w.Barcode, &w.Title, &w.Category,
+			&w.Reason, &w.TotalLoans, &w.WithdrawnAt); err != nil {
+			return nil, err
+		}
+		withdrawals = append(withdrawals, w)
+	}
+
+	return withdrawals, rows.Err()
+}
+
+// SetCopyCondition обновляет оценку состояния экземпляра.
+func (d *Database) SetCopyCondition(ctx context.Context, copyID int, condition string) error {
+	ctx, cancel := d.withTimeout(ctx)
+	defer cancel()
+
+	res, err := d.db.ExecContext(ctx, "UPDATE copies SET condition = $1 WHERE id = $2", condition, copyID)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// receiveRequest тело запроса на приемку поступления
+type receiveRequest struct {
+	BranchID int                  `json:"branchID"`
+	Lines    []models.ReceiveLine `json:"lines"`
+}
+
+// Vendors Handlers
+
+// GetVendors обрабатывает запрос списка поставщиков
+func (h *Handler) GetVendors(c *gin.Context) {
+	vendors, err := h.service.GetVendors(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, vendors)
+}
+
+// AddVendor обрабатывает добавление поставщика
+func (h *Handler) AddVendor(c *gin.Context) {
+	var vendor models.Vendor
+	if err := c.ShouldBindJSON(&vendor); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newVendor, err := h.service.AddVendor(c.Request.Context(), vendor)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newVendor)
+}
+
+// Funds Handlers
+
+// GetFunds обрабатывает запрос списка фондов с фильтром year
+func (h *Handler) GetFunds(c *gin.Context) {
+	year, err := queryInt(c, "year")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+
+	funds, err := h.service.GetFunds(c.Request.Context(), year)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, funds)
+}
+
+// AddFund обрабатывает добавление фонда с годовым бюджетом
+func (h *Handler) AddFund(c *gin.Context) {
+	var fund models.Fund
+	if err := c.ShouldBindJSON(&fund); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newFund, err := h.service.AddFund(c.Request.Context(), fund)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newFund)
+}
+
+// GetFundReports обрабатывает запрос отчета «потрачено / выделено» по фондам
+func (h *Handler) GetFundReports(c *gin.Context) {
+	year, err := queryInt(c, "year")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+
+	reports, err := h.service.GetFundReports(c.Request.Context(), year)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// Purchase Orders Handlers
+
+// GetOrders обрабатывает запрос списка заказов с фильтром status
+func (h *Handler) GetOrders(c *gin.Context) {
+	orders, err := h.service.GetOrders(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, orders)
+}
+
+// GetOrder обрабатывает запрос заказа со строками
+func (h *Handler) GetOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.service.GetOrder(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// CreateOrder обрабатывает создание черновика заказа
+func (h *Handler) CreateOrder(c *gin.Context) {
+	var order models.PurchaseOrder
+	if err := c.ShouldBindJSON(&order); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newOrder, err := h.service.CreateOrder(c.Request.Context(), order)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newOrder)
+}
+
+// PlaceOrder обрабатывает отправку заказа поставщику
+func (h *Handler) PlaceOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.service.PlaceOrder(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// CancelOrder обрабатывает отмену заказа
+func (h *Handler) CancelOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	order, err := h.service.CancelOrder(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
+
+// ReceiveOrder обрабатывает приемку поступления: создаются книги и экземпляры
+func (h *Handler) ReceiveOrder(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid order ID"})
+		return
+	}
+
+	var req receiveRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	order, err := h.service.ReceiveOrder(c.Request.Context(), id, req.BranchID, req.Lines)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, order)
+}
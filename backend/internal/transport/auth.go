@@ -0,0 +1,44 @@
+package transport
+
+import (
+	"cmd/main.go/internal/auth"
+	"cmd/main.go/pkg/logger"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// contextUserID — ключ gin-контекста с идентификатором сотрудника из токена
+const contextUserID = "userID"
+
+// authRequired пропускает только запросы с действительным токеном сотрудника. Токен
+// передается в заголовке Authorization: Bearer или, для EventSource, который не умеет
+// задавать заголовки, в параметре access_token. Без JWTSecret токены проверить нечем,
+// поэтому такие маршруты недоступны (503), а не открыты всем.
+func (h *Handler) authRequired() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(h.jwtSecret) == 0 {
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Authentication is not configured"})
+			return
+		}
+
+		token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if token == "" || token == c.GetHeader("Authorization") {
+			token = c.Query("access_token")
+		}
+
+		claims, err := auth.Verify(h.jwtSecret, token, time.Now())
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+			return
+		}
+		c.Set(contextUserID, claims.Subject)
+		// Дальнейшие записи лога запроса несут пользователя
+		ctx := c.Request.Context()
+		log := logger.FromContext(ctx).With(zap.String("user_id", claims.Subject))
+		c.Request = c.Request.WithContext(logger.WithLoggerContext(ctx, log))
+		c.Next()
+	}
+}
@@ -0,0 +1,58 @@
+package transport
+
+import (
+	"cmd/main.go/internal/auth"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestAuthRequired(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	token, err := auth.Sign([]byte("secret"), auth.Claims{Subject: "42", ExpiresAt: time.Now().Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		header string
+		query  string
+		want   int
+	}{
+		{"no secret configured", "", "Bearer " + token, "", http.StatusServiceUnavailable},
+		{"no secret and no token", "", "", "", http.StatusServiceUnavailable},
+		{"no token", "secret", "", "", http.StatusUnauthorized},
+		{"bearer token", "secret", "Bearer " + token, "", http.StatusOK},
+		{"query token", "secret", "", "?access_token=" + token, http.StatusOK},
+		{"token without bearer", "secret", token, "", http.StatusUnauthorized},
+		{"token for other secret", "other", "Bearer " + token, "", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Handler{jwtSecret: []byte(tt.secret)}
+			router := gin.New()
+			router.GET("/staff", h.authRequired(), func(c *gin.Context) {
+				c.String(http.StatusOK, c.GetString(contextUserID))
+			})
+
+			req := httptest.NewRequest(http.MethodGet, "/staff"+tt.query, nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusOK && rec.Body.String() != "42" {
+				t.Errorf("user = %q, want 42", rec.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// Branches Handlers
+
+// GetBranches обрабатывает запрос на получение списка филиалов
+func (h *Handler) GetBranches(c *gin.Context) {
+	branches, err := h.service.GetBranches(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, branches)
+}
+
+// AddBranch обрабатывает запрос на добавление филиала
+func (h *Handler) AddBranch(c *gin.Context) {
+	var branch models.Branch
+	if err := c.ShouldBindJSON(&branch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newBranch, err := h.service.AddBranch(c.Request.Context(), branch)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newBranch)
+}
+
+// Copies Handlers
+
+// GetCopies обрабатывает запрос на получение экземпляров с фильтрами book и branch
+func (h *Handler) GetCopies(c *gin.Context) {
+	bookID, err := queryInt(c, "book")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid book ID"})
+		return
+	}
+	branchID, err := queryInt(c, "branch")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	copies, err := h.service.GetCopies(c.Request.Context(), bookID, branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, copies)
+}
+
+// AddCopy обрабатывает запрос на добавление экземпляра книги
+func (h *Handler) AddCopy(c *gin.Context) {
+	var newCopy models.Copy
+	if err := c.ShouldBindJSON(&newCopy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.service.AddCopy(c.Request.Context(), newCopy)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, created)
+}
+
+// Holds Handlers
+
+// GetHolds обрабатывает запрос на получение броней с фильтром branch
+func (h *Handler) GetHolds(c *gin.Context) {
+	branchID, err := queryInt(c, "branch")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	holds, err := h.service.GetHolds(c.Request.Context(), branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, holds)
+}
+
+// PlaceHold обрабатывает запрос на бронирование книги с выдачей в указанном филиале
+func (h *Handler) PlaceHold(c *gin.Context) {
+	var hold models.Hold
+	if err := c.ShouldBindJSON(&hold); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newHold, err := h.service.PlaceHold(c.Request.Context(), hold)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newHold)
+}
+
+// CancelHold обрабатывает запрос на отмену брони
+func (h *Handler) CancelHold(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid hold ID"})
+		return
+	}
+
+	if err := h.service.CancelHold(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Hold cancelled"})
+}
+
+// Transfers Handlers
+
+// GetTransfers обрабатывает запрос на получение перемещений из филиала или в филиал
+func (h *Handler) GetTransfers(c *gin.Context) {
+	branchID, err := queryInt(c, "branch")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	transfers, err := h.service.GetTransfers(c.Request.Context(), branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, transfers)
+}
+
+// RequestTransfer обрабатывает запрос на перемещение экземпляра в другой филиал
+func (h *Handler) RequestTransfer(c *gin.Context) {
+	var transfer models.Transfer
+	if err := c.ShouldBindJSON(&transfer); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newTransfer, err := h.service.RequestTransfer(c.Request.Context(), transfer.CopyID, transfer.ToBranchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newTransfer)
+}
+
+// ShipTransfer обрабатывает отметку об отправке экземпляра
+func (h *Handler) ShipTransfer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	if err := h.service.ShipTransfer(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer shipped"})
+}
+
+// ReceiveTransfer обрабатывает приемку экземпляра в филиале назначения
+func (h *Handler) ReceiveTransfer(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transfer ID"})
+		return
+	}
+
+	if err := h.service.ReceiveTransfer(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Transfer received"})
+}
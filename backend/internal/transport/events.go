@@ -0,0 +1,85 @@
+package transport
+
+import (
+	"cmd/main.go/internal/sse"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// heartbeatInterval — как часто слать комментарий, чтобы прокси не закрывали простаивающий поток
+const heartbeatInterval = 15 * time.Second
+
+// StreamEvents обрабатывает подписку на поток событий выдачи и изменений статистики (SSE).
+// Параметры: types (через запятую), branch. Заголовок Last-Event-ID (или параметр
+// lastEventId) продолжает поток после переподключения.
+func (h *Handler) StreamEvents(c *gin.Context) {
+	filter := sse.Filter{}
+	if types := c.Query("types"); types != "" {
+		filter.Types = strings.Split(types, ",")
+	}
+	var err error
+	if filter.BranchID, err = queryInt(c, "branch"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	lastID := c.GetHeader("Last-Event-ID")
+	if lastID == "" {
+		lastID = c.Query("lastEventId")
+	}
+	var lastEventID uint64
+	if lastID != "" {
+		if lastEventID, err = strconv.ParseUint(lastID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	client, replay, ok := h.hub.Subscribe(filter, lastEventID)
+	defer h.hub.Unsubscribe(client)
+
+	// Поток живет дольше WriteTimeout сервера
+	rc := http.NewResponseController(c.Writer)
+	rc.SetWriteDeadline(time.Time{})
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	if !ok {
+		fmt.Fprintf(c.Writer, "event: %s\ndata: {}\n\n", sse.EventReset)
+	}
+	for _, msg := range replay {
+		writeEvent(c, msg)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case msg, open := <-client.Messages():
+			if !open {
+				return
+			}
+			writeEvent(c, msg)
+			c.Writer.Flush()
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+func writeEvent(c *gin.Context, msg sse.Message) {
+	fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", msg.ID, msg.Event, msg.Data)
+}
@@ -1,32 +1,57 @@
 package transport
 
 import (
+	"cmd/main.go/internal/jobs"
+	"cmd/main.go/internal/metrics"
 	"cmd/main.go/internal/service"
+	"cmd/main.go/internal/sse"
+	"cmd/main.go/internal/storage"
 	"cmd/main.go/models"
-	"github.com/gin-contrib/cors" // Импортируем пакет
+	"context"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
 	"net/http"
 	"strconv"
+	"sync/atomic"
+	"time"
 )
 
 // Handler struct для работы с сервисом
 type Handler struct {
-	service service.Service
+	service   service.Service
+	scheduler *jobs.Scheduler
+	hub       *sse.Hub
+	logLevel  zap.AtomicLevel
+	jwtSecret []byte
+	ready     atomic.Bool
+	origins   atomic.Pointer[[]string]
+	limiter   rateLimiter
 }
 
-// NewHandler создает новый обработчик с сервисом
-func NewHandler(service service.Service) *Handler {
-	return &Handler{service: service}
+// NewHandler создает новый обработчик с сервисом, планировщиком фоновых задач, хабом
+// потока событий и уровнем логирования, который можно менять на лету. Пустой jwtSecret
+// отключает проверку токенов.
+func NewHandler(service service.Service, scheduler *jobs.Scheduler, hub *sse.Hub, logLevel zap.AtomicLevel, jwtSecret string) *Handler {
+	return &Handler{service: service, scheduler: scheduler, hub: hub, logLevel: logLevel, jwtSecret: []byte(jwtSecret)}
 }
 
-// InitRoutes инициализирует маршруты для обработки HTTP запросов
-func (h *Handler) InitRoutes() *gin.Engine {
-	router := gin.Default()
+// InitRoutes инициализирует маршруты для обработки HTTP запросов. Если serveMetrics
+// выключен, /metrics отдается только на служебном порту (InitAdminRoutes).
+func (h *Handler) InitRoutes(serveMetrics bool) *gin.Engine {
+	router := gin.New()
+	router.Use(requestLogging(zap.L()), metrics.Middleware(), recovery())
 
-	// Добавляем CORS middleware
-	router.Use(cors.Default()) // Вы можете настроить CORS здесь, если нужно
+	// Добавляем CORS middleware; список источников задается SetCORSOrigins
+	router.Use(h.corsPolicy())
 
-	api := router.Group("/api")
+	if serveMetrics {
+		router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	}
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+
+	api := router.Group("/api", h.rateLimit())
 
 	// Books
 	books := api.Group("/books")
@@ -40,8 +65,30 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	users := api.Group("/users")
 	{
 		users.GET("", h.GetUsers)
+		users.GET(":id", h.GetUser)
+		users.GET("card/:number", h.GetUserByCard)
 		users.POST("", h.AddUser)
 		users.DELETE(":id", h.DeleteUser)
+		users.POST(":id/renew", h.RenewUser)
+		users.POST(":id/block", h.BlockUser)
+		users.POST(":id/unblock", h.UnblockUser)
+		users.PUT(":id/guardian", h.SetGuardian)
+		users.GET(":id/dependents", h.GetDependents)
+		users.GET(":id/fines", h.GetFines)
+		users.POST(":id/fines", h.AddFine)
+		users.GET(":id/balance", h.GetFineBalance)
+		users.GET(":id/notification-preferences", h.GetNotificationPreferences)
+		users.PUT(":id/notification-preferences", h.SetNotificationPreferences)
+	}
+
+	// Notifications
+	api.GET("/notifications", h.GetNotifications)
+
+	// Loan policies
+	policies := api.Group("/policies")
+	{
+		policies.GET("", h.GetLoanPolicies)
+		policies.PUT(":category", h.SetLoanPolicy)
 	}
 
 	// Loans
@@ -50,10 +97,141 @@ func (h *Handler) InitRoutes() *gin.Engine {
 		loans.GET("", h.GetLoans)
 		loans.POST("", h.IssueLoan)
 		loans.POST(":id/return", h.ReturnLoan)
+		loans.POST(":id/lost", h.DeclareLost)
+		loans.POST(":id/damaged", h.DeclareDamaged)
+		loans.POST(":id/found", h.MarkFound)
+		loans.GET(":id/contacts", h.authRequired(), h.GetContactAttempts)
+		loans.POST(":id/contacts", h.authRequired(), h.AddContactAttempt)
+	}
+
+	// Reports
+	reports := api.Group("/reports", h.authRequired())
+	{
+		reports.GET("overdue", h.GetOverdueReport)
+	}
+
+	// Replacement costs
+	costs := api.Group("/replacement-costs")
+	{
+		costs.GET("", h.GetReplacementCosts)
+		costs.PUT(":category", h.SetReplacementCost)
+	}
+
+	// Branches
+	branches := api.Group("/branches")
+	{
+		branches.GET("", h.GetBranches)
+		branches.POST("", h.AddBranch)
+	}
+
+	// Copies
+	copies := api.Group("/copies")
+	{
+		copies.GET("", h.GetCopies)
+		copies.POST("", h.AddCopy)
+		copies.PUT(":id/condition", h.SetCopyCondition)
+	}
+
+	// Holds
+	holds := api.Group("/holds")
+	{
+		holds.GET("", h.GetHolds)
+		holds.POST("", h.PlaceHold)
+		holds.POST(":id/cancel", h.CancelHold)
+	}
+
+	// Transfers
+	transfers := api.Group("/transfers")
+	{
+		transfers.GET("", h.GetTransfers)
+		transfers.POST("", h.RequestTransfer)
+		transfers.POST(":id/ship", h.ShipTransfer)
+		transfers.POST(":id/receive", h.ReceiveTransfer)
+	}
+
+	// Stocktakes
+	stocktakes := api.Group("/stocktakes")
+	{
+		stocktakes.GET("", h.GetStocktakes)
+		stocktakes.POST("", h.OpenStocktake)
+		stocktakes.POST(":id/scans", h.AddStocktakeScans)
+		stocktakes.GET(":id/report", h.GetStocktakeReport)
+		stocktakes.POST(":id/mark-missing", h.MarkStocktakeMissingLost)
+		stocktakes.POST(":id/close", h.CloseStocktake)
+	}
+
+	// Acquisitions
+	vendors := api.Group("/vendors")
+	{
+		vendors.GET("", h.GetVendors)
+		vendors.POST("", h.AddVendor)
+	}
+
+	funds := api.Group("/funds")
+	{
+		funds.GET("", h.GetFunds)
+		funds.POST("", h.AddFund)
+		funds.GET("report", h.GetFundReports)
+	}
+
+	orders := api.Group("/orders")
+	{
+		orders.GET("", h.GetOrders)
+		orders.GET(":id", h.GetOrder)
+		orders.POST("", h.CreateOrder)
+		orders.POST(":id/place", h.PlaceOrder)
+		orders.POST(":id/cancel", h.CancelOrder)
+		orders.POST(":id/receive", h.ReceiveOrder)
+	}
+
+	// Weeding
+	weeding := api.Group("/weeding")
+	{
+		weeding.GET("candidates", h.GetWeedingCandidates)
+		weeding.POST("withdraw", h.WithdrawCopies)
+		weeding.GET("withdrawals", h.GetWithdrawals)
+	}
+
+	// Webhooks (staff only: responses carry signing secrets)
+	webhooks := api.Group("/webhooks", h.authRequired())
+	{
+		webhooks.GET("", h.GetWebhooks)
+		webhooks.POST("", h.AddWebhook)
+		webhooks.DELETE(":id", h.DeleteWebhook)
+		webhooks.GET(":id/deliveries", h.GetWebhookDeliveries)
+		webhooks.GET("deliveries/:id", h.GetWebhookDelivery)
+		webhooks.POST("deliveries/:id/redeliver", h.RedeliverWebhook)
 	}
 
 	// Statistics
 	api.GET("/statistics", h.GetStatistics)
+	api.GET("/statistics/rollup", h.GetRollupStatistics)
+
+	// Live events
+	api.GET("/events", h.authRequired(), h.StreamEvents)
+
+	// Admin
+	admin := api.Group("/admin", h.authRequired())
+	{
+		admin.GET("jobs", h.GetJobs)
+		admin.GET("jobs/:name/runs", h.GetJobRuns)
+		admin.POST("jobs/:name/run", h.RunJob)
+		admin.GET("outbox", h.GetOutboxConsumers)
+		admin.GET("log-level", h.GetLogLevel)
+		admin.PUT("log-level", h.SetLogLevel)
+	}
+	return router
+}
+
+// InitAdminRoutes инициализирует маршруты служебного порта
+func (h *Handler) InitAdminRoutes() *gin.Engine {
+	router := gin.New()
+	router.Use(requestLogging(zap.L()), recovery())
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	router.GET("/healthz", h.Healthz)
+	router.GET("/readyz", h.Readyz)
+	router.GET("/log-level", h.GetLogLevel)
+	router.PUT("/log-level", h.SetLogLevel)
 	return router
 }
 
@@ -61,9 +239,15 @@ func (h *Handler) InitRoutes() *gin.Engine {
 
 // GetBooks обрабатывает запрос на получение списка книг
 func (h *Handler) GetBooks(c *gin.Context) {
-	books, err := h.service.GetBooks()
+	branchID, err := queryInt(c, "branch")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	books, err := h.service.GetBooks(c.Request.Context(), branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, books)
@@ -77,9 +261,9 @@ func (h *Handler) AddBook(c *gin.Context) {
 		return
 	}
 
-	newBook, err := h.service.AddBook(book)
+	newBook, err := h.service.AddBook(c.Request.Context(), book)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -94,8 +278,8 @@ func (h *Handler) DeleteBook(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteBook(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.DeleteBook(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -106,9 +290,9 @@ func (h *Handler) DeleteBook(c *gin.Context) {
 
 // GetUsers обрабатывает запрос на получение списка пользователей
 func (h *Handler) GetUsers(c *gin.Context) {
-	users, err := h.service.GetUsers()
+	users, err := h.service.GetUsers(c.Request.Context())
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, users)
@@ -122,9 +306,9 @@ func (h *Handler) AddUser(c *gin.Context) {
 		return
 	}
 
-	newUser, err := h.service.AddUser(user)
+	newUser, err := h.service.AddUser(c.Request.Context(), user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -139,8 +323,8 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.service.DeleteUser(id); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := h.service.DeleteUser(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -151,9 +335,15 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 
 // GetLoans обрабатывает запрос на получение списка всех займов
 func (h *Handler) GetLoans(c *gin.Context) {
-	loans, err := h.service.GetLoans()
+	branchID, err := queryInt(c, "branch")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	loans, err := h.service.GetLoans(c.Request.Context(), branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, loans)
@@ -179,10 +369,14 @@ func (h *Handler) IssueLoan(c *gin.Context) {
 		return
 	}
 
-	newLoan, err := h.service.IssueLoan(userID, bookID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	branchID := 0
+	if loan.BranchID != nil {
+		branchID = *loan.BranchID
+	}
 
+	newLoan, err := h.service.IssueLoan(c.Request.Context(), userID, bookID, branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -197,9 +391,9 @@ func (h *Handler) ReturnLoan(c *gin.Context) {
 		return
 	}
 
-	updatedLoan, err := h.service.ReturnLoan(loanID)
+	updatedLoan, err := h.service.ReturnLoan(c.Request.Context(), loanID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
@@ -208,12 +402,113 @@ func (h *Handler) ReturnLoan(c *gin.Context) {
 
 // Statistics Handler
 
-// GetStatistics обрабатывает запрос на получение статистики по библиотеке
+// GetStatistics обрабатывает запрос на получение статистики по библиотеке. С параметрами
+// from, to или granularity отдает статистику за период по всей истории выдач.
 func (h *Handler) GetStatistics(c *gin.Context) {
-	stats, err := h.service.GetStatistics()
+	if c.Query("from") != "" || c.Query("to") != "" || c.Query("granularity") != "" {
+		h.getTrendStatistics(c)
+		return
+	}
+
+	stats, err := h.service.GetStatistics(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+func (h *Handler) getTrendStatistics(c *gin.Context) {
+	period, ok := statisticsPeriod(c)
+	if !ok {
+		return
+	}
+
+	stats, err := h.service.GetTrendStatistics(c.Request.Context(), period)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 	c.JSON(http.StatusOK, stats)
 }
+
+// GetRollupStatistics обрабатывает запрос статистики за период по дневным сводкам выдач
+func (h *Handler) GetRollupStatistics(c *gin.Context) {
+	period, ok := statisticsPeriod(c)
+	if !ok {
+		return
+	}
+	branchID, err := queryInt(c, "branch")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	stats, err := h.service.GetRollupStatistics(c.Request.Context(), period, branchID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stats)
+}
+
+// statisticsPeriod читает период статистики из параметров from, to и granularity;
+// при ошибке отвечает 400 и возвращает false
+func statisticsPeriod(c *gin.Context) (models.StatisticsPeriod, bool) {
+	period := models.StatisticsPeriod{Granularity: c.Query("granularity")}
+
+	var err error
+	if period.From, err = queryDate(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from date, expected YYYY-MM-DD"})
+		return period, false
+	}
+	if period.To, err = queryDate(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to date, expected YYYY-MM-DD"})
+		return period, false
+	}
+	return period, true
+}
+
+// queryDate читает необязательную дату в формате YYYY-MM-DD; отсутствие параметра дает нулевое время
+func queryDate(c *gin.Context, key string) (time.Time, error) {
+	value := c.Query(key)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// queryInt читает необязательный целочисленный параметр запроса; отсутствие параметра дает 0
+func queryInt(c *gin.Context, key string) (int, error) {
+	value := c.Query(key)
+	if value == "" {
+		return 0, nil
+	}
+	return strconv.Atoi(value)
+}
+
+// statusClientClosedRequest — нестандартный статус (как в nginx) для запроса, клиент
+// которого отключился раньше, чем получил ответ
+const statusClientClosedRequest = 499
+
+// errorStatus подбирает HTTP-статус для ошибки сервиса
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return statusClientClosedRequest
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, service.ErrValidation), errors.Is(err, storage.ErrUnknownCategory), errors.Is(err, storage.ErrGuardianRequired),
+		errors.Is(err, storage.ErrInvalidGuardian), errors.Is(err, storage.ErrSelfGuardian):
+		return http.StatusBadRequest
+	case errors.Is(err, storage.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, storage.ErrPatronBlocked), errors.Is(err, storage.ErrMembershipExpired), errors.Is(err, storage.ErrRestrictedCategory):
+		return http.StatusForbidden
+	case errors.Is(err, storage.ErrNoCopyAvailable), errors.Is(err, storage.ErrInvalidState), errors.Is(err, storage.ErrLoanLimit),
+		errors.Is(err, storage.ErrNoReplacementCost):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readyTimeout ограничивает проверку зависимостей, чтобы зависшая база не держала пробу
+const readyTimeout = 2 * time.Second
+
+// SetReady включает или выключает готовность принимать трафик. Приложение включает ее
+// после запуска и выключает в начале остановки, чтобы балансировщик успел снять трафик.
+func (h *Handler) SetReady(ready bool) {
+	h.ready.Store(ready)
+}
+
+// Healthz обрабатывает проверку живости: процесс запущен и отвечает
+func (h *Handler) Healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readyz обрабатывает проверку готовности: база отвечает, схема нужной версии, фоновые
+// задачи работают и приложение не останавливается. Ответ 503 перечисляет, что не так.
+func (h *Handler) Readyz(c *gin.Context) {
+	checks := gin.H{}
+	ready := true
+	fail := func(name string, err error) {
+		checks[name] = err.Error()
+		ready = false
+	}
+
+	if h.ready.Load() {
+		checks["lifecycle"] = "ok"
+	} else {
+		checks["lifecycle"] = "not accepting traffic"
+		ready = false
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readyTimeout)
+	defer cancel()
+	if err := h.service.CheckDatabase(ctx); err != nil {
+		fail("database", err)
+	} else {
+		checks["database"] = "ok"
+	}
+
+	if h.scheduler != nil {
+		if err := h.scheduler.Healthy(); err != nil {
+			fail("jobs", err)
+		} else {
+			checks["jobs"] = "ok"
+		}
+	}
+
+	if !ready {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "not ready", "checks": checks})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ready", "checks": checks})
+}
@@ -0,0 +1,70 @@
+package transport
+
+import (
+	"cmd/main.go/internal/jobs"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"net/http"
+)
+
+// jobHistoryLimit — сколько последних запусков показывать по умолчанию
+const jobHistoryLimit = 10
+
+// GetJobs обрабатывает запрос списка фоновых задач с расписанием и последними запусками
+func (h *Handler) GetJobs(c *gin.Context) {
+	list, err := h.scheduler.Jobs(c.Request.Context(), jobHistoryLimit)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, list)
+}
+
+// GetJobRuns обрабатывает запрос истории запусков задачи; limit по умолчанию 10
+func (h *Handler) GetJobRuns(c *gin.Context) {
+	limit, err := queryInt(c, "limit")
+	if err != nil || limit < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	if limit == 0 {
+		limit = jobHistoryLimit
+	}
+
+	runs, err := h.scheduler.Runs(c.Request.Context(), c.Param("name"), limit)
+	if err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// RunJob обрабатывает ручной запуск задачи вне расписания
+func (h *Handler) RunJob(c *gin.Context) {
+	if err := h.scheduler.Trigger(c.Param("name")); err != nil {
+		c.JSON(jobErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job started"})
+}
+
+// GetOutboxConsumers обрабатывает запрос смещений подписчиков outbox и их отставания
+func (h *Handler) GetOutboxConsumers(c *gin.Context) {
+	consumers, err := h.service.GetOutboxConsumers(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, consumers)
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, jobs.ErrUnknownJob):
+		return http.StatusNotFound
+	case errors.Is(err, jobs.ErrJobBusy):
+		return http.StatusConflict
+	default:
+		return errorStatus(err)
+	}
+}
@@ -0,0 +1,38 @@
+package transport
+
+import (
+	"cmd/main.go/pkg/logger"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// logLevelRequest — тело запроса смены уровня логирования
+type logLevelRequest struct {
+	Level string `json:"level"`
+}
+
+// GetLogLevel обрабатывает запрос текущего уровня логирования
+func (h *Handler) GetLogLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": h.logLevel.String()})
+}
+
+// SetLogLevel обрабатывает смену уровня логирования без перезапуска: debug, info, warn или error.
+// Уровень действует до следующей смены или перезапуска.
+func (h *Handler) SetLogLevel(c *gin.Context) {
+	var req logLevelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	previous := h.logLevel.Level()
+	if req.Level == "" || h.logLevel.UnmarshalText([]byte(req.Level)) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid level, expected debug, info, warn or error"})
+		return
+	}
+
+	logger.FromContext(c.Request.Context()).Warn("Log level changed",
+		zap.Stringer("from", previous), zap.Stringer("to", h.logLevel.Level()))
+	c.JSON(http.StatusOK, gin.H{"level": h.logLevel.String()})
+}
@@ -0,0 +1,84 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// DeclareLost обрабатывает утерю экземпляра по займу с начислением стоимости замены
+func (h *Handler) DeclareLost(c *gin.Context) {
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	fine, err := h.service.DeclareLost(c.Request.Context(), loanID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fine)
+}
+
+// DeclareDamaged обрабатывает порчу экземпляра по займу с начислением стоимости замены
+func (h *Handler) DeclareDamaged(c *gin.Context) {
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	fine, err := h.service.DeclareDamaged(c.Request.Context(), loanID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fine)
+}
+
+// MarkFound обрабатывает находку утерянного экземпляра с возвратом начисления
+func (h *Handler) MarkFound(c *gin.Context) {
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	refund, err := h.service.MarkFound(c.Request.Context(), loanID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, refund)
+}
+
+// Replacement Costs Handlers
+
+// GetReplacementCosts обрабатывает запрос стоимости замены по категориям
+func (h *Handler) GetReplacementCosts(c *gin.Context) {
+	costs, err := h.service.GetReplacementCosts(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, costs)
+}
+
+// SetReplacementCost обрабатывает изменение стоимости замены для категории
+func (h *Handler) SetReplacementCost(c *gin.Context) {
+	var cost models.ReplacementCost
+	if err := c.ShouldBindJSON(&cost); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	cost.Category = c.Param("category")
+
+	if err := h.service.SetReplacementCost(c.Request.Context(), cost); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, cost)
+}
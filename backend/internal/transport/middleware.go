@@ -0,0 +1,125 @@
+package transport
+
+import (
+	"cmd/main.go/pkg/logger"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"slices"
+	"time"
+
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// requestIDHeader — заголовок с идентификатором запроса; переданный клиентом или прокси
+// идентификатор сохраняется, иначе генерируется новый
+const requestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength ограничивает чужой идентификатор, чтобы он не раздувал логи
+const maxRequestIDLength = 128
+
+// requestLogging присваивает запросу идентификатор, кладет в контекст запроса логгер с
+// этим идентификатором и после ответа пишет одну структурированную запись о запросе.
+func requestLogging(base *zap.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		requestID := c.GetHeader(requestIDHeader)
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		c.Header(requestIDHeader, requestID)
+
+		log := base.With(zap.String("request_id", requestID))
+		c.Request = c.Request.WithContext(logger.WithLoggerContext(c.Request.Context(), log))
+
+		c.Next()
+
+		status := c.Writer.Status()
+		fields := []zap.Field{
+			zap.String("method", c.Request.Method),
+			zap.String("route", c.FullPath()),
+			zap.String("path", c.Request.URL.Path),
+			zap.Int("status", status),
+			zap.Duration("latency", time.Since(start)),
+			zap.String("client_ip", c.ClientIP()),
+			zap.Int("size", c.Writer.Size()),
+		}
+		if userID := c.GetString(contextUserID); userID != "" {
+			fields = append(fields, zap.String("user_id", userID))
+		}
+		if len(c.Errors) > 0 {
+			fields = append(fields, zap.String("errors", c.Errors.String()))
+		}
+
+		switch {
+		case errors.Is(c.Request.Context().Err(), context.Canceled):
+			// Клиент отключился; работа с базой уже отменена вместе с контекстом запроса
+			log.Info("Request canceled by client", fields...)
+		case status >= http.StatusInternalServerError:
+			log.Error("Request failed", fields...)
+		case status >= http.StatusBadRequest:
+			log.Warn("Request rejected", fields...)
+		default:
+			log.Info("Request handled", fields...)
+		}
+	}
+}
+
+// recovery перехватывает панику обработчика, пишет ее со стеком в лог запроса и отвечает 500.
+func recovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			r := recover()
+			if r == nil {
+				return
+			}
+			// Так net/http прерывает ответ намеренно — это не ошибка обработчика
+			if r == http.ErrAbortHandler {
+				panic(r)
+			}
+			logger.FromContext(c.Request.Context()).Error("Panic while handling request", zap.Any("panic", r), zap.Stack("stack"))
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}()
+		c.Next()
+	}
+}
+
+// SetCORSOrigins задает источники, которым разрешено обращаться к API из браузера; пустой
+// список разрешает любые. Действует сразу, без перезапуска.
+func (h *Handler) SetCORSOrigins(origins []string) {
+	h.origins.Store(&origins)
+}
+
+// corsPolicy — CORS с настройками по умолчанию, но со списком источников, который
+// можно менять на лету
+func (h *Handler) corsPolicy() gin.HandlerFunc {
+	config := cors.DefaultConfig()
+	config.AllowOriginFunc = func(origin string) bool {
+		origins := h.origins.Load()
+		return origins == nil || len(*origins) == 0 || slices.Contains(*origins, origin)
+	}
+	return cors.New(config)
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.' || r == ':') {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
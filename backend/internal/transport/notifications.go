@@ -0,0 +1,63 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// GetNotificationPreferences обрабатывает запрос настроек уведомлений читателя
+func (h *Handler) GetNotificationPreferences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	prefs, err := h.service.GetNotificationPreferences(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// SetNotificationPreferences обрабатывает изменение языка уведомлений и отказ от них
+func (h *Handler) SetNotificationPreferences(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var prefs models.NotificationPreferences
+	if err := c.ShouldBindJSON(&prefs); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	prefs.UserID = id
+
+	prefs, err = h.service.SetNotificationPreferences(c.Request.Context(), prefs)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, prefs)
+}
+
+// GetNotifications обрабатывает запрос журнала уведомлений с фильтрами status и user
+func (h *Handler) GetNotifications(c *gin.Context) {
+	userID, err := queryInt(c, "user")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	notifications, err := h.service.GetNotifications(c.Request.Context(), c.Query("status"), userID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, notifications)
+}
@@ -0,0 +1,222 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// statusRequest тело запроса на блокировку читателя
+type statusRequest struct {
+	Status string `json:"status"`
+	Reason string `json:"reason"`
+}
+
+// GetUser обрабатывает запрос на получение читателя по ID
+func (h *Handler) GetUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.service.GetUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetUserByCard обрабатывает поиск читателя по номеру билета (сканер штрихкодов)
+func (h *Handler) GetUserByCard(c *gin.Context) {
+	user, err := h.service.GetUserByCard(c.Request.Context(), c.Param("number"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// RenewUser обрабатывает продление читательского билета
+func (h *Handler) RenewUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.service.RenewUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// BlockUser обрабатывает блокировку или приостановку читателя
+func (h *Handler) BlockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req statusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.BlockUser(c.Request.Context(), id, req.Status, req.Reason)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// UnblockUser обрабатывает снятие блокировки с читателя
+func (h *Handler) UnblockUser(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	user, err := h.service.UnblockUser(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// Loan Policies Handlers
+
+// GetLoanPolicies обрабатывает запрос на получение политик выдачи по категориям
+func (h *Handler) GetLoanPolicies(c *gin.Context) {
+	policies, err := h.service.GetLoanPolicies(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// SetLoanPolicy обрабатывает изменение политики выдачи для категории
+func (h *Handler) SetLoanPolicy(c *gin.Context) {
+	var policy models.LoanPolicy
+	if err := c.ShouldBindJSON(&policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	policy.Category = c.Param("category")
+
+	if err := h.service.SetLoanPolicy(c.Request.Context(), policy); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, policy)
+}
+
+// guardianRequest тело запроса на привязку к ответственному взрослому
+type guardianRequest struct {
+	GuardianID *int `json:"guardianID"`
+}
+
+// SetGuardian обрабатывает привязку читателя к ответственному взрослому
+func (h *Handler) SetGuardian(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var req guardianRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	user, err := h.service.SetGuardian(c.Request.Context(), id, req.GuardianID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
+
+// GetDependents обрабатывает запрос опекуна на просмотр займов и броней подопечных
+func (h *Handler) GetDependents(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	dependents, err := h.service.GetDependents(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, dependents)
+}
+
+// Fines Handlers
+
+// GetFines обрабатывает запрос на получение журнала штрафов читателя и подопечных
+func (h *Handler) GetFines(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	fines, err := h.service.GetFines(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, fines)
+}
+
+// AddFine обрабатывает начисление штрафа или прием оплаты
+func (h *Handler) AddFine(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	var fine models.Fine
+	if err := c.ShouldBindJSON(&fine); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	fine.UserID = id
+
+	newFine, err := h.service.AddFine(c.Request.Context(), fine)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newFine)
+}
+
+// GetFineBalance обрабатывает запрос задолженности читателя с учетом подопечных
+func (h *Handler) GetFineBalance(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid user ID"})
+		return
+	}
+
+	balance, err := h.service.GetFineBalance(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, balance)
+}
@@ -0,0 +1,81 @@
+package transport
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// limiterSweep — как часто удалять корзины клиентов, которые давно не присылали запросов
+const limiterSweep = time.Minute
+
+// rateLimiter ограничивает частоту запросов с одного адреса по алгоритму token bucket.
+// Нулевое значение ничего не ограничивает.
+type rateLimiter struct {
+	mu        sync.Mutex
+	rate      float64 // запросов в секунду; 0 — без ограничения
+	burst     float64
+	clients   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// SetRateLimit задает допустимую частоту запросов к API с одного адреса: rate в секунду
+// с всплесками до burst. rate 0 снимает ограничение. Действует сразу, без перезапуска.
+func (h *Handler) SetRateLimit(rate, burst int) {
+	h.limiter.mu.Lock()
+	defer h.limiter.mu.Unlock()
+	h.limiter.rate, h.limiter.burst = float64(rate), float64(burst)
+	h.limiter.clients = make(map[string]*bucket)
+}
+
+// allow списывает один запрос клиента key, если в его корзине есть запас
+func (l *rateLimiter) allow(key string, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+
+	if now.Sub(l.lastSweep) > limiterSweep {
+		// Корзина, которая успела бы наполниться до краев, ничем не отличается от новой
+		full := time.Duration(l.burst / l.rate * float64(time.Second))
+		for k, b := range l.clients {
+			if now.Sub(b.last) > full {
+				delete(l.clients, k)
+			}
+		}
+		l.lastSweep = now
+	}
+
+	b, ok := l.clients[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.clients[key] = b
+	}
+	b.tokens = min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimit отвечает 429, если клиент превысил допустимую частоту запросов
+func (h *Handler) rateLimit() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !h.limiter.allow(c.ClientIP(), time.Now()) {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": "Too many requests"})
+			return
+		}
+		c.Next()
+	}
+}
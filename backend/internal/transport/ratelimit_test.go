@@ -0,0 +1,73 @@
+package transport
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateLimiterAllow(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	type request struct {
+		key   string
+		after time.Duration // от start
+		want  bool
+	}
+	tests := []struct {
+		name     string
+		rate     float64
+		burst    float64
+		requests []request
+	}{
+		{"no limit", 0, 0, []request{
+			{"a", 0, true}, {"a", 0, true}, {"a", 0, true},
+		}},
+		{"burst then reject", 1, 2, []request{
+			{"a", 0, true}, {"a", 0, true}, {"a", 0, false},
+		}},
+		{"refill over time", 2, 1, []request{
+			{"a", 0, true},
+			{"a", 100 * time.Millisecond, false},
+			{"a", 500 * time.Millisecond, true},
+		}},
+		{"refill capped at burst", 10, 2, []request{
+			{"a", 0, true}, {"a", 0, true},
+			{"a", time.Hour, true}, {"a", time.Hour, true}, {"a", time.Hour, false},
+		}},
+		{"clients are independent", 1, 1, []request{
+			{"a", 0, true}, {"a", 0, false}, {"b", 0, true},
+		}},
+		{"sweep keeps partial buckets", 0.01, 2, []request{
+			{"a", 0, true}, {"a", 0, true},
+			// Прошло больше limiterSweep, но корзина a еще не наполнилась
+			{"b", 2 * time.Minute, true},
+			{"a", 2 * time.Minute, true},
+			{"a", 2 * time.Minute, false},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := &rateLimiter{rate: tt.rate, burst: tt.burst, clients: make(map[string]*bucket), lastSweep: start}
+			for i, r := range tt.requests {
+				if got := l.allow(r.key, start.Add(r.after)); got != r.want {
+					t.Errorf("request %d (%s at +%s): allow = %v, want %v", i, r.key, r.after, got, r.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	start := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	l := &rateLimiter{rate: 1, burst: 5, clients: make(map[string]*bucket), lastSweep: start}
+	l.allow("idle", start)
+	l.allow("busy", start.Add(time.Minute))
+	l.allow("new", start.Add(time.Minute+time.Second))
+
+	if _, ok := l.clients["idle"]; ok {
+		t.Error("idle bucket was not swept")
+	}
+	if _, ok := l.clients["busy"]; !ok {
+		t.Error("recent bucket was swept")
+	}
+}
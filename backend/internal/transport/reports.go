@@ -0,0 +1,165 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+type contactRequest struct {
+	Method string `json:"method"`
+	Note   string `json:"note"`
+}
+
+// overdueHTML — версия отчета для печати
+var overdueHTML = template.Must(template.New("overdue").Parse(`<!DOCTYPE html>
+<html lang="ru">
+<head>
+<meta charset="utf-8">
+<title>Просроченные выдачи на {{.Date}}</title>
+<style>
+body { font-family: sans-serif; font-size: 12px; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 16px; page-break-inside: avoid; }
+th, td { border: 1px solid #999; padding: 4px; text-align: left; vertical-align: top; }
+h2 { font-size: 14px; margin: 16px 0 4px; }
+</style>
+</head>
+<body>
+<h1>Просроченные выдачи на {{.Date}}</h1>
+{{range .Patrons}}
+<h2>{{.Name}} — билет {{.CardNumber}}</h2>
+<p>Телефон: {{.Phone}} · Email: {{.Email}} · Адрес: {{.Address}}</p>
+<p>Начислено за просрочку: {{printf "%.2f" .AccruedFines}} · Задолженность по штрафам: {{printf "%.2f" .Balance}}</p>
+<table>
+<tr><th>Книга</th><th>Штрихкод</th><th>Выдана</th><th>Срок</th><th>Дней просрочки</th><th>Начислено</th><th>Связь</th></tr>
+{{range .Loans}}
+<tr>
+<td>{{.Title}}, {{.Author}}</td>
+<td>{{.Barcode}}</td>
+<td>{{.BorrowDate}}</td>
+<td>{{.DueDate}}{{if .DueEstimated}}*{{end}}</td>
+<td>{{.DaysOverdue}}</td>
+<td>{{printf "%.2f" .AccruedFine}}</td>
+<td>{{range .Contacts}}{{.CreatedAt}}: {{.Method}}{{if .Note}} ({{.Note}}){{end}}<br>{{end}}</td>
+</tr>
+{{end}}
+</table>
+{{else}}
+<p>Просроченных выдач нет.</p>
+{{end}}
+<p>* Срок не был указан при выдаче и рассчитан по сроку выдачи категории читателя.</p>
+</body>
+</html>
+`))
+
+// GetOverdueReport обрабатывает запрос списка читателей с просроченными займами.
+// Параметр format=csv или format=html отдает отчет для выгрузки или печати.
+func (h *Handler) GetOverdueReport(c *gin.Context) {
+	filter := models.OverdueFilter{Category: c.Query("category")}
+
+	var err error
+	if filter.BranchID, err = queryInt(c, "branch"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+	if filter.MinDays, err = queryInt(c, "minDays"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minDays"})
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" && format != "html" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid format, expected json, csv or html"})
+		return
+	}
+
+	report, err := h.service.GetOverdueReport(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	switch format {
+	case "csv":
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="overdue-%s.csv"`, report.Date))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		writeOverdueCSV(c.Writer, report)
+	case "html":
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		if err := overdueHTML.Execute(c.Writer, report); err != nil {
+			c.Error(err)
+		}
+	default:
+		c.JSON(http.StatusOK, report)
+	}
+}
+
+// writeOverdueCSV пишет отчет по строке на займ с контактами читателя в каждой строке
+func writeOverdueCSV(w http.ResponseWriter, report models.OverdueReport) {
+	out := csv.NewWriter(w)
+	out.Write([]string{"patron", "card", "category", "phone", "email", "address", "fines balance",
+		"loan", "title", "author", "barcode", "borrowed", "due", "due estimated", "days overdue", "accrued fine",
+		"contacts", "last contact"})
+	for _, p := range report.Patrons {
+		for _, l := range p.Loans {
+			last := ""
+			if n := len(l.Contacts); n > 0 {
+				last = l.Contacts[n-1].CreatedAt + " " + l.Contacts[n-1].Method
+			}
+			out.Write([]string{p.Name, p.CardNumber, p.Category, p.Phone, p.Email, p.Address, strconv.FormatFloat(p.Balance, 'f', 2, 64),
+				strconv.Itoa(l.LoanID), l.Title, l.Author, l.Barcode, l.BorrowDate, l.DueDate, strconv.FormatBool(l.DueEstimated),
+				strconv.Itoa(l.DaysOverdue), strconv.FormatFloat(l.AccruedFine, 'f', 2, 64),
+				strconv.Itoa(len(l.Contacts)), last})
+		}
+	}
+	out.Flush()
+}
+
+// GetContactAttempts обрабатывает запрос попыток связаться с читателем по займу
+func (h *Handler) GetContactAttempts(c *gin.Context) {
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	contacts, err := h.service.GetContactAttempts(c.Request.Context(), loanID)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, contacts)
+}
+
+// AddContactAttempt обрабатывает запись звонка, письма или отправленного уведомления
+// по просроченному займу. Сотрудник берется из токена, если он передан.
+func (h *Handler) AddContactAttempt(c *gin.Context) {
+	loanID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid loan ID"})
+		return
+	}
+
+	var req contactRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	attempt, err := h.service.AddContactAttempt(c.Request.Context(), models.ContactAttempt{
+		LoanID: loanID,
+		Method: req.Method,
+		Note:   req.Note,
+		Staff:  c.GetString(contextUserID),
+	})
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, attempt)
+}
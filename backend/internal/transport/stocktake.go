@@ -0,0 +1,108 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// scansRequest тело запроса с пачкой отсканированных штрихкодов
+type scansRequest struct {
+	Barcodes []string `json:"barcodes"`
+}
+
+// GetStocktakes обрабатывает запрос списка сессий инвентаризации
+func (h *Handler) GetStocktakes(c *gin.Context) {
+	stocktakes, err := h.service.GetStocktakes(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, stocktakes)
+}
+
+// OpenStocktake обрабатывает открытие сессии инвентаризации филиала или диапазона полок
+func (h *Handler) OpenStocktake(c *gin.Context) {
+	var st models.Stocktake
+	if err := c.ShouldBindJSON(&st); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	newStocktake, err := h.service.OpenStocktake(c.Request.Context(), st)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, newStocktake)
+}
+
+// AddStocktakeScans обрабатывает пачку штрихкодов со сканера
+func (h *Handler) AddStocktakeScans(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	var req scansRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	added, err := h.service.AddStocktakeScans(c.Request.Context(), id, req.Barcodes)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"received": len(req.Barcodes), "added": added})
+}
+
+// GetStocktakeReport обрабатывает запрос отчета о сверке
+func (h *Handler) GetStocktakeReport(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	report, err := h.service.GetStocktakeReport(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// MarkStocktakeMissingLost обрабатывает списание ненайденных экземпляров как утерянных
+func (h *Handler) MarkStocktakeMissingLost(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	marked, err := h.service.MarkStocktakeMissingLost(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"markedLost": marked})
+}
+
+// CloseStocktake обрабатывает закрытие сессии инвентаризации
+func (h *Handler) CloseStocktake(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stocktake ID"})
+		return
+	}
+
+	if err := h.service.CloseStocktake(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Stocktake closed"})
+}
@@ -0,0 +1,97 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// GetWebhooks обрабатывает запрос списка подписок на вебхуки
+func (h *Handler) GetWebhooks(c *gin.Context) {
+	subs, err := h.service.GetWebhooks(c.Request.Context())
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, subs)
+}
+
+// AddWebhook обрабатывает создание подписки; секрет возвращается только в этом ответе
+func (h *Handler) AddWebhook(c *gin.Context) {
+	var sub models.WebhookSubscription
+	if err := c.ShouldBindJSON(&sub); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	created, err := h.service.AddWebhook(c.Request.Context(), sub)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// DeleteWebhook обрабатывает удаление подписки вместе с ее очередью доставок
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	if err := h.service.DeleteWebhook(c.Request.Context(), id); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries обрабатывает запрос журнала доставок подписки с фильтром status
+func (h *Handler) GetWebhookDeliveries(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return
+	}
+
+	deliveries, err := h.service.GetWebhookDeliveries(c.Request.Context(), id, c.Query("status"))
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetWebhookDelivery обрабатывает запрос доставки с журналом попыток
+func (h *Handler) GetWebhookDelivery(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.service.GetWebhookDelivery(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, delivery)
+}
+
+// RedeliverWebhook обрабатывает повторную отправку доставки
+func (h *Handler) RedeliverWebhook(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid delivery ID"})
+		return
+	}
+
+	delivery, err := h.service.RedeliverWebhook(c.Request.Context(), id)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusAccepted, delivery)
+}
@@ -0,0 +1,102 @@
+package transport
+
+import (
+	"cmd/main.go/models"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"strconv"
+)
+
+// withdrawRequest тело запроса на списание экземпляров
+type withdrawRequest struct {
+	CopyIDs []int  `json:"copyIDs"`
+	Reason  string `json:"reason"`
+}
+
+// conditionRequest тело запроса на изменение состояния экземпляра
+type conditionRequest struct {
+	Condition string `json:"condition"`
+}
+
+// GetWeedingCandidates обрабатывает отчет о редко выдаваемых экземплярах.
+// Параметры: months (обязательный), category, minAge (лет), condition, branch
+func (h *Handler) GetWeedingCandidates(c *gin.Context) {
+	filter := models.WeedingFilter{
+		Category:  c.Query("category"),
+		Condition: c.Query("condition"),
+	}
+
+	var err error
+	if filter.Months, err = queryInt(c, "months"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid months"})
+		return
+	}
+	if filter.MinAgeYears, err = queryInt(c, "minAge"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid minAge"})
+		return
+	}
+	if filter.BranchID, err = queryInt(c, "branch"); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid branch ID"})
+		return
+	}
+
+	candidates, err := h.service.GetWeedingCandidates(c.Request.Context(), filter)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, candidates)
+}
+
+// WithdrawCopies обрабатывает пакетное списание экземпляров
+func (h *Handler) WithdrawCopies(c *gin.Context) {
+	var req withdrawRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	withdrawals, err := h.service.WithdrawCopies(c.Request.Context(), req.CopyIDs, req.Reason)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, withdrawals)
+}
+
+// GetWithdrawals обрабатывает запрос журнала списаний с фильтром year
+func (h *Handler) GetWithdrawals(c *gin.Context) {
+	year, err := queryInt(c, "year")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid year"})
+		return
+	}
+
+	withdrawals, err := h.service.GetWithdrawals(c.Request.Context(), year)
+	if err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, withdrawals)
+}
+
+// SetCopyCondition обрабатывает изменение состояния экземпляра
+func (h *Handler) SetCopyCondition(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid copy ID"})
+		return
+	}
+
+	var req conditionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.service.SetCopyCondition(c.Request.Context(), id, req.Condition); err != nil {
+		c.JSON(errorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Condition updated"})
+}
@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"syscall"
+)
+
+// ErrForbiddenAddress возвращается для подписчика, адрес которого не публичный: петлевой,
+// локальный для канала или из частной сети. Иначе вебхук можно было бы направить на
+// внутренние сервисы.
+var ErrForbiddenAddress = errors.New("webhook address is not public")
+
+// sharedAddressSpace — 100.64.0.0/10 (RFC 6598), адреса провайдерского NAT
+var sharedAddressSpace = netip.MustParsePrefix("100.64.0.0/10")
+
+// publicAddr сообщает, можно ли отправлять вебхуки на адрес ip
+func publicAddr(ip netip.Addr) bool {
+	ip = ip.Unmap()
+	return ip.IsGlobalUnicast() && !ip.IsPrivate() && !sharedAddressSpace.Contains(ip)
+}
+
+// CheckURL проверяет адрес подписки: абсолютный https URL, все адреса хоста которого
+// публичные. Проверка при регистрации лишь сообщает об ошибке сразу; при доставке
+// адрес проверяется еще раз уже после разрешения имени (см. dialControl).
+func CheckURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Hostname() == "" {
+		return errors.New("url must be an absolute https URL")
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return fmt.Errorf("resolve %s: %w", u.Hostname(), err)
+	}
+	for _, ip := range addrs {
+		if !publicAddr(ip) {
+			return fmt.Errorf("%w: %s resolves to %s", ErrForbiddenAddress, u.Hostname(), ip)
+		}
+	}
+	return nil
+}
+
+// dialControl запрещает соединения с непубличными адресами. Она вызывается для уже
+// разрешенного адреса, поэтому смена DNS-записи после регистрации подписки не помогает.
+func dialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil || !publicAddr(ip) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, host)
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"testing"
+)
+
+func TestPublicAddr(t *testing.T) {
+	tests := []struct {
+		addr string
+		want bool
+	}{
+		{"93.184.215.14", true},
+		{"2606:2800:21f:cb07:6820:80da:af6b:8b2c", true},
+		{"::ffff:93.184.215.14", true},
+		{"127.0.0.1", false},
+		{"::1", false},
+		{"::ffff:127.0.0.1", false},
+		{"0.0.0.0", false},
+		{"10.0.0.1", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"100.64.0.1", false},
+		{"169.254.169.254", false},
+		{"fe80::1", false},
+		{"fd00::1", false},
+		{"224.0.0.1", false},
+		{"255.255.255.255", false},
+	}
+
+	for _, tt := range tests {
+		if got := publicAddr(netip.MustParseAddr(tt.addr)); got != tt.want {
+			t.Errorf("publicAddr(%s) = %v, want %v", tt.addr, got, tt.want)
+		}
+	}
+}
+
+// Адреса в тестах — IP-литералы, поэтому DNS не нужен
+func TestCheckURL(t *testing.T) {
+	tests := []struct {
+		name          string
+		url           string
+		wantErr       bool
+		wantForbidden bool
+	}{
+		{"public https", "https://93.184.215.14/hook", false, false},
+		{"public ipv6 with port", "https://[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:8443/hook", false, false},
+		{"plain http", "http://93.184.215.14/hook", true, false},
+		{"relative", "/hook", true, false},
+		{"no host", "https:///hook", true, false},
+		{"not a url", "https://%zz", true, false},
+		{"loopback", "https://127.0.0.1/hook", true, true},
+		{"private", "https://192.168.0.10/hook", true, true},
+		{"metadata service", "https://169.254.169.254/latest", true, true},
+		{"ipv6 loopback", "https://[::1]/hook", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckURL(context.Background(), tt.url)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if errors.Is(err, ErrForbiddenAddress) != tt.wantForbidden {
+				t.Errorf("err = %v, want forbidden %v", err, tt.wantForbidden)
+			}
+		})
+	}
+}
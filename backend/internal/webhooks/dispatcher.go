@@ -0,0 +1,179 @@
+package webhooks
+
+import (
+	"bytes"
+	"cmd/main.go/models"
+	"cmd/main.go/pkg/logger"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Заголовки запроса доставки
+const (
+	HeaderEvent     = "X-Library-Event"
+	HeaderDelivery  = "X-Library-Delivery"
+	HeaderSignature = "X-Library-Signature-256"
+)
+
+const (
+	// maxAttempts — после стольких неудачных попыток доставка помечается failed
+	maxAttempts = 10
+	// baseBackoff — задержка перед второй попыткой; дальше удваивается
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+	// batchSize — сколько доставок отправлять за один проход
+	batchSize = 100
+)
+
+// Store — очередь доставок вебхуков.
+type Store interface {
+	RecordWebhookEvent(ctx context.Context, event models.OutboxEvent) error
+	GetDueWebhooks(ctx context.Context, limit int) ([]models.OutgoingWebhook, error)
+	RecordWebhookAttempt(ctx context.Context, deliveryID int, attempt models.WebhookAttempt, delivered bool, retryAfter time.Duration) error
+}
+
+// envelope — тело запроса, которое получает подписчик
+type envelope struct {
+	ID         int             `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurredAt"`
+	Data       json.RawMessage `json:"data"`
+}
+
+// Dispatcher отправляет события подписчикам: POST с JSON-телом, подписанным
+// HMAC-SHA256 секретом подписки. Ответ 2xx считается доставкой; при ошибке попытка
+// повторяется с экспоненциальной задержкой. Перенаправления не выполняются (ответ 3xx —
+// неудачная попытка), а соединения разрешены только с публичными адресами.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+}
+
+func NewDispatcher(store Store) *Dispatcher {
+	dialer := &net.Dialer{Timeout: 10 * time.Second, Control: dialControl}
+	return &Dispatcher{
+		store: store,
+		client: &http.Client{
+			Timeout: 10 * time.Second,
+			// Без Proxy: через прокси проверялся бы адрес прокси, а не подписчика
+			Transport: &http.Transport{
+				DialContext:         dialer.DialContext,
+				ForceAttemptHTTP2:   true,
+				TLSHandshakeTimeout: 10 * time.Second,
+				MaxIdleConns:        10,
+				IdleConnTimeout:     90 * time.Second,
+			},
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Sign возвращает значение заголовка подписи для тела запроса.
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue — подписчик outbox: ставит событие в очередь доставки подписанным на него вебхукам.
+func (d *Dispatcher) Enqueue(ctx context.Context, event models.OutboxEvent) error {
+	if !slices.Contains(models.EventTypes, event.Type) {
+		return nil
+	}
+	return d.store.RecordWebhookEvent(ctx, event)
+}
+
+// Deliver отправляет все доставки, время которых наступило. Возвращает число успешных.
+func (d *Dispatcher) Deliver(ctx context.Context) (int, error) {
+	due, err := d.store.GetDueWebhooks(ctx, batchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	delivered := 0
+	for _, w := range due {
+		if ctx.Err() != nil {
+			return delivered, ctx.Err()
+		}
+
+		attempt, ok := d.send(ctx, w)
+		retryAfter := time.Duration(0)
+		if !ok && w.Attempts+1 < maxAttempts {
+			retryAfter = backoff(w.Attempts)
+		}
+		if !ok {
+			logger.FromContext(ctx).Warn("Webhook delivery failed",
+				zap.Int("delivery", w.DeliveryID), zap.String("url", w.URL), zap.Duration("retryAfter", retryAfter))
+		}
+
+		if err := d.store.RecordWebhookAttempt(context.WithoutCancel(ctx), w.DeliveryID, attempt, ok, retryAfter); err != nil {
+			return delivered, err
+		}
+		if ok {
+			delivered++
+		}
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, w models.OutgoingWebhook) (models.WebhookAttempt, bool) {
+	started := time.Now()
+	attempt := models.WebhookAttempt{}
+	fail := func(err error) (models.WebhookAttempt, bool) {
+		text := err.Error()
+		attempt.Error = &text
+		attempt.DurationMs = int(time.Since(started).Milliseconds())
+		return attempt, false
+	}
+
+	body, err := json.Marshal(envelope{ID: w.EventID, Type: w.EventType, OccurredAt: w.OccurredAt, Data: w.Data})
+	if err != nil {
+		return fail(err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, w.URL, bytes.NewReader(body))
+	if err != nil {
+		return fail(err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, w.EventType)
+	req.Header.Set(HeaderDelivery, strconv.Itoa(w.DeliveryID))
+	req.Header.Set(HeaderSignature, Sign(w.Secret, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fail(err)
+	}
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	resp.Body.Close()
+
+	attempt.StatusCode = &resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fail(fmt.Errorf("unexpected status %s", resp.Status))
+	}
+	attempt.DurationMs = int(time.Since(started).Milliseconds())
+	return attempt, true
+}
+
+// backoff возвращает задержку после attempts неудачных попыток
+func backoff(attempts int) time.Duration {
+	delay := baseBackoff << attempts
+	if delay <= 0 || delay > maxBackoff {
+		return maxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"cmd/main.go/models"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSign(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret string
+		body   string
+		want   string
+	}{
+		{"known vector", "It's a Secret to Everybody", "Hello, World!",
+			"sha256=757107ea0eb2509fc211221cce984b8a37570b6d7586c22c46f4379c8b043e17"},
+		{"empty body", "key", "",
+			"sha256=5d5d139563c95b5967b9bd9a8c9b233a9dedb45072794cd232dc1b74832607d0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Sign(tt.secret, []byte(tt.body)); got != tt.want {
+				t.Errorf("Sign = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, time.Minute},
+		{5, 16 * time.Minute},
+		{9, 256 * time.Minute},
+		{10, maxBackoff},
+		{100, maxBackoff},
+	}
+
+	for _, tt := range tests {
+		if got := backoff(tt.attempts); got != tt.want {
+			t.Errorf("backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+type fakeStore struct {
+	due      []models.OutgoingWebhook
+	attempts []models.WebhookAttempt
+	retries  []time.Duration
+}
+
+func (s *fakeStore) RecordWebhookEvent(context.Context, models.OutboxEvent) error { return nil }
+
+func (s *fakeStore) GetDueWebhooks(context.Context, int) ([]models.OutgoingWebhook, error) {
+	return s.due, nil
+}
+
+func (s *fakeStore) RecordWebhookAttempt(_ context.Context, _ int, attempt models.WebhookAttempt, _ bool, retryAfter time.Duration) error {
+	s.attempts = append(s.attempts, attempt)
+	s.retries = append(s.retries, retryAfter)
+	return nil
+}
+
+// Доставка на адрес из локальной сети отклоняется при соединении, даже если
+// подписка его как-то обошла проверку CheckURL
+func TestDeliverRefusesLoopback(t *testing.T) {
+	called := false
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) { called = true }))
+	defer srv.Close()
+
+	tests := []struct {
+		name     string
+		attempts int
+		retry    time.Duration
+	}{
+		{"first attempt", 0, baseBackoff},
+		{"last attempt", maxAttempts - 1, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := &fakeStore{due: []models.OutgoingWebhook{{DeliveryID: 1, Attempts: tt.attempts, URL: srv.URL, EventType: models.EventLoanIssued}}}
+			delivered, err := NewDispatcher(store).Deliver(context.Background())
+			if err != nil || delivered != 0 {
+				t.Fatalf("Deliver = %d, %v; want 0, nil", delivered, err)
+			}
+			if called {
+				t.Fatal("request reached the loopback server")
+			}
+			if len(store.attempts) != 1 || store.attempts[0].Error == nil ||
+				!strings.Contains(*store.attempts[0].Error, ErrForbiddenAddress.Error()) {
+				t.Fatalf("attempts = %+v, want one refused attempt", store.attempts)
+			}
+			if store.retries[0] != tt.retry {
+				t.Errorf("retry after %s, want %s", store.retries[0], tt.retry)
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	tests := []struct {
+		address string
+		wantErr bool
+	}{
+		{"93.184.215.14:443", false},
+		{"[2606:2800:21f:cb07:6820:80da:af6b:8b2c]:443", false},
+		{"127.0.0.1:443", true},
+		{"[::1]:443", true},
+		{"10.1.2.3:443", true},
+		{"169.254.169.254:80", true},
+		{"not-an-address", true},
+	}
+
+	for _, tt := range tests {
+		err := dialControl("tcp", tt.address, nil)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("dialControl(%s) = %v, want error %v", tt.address, err, tt.wantErr)
+		}
+		if err != nil && tt.address != "not-an-address" && !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("dialControl(%s) = %v, want %v", tt.address, err, ErrForbiddenAddress)
+		}
+	}
+}
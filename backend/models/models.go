@@ -1,28 +1,292 @@
 package models
 
+import (
+	"encoding/json"
+	"time"
+)
+
 // Book represents a book in the library system
 type Book struct {
 	ID       int
 	Title    string
 	Author   string
 	Category string
+	ISBN     *string
 }
 
+// Patron categories
+const (
+	CategoryAdult   = "adult"
+	CategoryChild   = "child"
+	CategoryStudent = "student"
+	CategoryStaff   = "staff"
+)
+
+// Patron account statuses
+const (
+	UserActive    = "active"
+	UserBlocked   = "blocked"
+	UserSuspended = "suspended"
+)
+
 // User represents a user in the library system
 type User struct {
 	ID            int
 	Name          string
 	Email         string
+	CardNumber    string
+	Category      string
+	Phone         string
+	Address       string
+	RegisteredAt  string
+	ExpiresAt     string
+	Status        string
+	StatusReason  string
+	GuardianID    *int
 	BorrowedBooks []string
 }
 
+// LoanPolicy holds circulation rules for a patron category
+type LoanPolicy struct {
+	Category         string `json:"category"`
+	MaxLoans         int    `json:"maxLoans"`
+	LoanDays         int    `json:"loanDays"`
+	MembershipMonths int    `json:"membershipMonths"`
+	// DailyFine is charged for each day a loan is overdue
+	DailyFine float64 `json:"dailyFine"`
+	// RestrictedCategories lists book categories patrons of this category may not borrow
+	RestrictedCategories []string `json:"restrictedCategories"`
+}
+
+// Dependent is a child patron as seen by their guardian
+type Dependent struct {
+	User  User   `json:"user"`
+	Loans []Loan `json:"loans"`
+	Holds []Hold `json:"holds"`
+}
+
+// Fine is an entry in the fines ledger; charges are positive, payments and refunds negative
+type Fine struct {
+	ID        int     `json:"id"`
+	UserID    int     `json:"userID"`
+	LoanID    *int    `json:"loanID"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason"`
+	CreatedAt string  `json:"createdAt"`
+}
+
+// Fine reasons used by the lost item workflow
+const (
+	FineReplacement       = "replacement"
+	FineReplacementRefund = "replacement refund"
+)
+
+// ReplacementCost is the default charge for a lost or damaged copy of a book category
+type ReplacementCost struct {
+	Category string  `json:"category"`
+	Amount   float64 `json:"amount"`
+}
+
+// FineBalance is a patron's outstanding balance including dependents' fines
+type FineBalance struct {
+	UserID     int     `json:"userID"`
+	Own        float64 `json:"own"`
+	Dependents float64 `json:"dependents"`
+	Total      float64 `json:"total"`
+}
+
 // Loan represents a book loan
 type Loan struct {
 	ID         int     `json:"id"`
 	UserID     string  `json:"userID"`
 	BookID     string  `json:"bookID"`
+	CopyID     *int    `json:"copyID"`
+	BranchID   *int    `json:"branchID"`
 	BorrowDate string  `json:"borrowDate"`
+	DueDate    *string `json:"dueDate"`
 	ReturnDate *string `json:"returnDate"`
+	Outcome    *string `json:"outcome"`
+	Overdue    bool    `json:"overdue"`
+}
+
+// Loan outcomes for loans closed other than by a normal return
+const (
+	LoanLost    = "lost"
+	LoanDamaged = "damaged"
+	LoanFound   = "found"
+)
+
+// Branch represents a library branch
+type Branch struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Address string `json:"address"`
+}
+
+// Copy statuses
+const (
+	CopyAvailable = "available"
+	CopyOnLoan    = "on_loan"
+	CopyOnHold    = "on_hold"
+	CopyInTransit = "in_transit"
+	CopyLost      = "lost"
+	CopyDamaged   = "damaged"
+	CopyWithdrawn = "withdrawn"
+)
+
+// Copy conditions
+const (
+	ConditionGood = "good"
+	ConditionFair = "fair"
+	ConditionPoor = "poor"
+)
+
+// Copy represents a physical copy of a book
+type Copy struct {
+	ID              int      `json:"id"`
+	BookID          int      `json:"bookID"`
+	Barcode         string   `json:"barcode"`
+	HomeBranchID    int      `json:"homeBranchID"`
+	CurrentBranchID int      `json:"currentBranchID"`
+	Status          string   `json:"status"`
+	Price           *float64 `json:"price"`
+	Shelf           string   `json:"shelf"`
+	Condition       string   `json:"condition"`
+	AcquiredAt      string   `json:"acquiredAt"`
+}
+
+// Stocktake statuses
+const (
+	StocktakeOpen   = "open"
+	StocktakeClosed = "closed"
+)
+
+// Stocktake is an inventory session covering a branch and an optional shelf range
+type Stocktake struct {
+	ID        int     `json:"id"`
+	BranchID  int     `json:"branchID"`
+	ShelfFrom string  `json:"shelfFrom"`
+	ShelfTo   string  `json:"shelfTo"`
+	Status    string  `json:"status"`
+	OpenedAt  string  `json:"openedAt"`
+	ClosedAt  *string `json:"closedAt"`
+}
+
+// StocktakeReport reconciles scanned barcodes against the catalogue
+type StocktakeReport struct {
+	StocktakeID   int    `json:"stocktakeID"`
+	Expected      int    `json:"expected"`
+	Scanned       int    `json:"scanned"`
+	Missing       []Copy `json:"missing"`
+	OnLoan        []Copy `json:"onLoan"`
+	WrongLocation []Copy `json:"wrongLocation"`
+	// Found are scanned copies recorded as lost or withdrawn
+	Found   []Copy   `json:"found"`
+	Unknown []string `json:"unknown"`
+}
+
+// Hold statuses
+const (
+	HoldWaiting   = "waiting"
+	HoldInTransit = "in_transit"
+	HoldReady     = "ready"
+	HoldFulfilled = "fulfilled"
+	HoldCancelled = "cancelled"
+	HoldExpired   = "expired"
+)
+
+// Hold represents a patron's request for a book to be picked up at a branch
+type Hold struct {
+	ID             int    `json:"id"`
+	UserID         int    `json:"userID"`
+	BookID         int    `json:"bookID"`
+	PickupBranchID int    `json:"pickupBranchID"`
+	CopyID         *int   `json:"copyID"`
+	Status         string `json:"status"`
+	CreatedAt      string `json:"createdAt"`
+}
+
+// Transfer statuses
+const (
+	TransferRequested = "requested"
+	TransferInTransit = "in_transit"
+	TransferReceived  = "received"
+)
+
+// Transfer represents moving a copy from one branch to another
+type Transfer struct {
+	ID           int     `json:"id"`
+	CopyID       int     `json:"copyID"`
+	FromBranchID int     `json:"fromBranchID"`
+	ToBranchID   int     `json:"toBranchID"`
+	HoldID       *int    `json:"holdID"`
+	Status       string  `json:"status"`
+	RequestedAt  string  `json:"requestedAt"`
+	ShippedAt    *string `json:"shippedAt"`
+	ReceivedAt   *string `json:"receivedAt"`
+}
+
+// Contact methods for overdue loans
+const (
+	ContactCalled  = "called"
+	ContactEmailed = "emailed"
+	ContactLetter  = "letter"
+)
+
+// ContactAttempt is a recorded attempt to reach a patron about an overdue loan
+type ContactAttempt struct {
+	ID        int    `json:"id"`
+	LoanID    int    `json:"loanID"`
+	Method    string `json:"method"`
+	Note      string `json:"note"`
+	Staff     string `json:"staff"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// OverdueFilter selects loans for the overdue report
+type OverdueFilter struct {
+	BranchID int    // branch the loan was issued at, 0 for all
+	MinDays  int    // at least MinDays days past due
+	Category string // patron category, empty for all
+}
+
+// OverdueReport is the collections worklist: patrons with overdue loans
+type OverdueReport struct {
+	Date    string          `json:"date"`
+	Patrons []OverduePatron `json:"patrons"`
+}
+
+// OverduePatron is a patron with contact details, fines and overdue loans. Balance is the
+// patron's fines ledger; AccruedFines is what the listed loans have accrued so far.
+type OverduePatron struct {
+	UserID       int           `json:"userID"`
+	Name         string        `json:"name"`
+	CardNumber   string        `json:"cardNumber"`
+	Category     string        `json:"category"`
+	Email        string        `json:"email"`
+	Phone        string        `json:"phone"`
+	Address      string        `json:"address"`
+	Balance      float64       `json:"balance"`
+	AccruedFines float64       `json:"accruedFines"`
+	Loans        []OverdueLoan `json:"loans"`
+}
+
+// OverdueLoan is an overdue loan with the contact attempts made about it. DueEstimated
+// is set for loans issued without a due date; DueDate is then the borrow date plus the
+// loan period of the patron's category.
+type OverdueLoan struct {
+	LoanID       int              `json:"loanID"`
+	BookID       int              `json:"bookID"`
+	Title        string           `json:"title"`
+	Author       string           `json:"author"`
+	Barcode      string           `json:"barcode"`
+	BranchID     *int             `json:"branchID"`
+	BorrowDate   string           `json:"borrowDate"`
+	DueDate      string           `json:"dueDate"`
+	DueEstimated bool             `json:"dueEstimated"`
+	DaysOverdue  int              `json:"daysOverdue"`
+	AccruedFine  float64          `json:"accruedFine"`
+	Contacts     []ContactAttempt `json:"contacts"`
 }
 
 // Statistics represents library statistics
@@ -30,8 +294,10 @@ type Statistics struct {
 	TotalBooks        int
 	TotalUsers        int
 	TotalLoans        int
+	TotalWithdrawn    int
 	PopularCategories []CategoryStats
 	ActiveUsers       []UserStats
+	Branches          []BranchStats
 }
 
 // CategoryStats represents statistics for a category
@@ -45,3 +311,389 @@ type UserStats struct {
 	Name       string
 	LoansCount int //количество loans пользователя
 }
+
+// BranchStats represents statistics for a branch
+type BranchStats struct {
+	Name        string
+	TotalCopies int
+	ActiveLoans int
+	InTransit   int //количество экземпляров в пути в филиал
+}
+
+// CirculationCounts is the current circulation state exported as metrics
+type CirculationCounts struct {
+	OverdueLoans int `json:"overdueLoans"`
+	HoldsWaiting int `json:"holdsWaiting"`
+}
+
+// Statistics granularities
+const (
+	GranularityDay   = "day"
+	GranularityWeek  = "week"
+	GranularityMonth = "month"
+)
+
+// StatisticsPeriod selects the range for trend statistics; both dates are inclusive
+type StatisticsPeriod struct {
+	From        time.Time
+	To          time.Time
+	Granularity string // day, week or month
+}
+
+// TrendStatistics is circulation over a period, counted over full loan history
+type TrendStatistics struct {
+	From          string          `json:"from"`
+	To            string          `json:"to"`
+	Granularity   string          `json:"granularity"`
+	Series        []TrendPoint    `json:"series"`
+	Totals        TrendTotals     `json:"totals"`
+	TopTitles     []TitleTrend    `json:"topTitles"`
+	TopCategories []CategoryTrend `json:"topCategories"`
+	TopPatrons    []PatronTrend   `json:"topPatrons"`
+}
+
+// TrendPoint is one bucket of the series; Period is the bucket start date
+type TrendPoint struct {
+	Period     string `json:"period"`
+	Issued     int    `json:"issued"`
+	Returned   int    `json:"returned"`
+	NewPatrons int    `json:"newPatrons"`
+}
+
+// TrendTotals sums the series over the whole period
+type TrendTotals struct {
+	Issued     int `json:"issued"`
+	Returned   int `json:"returned"`
+	NewPatrons int `json:"newPatrons"`
+}
+
+// TitleTrend is a title ranked by loans issued during the period
+type TitleTrend struct {
+	BookID int    `json:"bookID"`
+	Title  string `json:"title"`
+	Author string `json:"author"`
+	Loans  int    `json:"loans"`
+}
+
+// CategoryTrend is a category ranked by loans issued during the period
+type CategoryTrend struct {
+	Name  string `json:"name"`
+	Loans int    `json:"loans"`
+}
+
+// RollupStatistics is circulation over a period served from the daily loan rollups
+type RollupStatistics struct {
+	From             string        `json:"from"`
+	To               string        `json:"to"`
+	Granularity      string        `json:"granularity"`
+	Series           []RollupPoint `json:"series"`
+	Categories       []RollupGroup `json:"categories"`
+	Branches         []RollupGroup `json:"branches"`
+	PatronCategories []RollupGroup `json:"patronCategories"`
+}
+
+// RollupPoint is one bucket of the rollup series; Period is the bucket start date
+type RollupPoint struct {
+	Period   string `json:"period"`
+	Issued   int    `json:"issued"`
+	Returned int    `json:"returned"`
+}
+
+// RollupGroup is circulation of a book category, branch or patron category over the period
+type RollupGroup struct {
+	Name     string `json:"name"`
+	Issued   int    `json:"issued"`
+	Returned int    `json:"returned"`
+	Open     int    `json:"open"` // loans still out at the end of the period
+}
+
+// PatronTrend is a patron ranked by loans taken during the period
+type PatronTrend struct {
+	UserID int    `json:"userID"`
+	Name   string `json:"name"`
+	Loans  int    `json:"loans"`
+}
+
+// Vendor is a supplier books are purchased from
+type Vendor struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+	Phone string `json:"phone"`
+}
+
+// Fund is a budget line with a yearly allocation
+type Fund struct {
+	ID     int     `json:"id"`
+	Name   string  `json:"name"`
+	Year   int     `json:"year"`
+	Budget float64 `json:"budget"`
+}
+
+// Purchase order statuses
+const (
+	OrderDraft             = "draft"
+	OrderPlaced            = "ordered"
+	OrderPartiallyReceived = "partially_received"
+	OrderReceived          = "received"
+	OrderCancelled         = "cancelled"
+)
+
+// PurchaseOrder is an order placed with a vendor and charged to a fund
+type PurchaseOrder struct {
+	ID        int         `json:"id"`
+	VendorID  int         `json:"vendorID"`
+	FundID    int         `json:"fundID"`
+	Status    string      `json:"status"`
+	CreatedAt string      `json:"createdAt"`
+	OrderedAt *string     `json:"orderedAt"`
+	Lines     []OrderLine `json:"lines"`
+}
+
+// OrderLine is a title ordered in a purchase order
+type OrderLine struct {
+	ID        int     `json:"id"`
+	OrderID   int     `json:"orderID"`
+	Title     string  `json:"title"`
+	Author    string  `json:"author"`
+	Category  string  `json:"category"`
+	ISBN      string  `json:"isbn"`
+	Quantity  int     `json:"quantity"`
+	UnitPrice float64 `json:"unitPrice"`
+	Received  int     `json:"received"`
+	BookID    *int    `json:"bookID"`
+}
+
+// ReceiveLine is the number of items of an order line that arrived
+type ReceiveLine struct {
+	LineID   int `json:"lineID"`
+	Quantity int `json:"quantity"`
+}
+
+// FundReport compares spending against a fund's allocation
+type FundReport struct {
+	FundID    int     `json:"fundID"`
+	Name      string  `json:"name"`
+	Year      int     `json:"year"`
+	Allocated float64 `json:"allocated"`
+	Committed float64 `json:"committed"` //заказано, но еще не получено
+	Spent     float64 `json:"spent"`
+	Remaining float64 `json:"remaining"`
+}
+
+// WeedingFilter selects withdrawal candidates
+type WeedingFilter struct {
+	Months      int    // no loans during the last Months months
+	Category    string // book category, empty for all
+	MinAgeYears int    // copies acquired at least MinAgeYears ago
+	Condition   string // copy condition, empty for all
+	BranchID    int    // current branch, 0 for all
+}
+
+// WeedingCandidate is a copy that rarely circulates
+type WeedingCandidate struct {
+	Copy       Copy    `json:"copy"`
+	Title      string  `json:"title"`
+	Author     string  `json:"author"`
+	Category   string  `json:"category"`
+	TotalLoans int     `json:"totalLoans"`
+	LastLoan   *string `json:"lastLoan"`
+}
+
+// Withdrawal records a copy removed from the collection
+type Withdrawal struct {
+	ID          int    `json:"id"`
+	CopyID      int    `json:"copyID"`
+	BookID      int    `json:"bookID"`
+	BranchID    int    `json:"branchID"`
+	Barcode     string `json:"barcode"`
+	Title       string `json:"title"`
+	Category    string `json:"category"`
+	Reason      string `json:"reason"`
+	TotalLoans  int    `json:"totalLoans"`
+	WithdrawnAt string `json:"withdrawnAt"`
+}
+
+// Job run statuses
+const (
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed"
+)
+
+// JobRun is one attempt of a background job
+type JobRun struct {
+	ID         int     `json:"id"`
+	Job        string  `json:"job"`
+	Attempt    int     `json:"attempt"`
+	Status     string  `json:"status"`
+	StartedAt  string  `json:"startedAt"`
+	FinishedAt *string `json:"finishedAt"`
+	Error      *string `json:"error"`
+}
+
+// Notification kinds
+const (
+	NotifyOverdue   = "overdue"
+	NotifyHoldReady = "hold_ready"
+)
+
+// Notification statuses
+const (
+	NotificationPending = "pending"
+	NotificationSent    = "sent"
+	NotificationFailed  = "failed"
+)
+
+// Notification is a rendered message in the outbox. DedupKey identifies the event,
+// so the same event is never queued twice.
+type Notification struct {
+	ID        int     `json:"id"`
+	UserID    int     `json:"userID"`
+	Channel   string  `json:"channel"`
+	Kind      string  `json:"kind"`
+	DedupKey  string  `json:"dedupKey"`
+	Recipient string  `json:"recipient"`
+	Subject   string  `json:"subject"`
+	Text      string  `json:"text"`
+	HTML      string  `json:"html"`
+	Status    string  `json:"status"`
+	Attempts  int     `json:"attempts"`
+	LastError *string `json:"lastError"`
+	CreatedAt string  `json:"createdAt"`
+	SentAt    *string `json:"sentAt"`
+}
+
+// Notice is an event a patron should be told about, before rendering
+type Notice struct {
+	Kind     string
+	DedupKey string
+	UserID   int
+	Name     string
+	Email    string
+	Language string
+	Title    string
+	Author   string
+	DueDate  time.Time // overdue loans
+	Branch   string    // ready holds: pickup branch
+}
+
+// Notification languages
+const (
+	LanguageRussian = "ru"
+	LanguageEnglish = "en"
+)
+
+// NotificationPreferences are a patron's opt-outs and message language
+type NotificationPreferences struct {
+	UserID    int    `json:"userID"`
+	Language  string `json:"language"`
+	Overdue   bool   `json:"overdue"`
+	HoldReady bool   `json:"holdReady"`
+}
+
+// Domain event types written to the outbox
+const (
+	EventBookAdded     = "book.added"
+	EventBookDeleted   = "book.deleted"
+	EventUserAdded     = "user.added"
+	EventUserDeleted   = "user.deleted"
+	EventLoanIssued    = "loan.issued"
+	EventLoanReturned  = "loan.returned"
+	EventHoldPlaced    = "hold.placed"
+	EventHoldCancelled = "hold.cancelled"
+	EventCopyLost      = "copy.lost"
+	EventCopyFound     = "copy.found"
+	EventCopyWithdrawn = "copy.withdrawn"
+)
+
+// EventTypes lists all event types a webhook can subscribe to
+var EventTypes = []string{EventLoanIssued, EventLoanReturned, EventHoldPlaced, EventCopyLost, EventCopyFound, EventCopyWithdrawn}
+
+// OutboxEvent is a domain event committed together with the change that caused it.
+// Data holds the event payload: the added or changed entity, or EntityDeleted.
+type OutboxEvent struct {
+	ID        int64           `json:"id"`
+	TxID      int64           `json:"-"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"createdAt"`
+}
+
+// Position returns the place of the event in the outbox stream.
+func (e OutboxEvent) Position() OutboxPosition {
+	return OutboxPosition{TxID: e.TxID, EventID: e.ID}
+}
+
+// OutboxPosition is a place in the outbox stream. Events are ordered by the transaction
+// that wrote them (TxID) and then by ID, which, unlike ID alone, never lets an event
+// appear behind a position that has already been passed.
+type OutboxPosition struct {
+	TxID    int64
+	EventID int64
+}
+
+// EntityDeleted is the payload of *.deleted and hold.cancelled events
+type EntityDeleted struct {
+	ID int `json:"id"`
+}
+
+// ConsumerOffset is how far an outbox subscriber has processed the event stream
+type ConsumerOffset struct {
+	Consumer    string `json:"consumer"`
+	LastEventID int64  `json:"lastEventID"`
+	Lag         int    `json:"lag"`
+	UpdatedAt   string `json:"updatedAt"`
+}
+
+// WebhookSubscription is an external endpoint that receives events. Secret is only
+// returned when the subscription is created.
+type WebhookSubscription struct {
+	ID        int      `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret,omitempty"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"createdAt"`
+}
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// WebhookDelivery is one event queued for one subscription
+type WebhookDelivery struct {
+	ID             int              `json:"id"`
+	SubscriptionID int              `json:"subscriptionID"`
+	EventID        int              `json:"eventID"`
+	EventType      string           `json:"eventType"`
+	Status         string           `json:"status"`
+	Attempts       int              `json:"attempts"`
+	NextAttemptAt  string           `json:"nextAttemptAt"`
+	CreatedAt      string           `json:"createdAt"`
+	DeliveredAt    *string          `json:"deliveredAt"`
+	Log            []WebhookAttempt `json:"log,omitempty"`
+}
+
+// WebhookAttempt is a logged HTTP call for a delivery
+type WebhookAttempt struct {
+	AttemptedAt string  `json:"attemptedAt"`
+	StatusCode  *int    `json:"statusCode"`
+	Error       *string `json:"error"`
+	DurationMs  int     `json:"durationMs"`
+}
+
+// OutgoingWebhook is a due delivery together with everything needed to send it
+type OutgoingWebhook struct {
+	DeliveryID int
+	Attempts   int
+	URL        string
+	Secret     string
+	EventID    int
+	EventType  string
+	Data       json.RawMessage
+	OccurredAt time.Time
+}
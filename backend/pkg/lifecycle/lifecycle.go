@@ -0,0 +1,126 @@
+// Package lifecycle runs the ordered shutdown of the application after SIGINT or SIGTERM.
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// ErrTimeout is reported for a shutdown step that did not finish within its timeout.
+var ErrTimeout = errors.New("timed out")
+
+type step struct {
+	name    string
+	timeout time.Duration
+	fn      func(ctx context.Context) error
+}
+
+// Manager waits for a termination signal and then runs the shutdown steps one by one,
+// in the order they were added, each under its own timeout.
+type Manager struct {
+	log     *zap.Logger
+	steps   []step
+	signals chan os.Signal
+
+	// ctx is cancelled by the first signal, which is stored in received before that
+	ctx      context.Context
+	cancel   context.CancelFunc
+	received os.Signal
+}
+
+// New creates a Manager and starts listening for SIGINT and SIGTERM. Call it early, so a
+// signal that arrives during startup is not lost.
+func New(log *zap.Logger) *Manager {
+	m := &Manager{log: log, signals: make(chan os.Signal, 1)}
+	m.ctx, m.cancel = context.WithCancel(context.Background())
+	signal.Notify(m.signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		m.received = <-m.signals
+		m.cancel()
+	}()
+	return m
+}
+
+// Context returns a context that is cancelled when the first termination signal arrives.
+// Use it for startup work such as connecting to the database, so a signal interrupts it
+// instead of waiting for its timeout.
+func (m *Manager) Context() context.Context {
+	return m.ctx
+}
+
+// OnShutdown adds a shutdown step. fn should return once ctx is done; if it does not, the
+// step is reported as timed out and shutdown moves on to the next one. A zero timeout
+// means no limit.
+func (m *Manager) OnShutdown(name string, timeout time.Duration, fn func(ctx context.Context) error) {
+	m.steps = append(m.steps, step{name: name, timeout: timeout, fn: fn})
+}
+
+// Wait blocks until a termination signal arrives or ctx is done.
+func (m *Manager) Wait(ctx context.Context) {
+	select {
+	case <-m.ctx.Done():
+		m.log.Info("Received signal, shutting down", zap.Stringer("signal", m.received))
+	case <-ctx.Done():
+		m.log.Info("Shutting down", zap.Error(context.Cause(ctx)))
+	}
+}
+
+// Shutdown runs every step, even after one fails, and returns the failures joined. A
+// second signal during shutdown exits the process immediately with status 1.
+func (m *Manager) Shutdown() error {
+	// If shutdown did not start with a signal, the first one already counts as the second
+	signalled := m.ctx.Err() != nil
+	go func() {
+		var sig os.Signal
+		if signalled {
+			sig = <-m.signals
+		} else {
+			<-m.ctx.Done()
+			sig = m.received
+		}
+		m.log.Error("Received second signal, exiting without finishing shutdown", zap.Stringer("signal", sig))
+		m.log.Sync()
+		os.Exit(1)
+	}()
+
+	var errs []error
+	for _, st := range m.steps {
+		started := time.Now()
+		if err := m.run(st); err != nil {
+			m.log.Error("Shutdown step failed", zap.String("step", st.name), zap.Duration("duration", time.Since(started)), zap.Error(err))
+			errs = append(errs, fmt.Errorf("%s: %w", st.name, err))
+			continue
+		}
+		m.log.Info("Shutdown step finished", zap.String("step", st.name), zap.Duration("duration", time.Since(started)))
+	}
+	return errors.Join(errs...)
+}
+
+func (m *Manager) run(st step) error {
+	ctx, cancel := context.Background(), context.CancelFunc(func() {})
+	if st.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, st.timeout)
+	}
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() { done <- st.fn(ctx) }()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return fmt.Errorf("%w after %s", ErrTimeout, st.timeout)
+	}
+	return err
+}
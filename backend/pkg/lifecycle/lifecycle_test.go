@@ -0,0 +1,78 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestShutdown(t *testing.T) {
+	failed := errors.New("failed")
+	tests := []struct {
+		name    string
+		steps   map[string]func(ctx context.Context) error
+		order   []string
+		timeout time.Duration
+		wantErr error
+	}{
+		{"all succeed", map[string]func(context.Context) error{
+			"http": func(context.Context) error { return nil },
+			"jobs": func(context.Context) error { return nil },
+		}, []string{"http", "jobs"}, 0, nil},
+		{"failure does not stop later steps", map[string]func(context.Context) error{
+			"http": func(context.Context) error { return failed },
+			"jobs": func(context.Context) error { return nil },
+		}, []string{"http", "jobs"}, 0, failed},
+		{"overrun step times out", map[string]func(context.Context) error{
+			"http": func(context.Context) error { time.Sleep(time.Second); return nil },
+			"jobs": func(context.Context) error { return nil },
+		}, []string{"http", "jobs"}, 10 * time.Millisecond, ErrTimeout},
+		{"step honouring its context times out", map[string]func(context.Context) error{
+			"http": func(ctx context.Context) error { <-ctx.Done(); return ctx.Err() },
+		}, []string{"http"}, 10 * time.Millisecond, ErrTimeout},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m := New(zap.NewNop())
+			// A step that times out keeps running next to the following ones
+			var mu sync.Mutex
+			var ran []string
+			for _, name := range tt.order {
+				fn := tt.steps[name]
+				m.OnShutdown(name, tt.timeout, func(ctx context.Context) error {
+					mu.Lock()
+					ran = append(ran, name)
+					mu.Unlock()
+					return fn(ctx)
+				})
+			}
+
+			err := m.Shutdown()
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			if !slices.Equal(ran, tt.order) {
+				t.Errorf("ran %v, want %v", ran, tt.order)
+			}
+		})
+	}
+}
+
+func TestWaitForContext(t *testing.T) {
+	m := New(zap.NewNop())
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	m.Wait(ctx)
+
+	if m.Context().Err() != nil {
+		t.Error("startup context cancelled without a signal")
+	}
+}
@@ -0,0 +1,173 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redacted replaces the value of fields that must never be logged.
+const redacted = "[REDACTED]"
+
+// secretKeys are field name fragments whose values are dropped entirely.
+var secretKeys = []string{"password", "secret", "token", "authorization", "cookie"}
+
+var (
+	emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*@([A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+	// dsnPasswordPattern and urlPasswordPattern catch credentials embedded in
+	// connection strings, e.g. "password=x" and "postgres://user:x@host"
+	dsnPasswordPattern = regexp.MustCompile(`(?i)(password=)\S+`)
+	urlPasswordPattern = regexp.MustCompile(`(://[^:/@\s]+:)[^@\s]+(@)`)
+)
+
+// redactCore masks sensitive data before entries are encoded: fields named like
+// secrets are replaced, and email addresses and embedded passwords are masked in
+// messages and field values. Stringers and byte strings are redacted as strings;
+// objects, arrays and reflected values (zap.Any on structs, maps and slices) are
+// encoded first and redacted value by value, including nested keys named like secrets.
+// Other field types (numbers, times, durations) carry nothing to mask.
+type redactCore struct {
+	zapcore.Core
+}
+
+func (c redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return redactCore{c.Core.With(redactFields(fields))}
+}
+
+func (c redactCore) Check(entry zapcore.Entry, checked *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(entry.Level) {
+		return checked.AddCore(entry, c)
+	}
+	return checked
+}
+
+func (c redactCore) Write(entry zapcore.Entry, fields []zapcore.Field) error {
+	entry.Message = redactString(entry.Message)
+	return c.Core.Write(entry, redactFields(fields))
+}
+
+func redactFields(fields []zapcore.Field) []zapcore.Field {
+	out := make([]zapcore.Field, len(fields))
+	for i, f := range fields {
+		out[i] = redactField(f)
+	}
+	return out
+}
+
+func redactField(f zapcore.Field) zapcore.Field {
+	if secretKey(f.Key) {
+		return zap.String(f.Key, redacted)
+	}
+
+	switch f.Type {
+	case zapcore.StringType:
+		f.String = redactString(f.String)
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok {
+			return zap.String(f.Key, redactString(err.Error()))
+		}
+	case zapcore.StringerType:
+		if s, ok := stringerValue(f.Interface); ok {
+			return zap.String(f.Key, redactString(s))
+		}
+	case zapcore.ByteStringType:
+		if b, ok := f.Interface.([]byte); ok {
+			return zap.String(f.Key, redactString(string(b)))
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.InlineMarshalerType, zapcore.ReflectType:
+		if v, ok := encodedValue(f); ok {
+			return zap.Any(f.Key, redactValue(v))
+		}
+	}
+	return f
+}
+
+// stringerValue calls String, which may panic on a nil receiver; zap reports such
+// values itself, so the field is then left unchanged
+func stringerValue(v any) (s string, ok bool) {
+	defer func() {
+		if recover() != nil {
+			ok = false
+		}
+	}()
+	stringer, ok := v.(fmt.Stringer)
+	if !ok {
+		return "", false
+	}
+	return stringer.String(), true
+}
+
+// encodedValue turns a structured field into plain maps, slices and scalars: marshalers
+// through zap's map encoder, reflected values through their JSON form
+func encodedValue(f zapcore.Field) (any, bool) {
+	if f.Type == zapcore.ReflectType {
+		data, err := json.Marshal(f.Interface)
+		if err != nil {
+			return nil, false
+		}
+		var v any
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, false
+		}
+		return v, true
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	if f.Type == zapcore.InlineMarshalerType {
+		// Inline objects add their fields next to the others; keep them under the key
+		f.Type = zapcore.ObjectMarshalerType
+	}
+	f.Key = "value"
+	f.AddTo(enc)
+	v, ok := enc.Fields["value"]
+	return v, ok
+}
+
+// redactValue redacts strings in v and drops values under keys named like secrets
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case string:
+		return redactString(v)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, item := range v {
+			if secretKey(k) {
+				out[k] = redacted
+			} else {
+				out[k] = redactValue(item)
+			}
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, item := range v {
+			out[i] = redactValue(item)
+		}
+		return out
+	default:
+		return v
+	}
+}
+
+func secretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secret := range secretKeys {
+		if strings.Contains(key, secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func redactString(s string) string {
+	if strings.ContainsAny(s, "@=") {
+		s = dsnPasswordPattern.ReplaceAllString(s, "${1}"+redacted)
+		s = urlPasswordPattern.ReplaceAllString(s, "${1}"+redacted+"${2}")
+		s = emailPattern.ReplaceAllString(s, "${1}***@${2}")
+	}
+	return s
+}
@@ -0,0 +1,120 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type user struct {
+	Email    string
+	Password string
+}
+
+func (u user) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("email", u.Email)
+	enc.AddString("apiToken", u.Password)
+	return nil
+}
+
+// account has no marshaler, so zap.Any logs it by reflection
+type account struct {
+	Name     string `json:"name"`
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+type address string
+
+func (a address) String() string { return string(a) }
+
+type emails []string
+
+func (e emails) MarshalLogArray(enc zapcore.ArrayEncoder) error {
+	for _, s := range e {
+		enc.AppendString(s)
+	}
+	return nil
+}
+
+// logged writes one entry with field through redactCore and returns the decoded JSON
+func logged(t *testing.T, msg string, field zap.Field) map[string]any {
+	t.Helper()
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	core := redactCore{zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel)}
+	zap.New(core).Info(msg, field)
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("decode %q: %v", buf.String(), err)
+	}
+	return entry
+}
+
+func TestRedactFields(t *testing.T) {
+	tests := []struct {
+		name  string
+		field zap.Field
+		want  string // JSON of the logged value
+	}{
+		{"secret key", zap.String("db_password", "hunter2"), `"[REDACTED]"`},
+		{"secret key of any type", zap.Int("tokenCount", 3), `"[REDACTED]"`},
+		{"email in string", zap.String("to", "mail alice@example.com"), `"mail a***@example.com"`},
+		{"dsn password", zap.String("dsn", "host=db password=hunter2 user=app"), `"host=db password=[REDACTED] user=app"`},
+		{"url password", zap.String("url", "postgres://app:hunter2@db/library"), `"postgres://app:[REDACTED]@db/library"`},
+		{"error", zap.Error(errors.New("send to bob@example.org failed")), `"send to b***@example.org failed"`},
+		{"stringer", zap.Stringer("addr", address("carol@example.com")), `"c***@example.com"`},
+		{"byte string", zap.ByteString("raw", []byte("dave@example.com")), `"d***@example.com"`},
+		{"object marshaler", zap.Object("user", user{Email: "erin@example.com", Password: "x"}),
+			`{"apiToken":"[REDACTED]","email":"e***@example.com"}`},
+		{"array marshaler", zap.Array("to", emails{"frank@example.com"}), `["f***@example.com"]`},
+		{"reflected struct", zap.Any("account", account{Name: "Grace", Email: "grace@example.com", Password: "x"}),
+			`{"email":"g***@example.com","name":"Grace","password":"[REDACTED]"}`},
+		{"reflected map", zap.Any("headers", map[string]any{"Authorization": "Bearer x", "From": "heidi@example.com"}),
+			`{"Authorization":"[REDACTED]","From":"h***@example.com"}`},
+		{"number", zap.Int("count", 3), `3`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := logged(t, "test", tt.field)
+			got, err := json.Marshal(entry[tt.field.Key])
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("got %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactMessage(t *testing.T) {
+	entry := logged(t, "Connecting to postgres://app:hunter2@db/library", zap.Skip())
+	if msg := entry["msg"].(string); strings.Contains(msg, "hunter2") {
+		t.Errorf("password not redacted from message: %q", msg)
+	}
+}
+
+func TestRedactWith(t *testing.T) {
+	var buf bytes.Buffer
+	encoder := zapcore.NewJSONEncoder(zapcore.EncoderConfig{MessageKey: "msg"})
+	core := redactCore{zapcore.NewCore(encoder, zapcore.AddSync(&buf), zapcore.DebugLevel)}
+	zap.New(core).With(zap.String("email", "ivan@example.com")).Info("test")
+
+	if strings.Contains(buf.String(), "ivan@") {
+		t.Errorf("context field not redacted: %s", buf.String())
+	}
+}
+
+func TestRedactNilStringer(t *testing.T) {
+	var addr *strings.Builder
+	// Must not panic; zap reports the failing String call itself
+	logged(t, "test", zap.Stringer("addr", addr))
+}
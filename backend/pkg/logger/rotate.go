@@ -0,0 +1,92 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// rotatingFile is a log file that is rotated by size: once a write would take it past
+// maxSize, it is renamed to path.1 (older backups shift to path.2 and so on, the oldest
+// beyond maxBackups is removed) and a new file is started.
+type rotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func openRotatingFile(path string, maxSizeMB, maxBackups int) (*rotatingFile, error) {
+	if maxSizeMB <= 0 {
+		return nil, fmt.Errorf("invalid log file size %d MB", maxSizeMB)
+	}
+	f := &rotatingFile{path: path, maxSize: int64(maxSizeMB) << 20, maxBackups: maxBackups}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Sync() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.file.Sync()
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("open log file: %w", err)
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	var err error
+	if f.maxBackups > 0 {
+		os.Remove(f.backup(f.maxBackups))
+		for i := f.maxBackups - 1; i >= 1; i-- {
+			os.Rename(f.backup(i), f.backup(i+1))
+		}
+		err = os.Rename(f.path, f.backup(1))
+	} else {
+		err = os.Remove(f.path)
+	}
+	// The file is reopened even if it could not be moved, so logging carries on
+	if openErr := f.open(); openErr != nil {
+		return openErr
+	}
+	if err != nil {
+		return fmt.Errorf("rotate log file: %w", err)
+	}
+	return nil
+}
+
+func (f *rotatingFile) backup(n int) string {
+	return fmt.Sprintf("%s.%d", f.path, n)
+}
@@ -2,42 +2,90 @@ package logger
 
 import (
 	"context"
+	"fmt"
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
+	"os"
+	"time"
 )
 
 // LoggerKey is the key for storing and retrieving the logger from context.
 const LoggerKey = "logger"
 
-// NewLogger creates a new zap.Logger with custom configuration.
-func NewLogger() *zap.Logger {
-	config := zap.Config{
-		Level:       zap.NewAtomicLevelAt(zap.DebugLevel),
-		Development: true,
-		Encoding:    "console",
-		EncoderConfig: zapcore.EncoderConfig{
-			TimeKey:        "time",
-			LevelKey:       "level",
-			NameKey:        "logger",
-			CallerKey:      "caller",
-			MessageKey:     "msg",
-			StacktraceKey:  "stacktrace",
-			LineEnding:     zapcore.DefaultLineEnding,
-			EncodeLevel:    zapcore.CapitalColorLevelEncoder,
-			EncodeTime:     zapcore.ISO8601TimeEncoder,
-			EncodeDuration: zapcore.StringDurationEncoder,
-			EncodeCaller:   zapcore.ShortCallerEncoder,
-		},
-		OutputPaths:      []string{"stdout"},
-		ErrorOutputPaths: []string{"stderr"},
-	}
+// Options configures the logger built by NewLogger.
+type Options struct {
+	// Format is "json" for log aggregators or "console" for human-readable colored output
+	Format string
+	// Level is the initial minimum level: debug, info, warn or error
+	Level string
+	// Sampling caps repeated entries with the same level and message to the first 100
+	// per second and every 100th after that, so hot paths cannot flood the output
+	Sampling bool
+	// File additionally writes logs to this path, rotating it once it reaches MaxSizeMB
+	// and keeping MaxBackups old files; empty logs to stdout only
+	File       string
+	MaxSizeMB  int
+	MaxBackups int
+}
+
+const (
+	sampleInitial    = 100
+	sampleThereafter = 100
+)
 
-	logger, err := config.Build()
+// NewLogger creates a zap.Logger from opts. The returned level can be changed at runtime
+// and affects the logger and every logger derived from it.
+// Sensitive fields are redacted before they reach any output (see redactCore).
+func NewLogger(opts Options) (*zap.Logger, zap.AtomicLevel, error) {
+	level, err := zap.ParseAtomicLevel(opts.Level)
 	if err != nil {
-		panic(err)
+		return nil, level, fmt.Errorf("invalid log level %q", opts.Level)
 	}
 
-	return logger
+	encoderConfig := zapcore.EncoderConfig{
+		TimeKey:        "time",
+		LevelKey:       "level",
+		NameKey:        "logger",
+		CallerKey:      "caller",
+		MessageKey:     "msg",
+		StacktraceKey:  "stacktrace",
+		LineEnding:     zapcore.DefaultLineEnding,
+		EncodeLevel:    zapcore.LowercaseLevelEncoder,
+		EncodeTime:     zapcore.ISO8601TimeEncoder,
+		EncodeDuration: zapcore.StringDurationEncoder,
+		EncodeCaller:   zapcore.ShortCallerEncoder,
+	}
+	var encoder zapcore.Encoder
+	switch opts.Format {
+	case "json":
+		encoder = zapcore.NewJSONEncoder(encoderConfig)
+	case "console":
+		encoderConfig.EncodeLevel = zapcore.CapitalColorLevelEncoder
+		encoder = zapcore.NewConsoleEncoder(encoderConfig)
+	default:
+		return nil, level, fmt.Errorf("invalid log format %q, expected json or console", opts.Format)
+	}
+
+	output := zapcore.Lock(os.Stdout)
+	if opts.File != "" {
+		file, err := openRotatingFile(opts.File, opts.MaxSizeMB, opts.MaxBackups)
+		if err != nil {
+			return nil, level, err
+		}
+		output = zapcore.NewMultiWriteSyncer(output, file)
+	}
+
+	var core zapcore.Core = redactCore{zapcore.NewCore(encoder, output, level)}
+	if opts.Sampling {
+		core = zapcore.NewSamplerWithOptions(core, time.Second, sampleInitial, sampleThereafter)
+	}
+
+	logger := zap.New(core,
+		zap.AddCaller(),
+		zap.AddStacktrace(zap.ErrorLevel),
+		zap.ErrorOutput(zapcore.Lock(os.Stderr)),
+	)
+	return logger, level, nil
 }
 
 // WithLoggerContext adds a zap.Logger to the provided context.
@@ -57,7 +105,7 @@ func FromContext(ctx context.Context) *zap.Logger {
 
 // Usage Guide:
 // 1. Create a logger instance:
-//    logger := logger.NewLogger()
+//    logger, level, err := logger.NewLogger(logger.Options{Format: "json", Level: "info"})
 //
 // 2. Add the logger to the context:
 //    ctx := logger.WithLoggerContext(context.Background(), logger)
@@ -70,4 +118,7 @@ func FromContext(ctx context.Context) *zap.Logger {
 //    log.Debug("This is a debug message")
 //    log.Error("This is an error message")
 //
-// 5. The logger includes caller information to trace where the logging method was invoked.
+// 5. Change the level at runtime:
+//    level.SetLevel(zap.DebugLevel)
+//
+// 6. The logger includes caller information to trace where the logging method was invoked.
@@ -2,24 +2,132 @@ package server
 
 import (
 	"context"
+	"crypto/tls"
+	"errors"
+	"net"
 	"net/http"
+	"sync"
 	"time"
 )
 
+// Options configures the listeners of a Server.
+type Options struct {
+	ReadTimeout       time.Duration
+	ReadHeaderTimeout time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+
+	// TLS serves the main listener over HTTPS (HTTP/2 and HTTP/1.1); nil serves plain HTTP
+	TLS *TLSOptions
+}
+
+// Server runs the main listener and, optionally, the admin and redirect listeners. The
+// Run methods are meant to be called in their own goroutines; Shutdown may be called at
+// any time from another one, also before a listener has started.
 type Server struct {
-	server *http.Server
+	opts      Options
+	tlsConfig *tls.Config
+
+	mu      sync.Mutex
+	closed  bool
+	servers []*http.Server
+}
+
+// NewServer prepares a server with opts. With TLS enabled the certificate and client CA
+// are loaded here, so a broken TLS setup fails at startup rather than on the first handshake.
+func NewServer(opts Options) (*Server, error) {
+	s := &Server{opts: opts}
+	if opts.TLS != nil {
+		config, err := opts.TLS.config()
+		if err != nil {
+			return nil, err
+		}
+		s.tlsConfig = config
+	}
+	return s, nil
 }
 
 func (s *Server) RunServer(port string, handler http.Handler) error {
-	s.server = &http.Server{
-		Addr:         ":" + port,
-		Handler:      handler,
-		ReadTimeout:  10 * time.Second,
-		WriteTimeout: 10 * time.Second,
+	server, err := s.add(port, handler)
+	if err != nil {
+		return err
+	}
+	if s.tlsConfig == nil {
+		return server.ListenAndServe()
+	}
+	server.TLSConfig = s.tlsConfig
+	// Certificates come from TLSConfig.GetCertificate, so no file names here
+	return server.ListenAndServeTLS("", "")
+}
+
+// RunAdminServer serves operational endpoints such as /metrics on a separate port,
+// so they can be kept off the public listener. It is stopped together with the main server.
+// The admin port is meant for the internal network and always speaks plain HTTP.
+func (s *Server) RunAdminServer(port string, handler http.Handler) error {
+	server, err := s.add(port, handler)
+	if err != nil {
+		return err
 	}
-	return s.server.ListenAndServe()
+	return server.ListenAndServe()
 }
 
+// RunRedirectServer serves plain HTTP on port and permanently redirects every request to
+// the same URL over HTTPS on httpsPort. It is stopped together with the main server.
+func (s *Server) RunRedirectServer(port, httpsPort string) error {
+	server, err := s.add(port, redirectHandler(httpsPort))
+	if err != nil {
+		return err
+	}
+	return server.ListenAndServe()
+}
+
+// redirectHandler permanently redirects every request to the same URL over HTTPS on httpsPort
+func redirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host := r.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+		target := "https://" + host + r.URL.RequestURI()
+		http.Redirect(w, r, target, http.StatusPermanentRedirect)
+	})
+}
+
+// add creates a listener's http.Server and registers it for Shutdown. After Shutdown it
+// returns http.ErrServerClosed, so a listener that starts late does not keep running.
+func (s *Server) add(port string, handler http.Handler) (*http.Server, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return nil, http.ErrServerClosed
+	}
+	server := &http.Server{
+		Addr:              ":" + port,
+		Handler:           handler,
+		ReadTimeout:       s.opts.ReadTimeout,
+		ReadHeaderTimeout: s.opts.ReadHeaderTimeout,
+		WriteTimeout:      s.opts.WriteTimeout,
+		IdleTimeout:       s.opts.IdleTimeout,
+		MaxHeaderBytes:    s.opts.MaxHeaderBytes,
+	}
+	s.servers = append(s.servers, server)
+	return server, nil
+}
+
+// Shutdown gracefully stops every started listener and prevents the others from starting.
 func (s *Server) Shutdown(ctx context.Context) error {
-	return s.server.Shutdown(ctx)
+	s.mu.Lock()
+	s.closed = true
+	servers := s.servers
+	s.mu.Unlock()
+
+	var errs []error
+	for _, server := range servers {
+		errs = append(errs, server.Shutdown(ctx))
+	}
+	return errors.Join(errs...)
 }
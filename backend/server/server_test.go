@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsPort string
+		target    string
+		want      string
+	}{
+		{"default port", "443", "http://library.example/api/books?q=go", "https://library.example/api/books?q=go"},
+		{"custom port", "8443", "http://library.example/api/books", "https://library.example:8443/api/books"},
+		{"request port dropped", "443", "http://library.example:8080/", "https://library.example/"},
+		{"ipv6 host", "8443", "http://[::1]:8080/healthz", "https://[::1]:8443/healthz"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			redirectHandler(tt.httpsPort).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, tt.target, nil))
+			if rec.Code != http.StatusPermanentRedirect {
+				t.Errorf("status = %d, want %d", rec.Code, http.StatusPermanentRedirect)
+			}
+			if got := rec.Header().Get("Location"); got != tt.want {
+				t.Errorf("Location = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	handler := http.NotFoundHandler()
+	tests := []struct {
+		name string
+		run  func(s *Server) error
+	}{
+		{"main", func(s *Server) error { return s.RunServer("0", handler) }},
+		{"admin", func(s *Server) error { return s.RunAdminServer("0", handler) }},
+		{"redirect", func(s *Server) error { return s.RunRedirectServer("0", "443") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name+" started after shutdown", func(t *testing.T) {
+			s, _ := NewServer(Options{})
+			if err := s.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := tt.run(s); !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("run = %v, want %v", err, http.ErrServerClosed)
+			}
+		})
+		t.Run(tt.name+" started concurrently", func(t *testing.T) {
+			s, _ := NewServer(Options{})
+			done := make(chan error, 1)
+			go func() { done <- tt.run(s) }()
+			if err := s.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if err := <-done; !errors.Is(err, http.ErrServerClosed) {
+				t.Errorf("run = %v, want %v", err, http.ErrServerClosed)
+			}
+		})
+	}
+}
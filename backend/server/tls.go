@@ -0,0 +1,129 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// certCheckInterval limits how often the certificate files are checked for changes,
+// so handshakes do not stat the files every time
+const certCheckInterval = 10 * time.Second
+
+// TLSOptions configures HTTPS on the main listener.
+type TLSOptions struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables mutual TLS for clients such as self-service kiosks: client
+	// certificates signed by these CAs are verified. Unless RequireClientCert is set,
+	// clients without a certificate (staff browsers) are still accepted.
+	ClientCAFile      string
+	RequireClientCert bool
+}
+
+func (o TLSOptions) config() (*tls.Config, error) {
+	certs, err := newCertReloader(o.CertFile, o.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		NextProtos:     []string{"h2", "http/1.1"},
+		GetCertificate: certs.getCertificate,
+	}
+
+	if o.ClientCAFile != "" {
+		pem, err := os.ReadFile(o.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("read client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", o.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if o.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if o.RequireClientCert {
+		return nil, errors.New("client certificates are required but no client CA is set")
+	}
+	return config, nil
+}
+
+// certReloader serves the certificate from disk and loads it again when the certificate
+// or key file changes, so renewed certificates are picked up without a restart. If the
+// new files cannot be loaded (e.g. the key is not written yet), the previous certificate
+// is kept and loading is retried on the next check.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	modTime   time.Time
+	lastCheck time.Time
+}
+
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	r := &certReloader{certFile: certFile, keyFile: keyFile}
+	modTime, err := r.filesModTime()
+	if err != nil {
+		return nil, err
+	}
+	if err := r.load(modTime); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if now := time.Now(); now.Sub(r.lastCheck) >= certCheckInterval {
+		r.lastCheck = now
+		modTime, err := r.filesModTime()
+		if err == nil && !modTime.Equal(r.modTime) {
+			err = r.load(modTime)
+			if err == nil {
+				zap.L().Info("Reloaded TLS certificate", zap.String("cert", r.certFile))
+			}
+		}
+		if err != nil {
+			zap.L().Warn("Failed to reload TLS certificate, keeping the current one", zap.Error(err))
+		}
+	}
+	return r.cert, nil
+}
+
+func (r *certReloader) load(modTime time.Time) error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS certificate: %w", err)
+	}
+	r.cert, r.modTime = &cert, modTime
+	return nil
+}
+
+// filesModTime returns the later modification time of the certificate and key files
+func (r *certReloader) filesModTime() (time.Time, error) {
+	var latest time.Time
+	for _, name := range []string{r.certFile, r.keyFile} {
+		info, err := os.Stat(name)
+		if err != nil {
+			return time.Time{}, fmt.Errorf("load TLS certificate: %w", err)
+		}
+		if info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest, nil
+}
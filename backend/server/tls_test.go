@@ -0,0 +1,196 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCert writes a self-signed certificate for name and its key to dir and returns the paths
+func writeCert(t *testing.T, dir, name string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	writePEM(t, certFile, "CERTIFICATE", der)
+	writePEM(t, keyFile, "PRIVATE KEY", keyDER)
+	return certFile, keyFile
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// touch moves the modification time of the files forward, as a renewal would
+func touch(t *testing.T, files ...string) {
+	t.Helper()
+	later := time.Now().Add(time.Minute)
+	for _, f := range files {
+		if err := os.Chtimes(f, later, later); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func servedName(t *testing.T, r *certReloader) string {
+	t.Helper()
+	cert, err := r.getCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return leaf.Subject.CommonName
+}
+
+func TestCertReloader(t *testing.T) {
+	tests := []struct {
+		name string
+		// change runs after the reloader has loaded the "old" certificate
+		change    func(t *testing.T, dir, certFile, keyFile string)
+		recheck   bool // whether the check interval has passed
+		wantServe string
+	}{
+		{"unchanged", func(*testing.T, string, string, string) {}, true, "old"},
+		{"renewed", func(t *testing.T, dir, certFile, keyFile string) {
+			writeCert(t, dir, "new")
+			touch(t, certFile, keyFile)
+		}, true, "new"},
+		{"renewed within interval", func(t *testing.T, dir, certFile, keyFile string) {
+			writeCert(t, dir, "new")
+			touch(t, certFile, keyFile)
+		}, false, "old"},
+		{"key not written yet", func(t *testing.T, dir, certFile, keyFile string) {
+			other := t.TempDir()
+			newCert, _ := writeCert(t, other, "new")
+			data, _ := os.ReadFile(newCert)
+			os.WriteFile(certFile, data, 0o600)
+			touch(t, certFile)
+		}, true, "old"},
+		{"key removed", func(t *testing.T, dir, certFile, keyFile string) {
+			os.Remove(keyFile)
+		}, true, "old"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			certFile, keyFile := writeCert(t, dir, "old")
+			r, err := newCertReloader(certFile, keyFile)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := servedName(t, r); got != "old" {
+				t.Fatalf("serving %q before the change", got)
+			}
+
+			tt.change(t, dir, certFile, keyFile)
+			if tt.recheck {
+				r.lastCheck = time.Time{}
+			}
+			if got := servedName(t, r); got != tt.wantServe {
+				t.Errorf("serving %q, want %q", got, tt.wantServe)
+			}
+		})
+	}
+}
+
+func TestCertReloaderRetries(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "old")
+	r, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	os.Remove(keyFile)
+	r.lastCheck = time.Time{}
+	servedName(t, r)
+
+	// The failed attempt must not be remembered as loaded
+	writeCert(t, dir, "new")
+	touch(t, certFile, keyFile)
+	r.lastCheck = time.Time{}
+	if got := servedName(t, r); got != "new" {
+		t.Errorf("serving %q after the key came back, want %q", got, "new")
+	}
+}
+
+func TestTLSOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := writeCert(t, dir, "library.example")
+	empty := filepath.Join(dir, "empty.pem")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		opts       TLSOptions
+		wantErr    bool
+		wantClient tls.ClientAuthType
+	}{
+		{"server only", TLSOptions{CertFile: certFile, KeyFile: keyFile}, false, tls.NoClientCert},
+		{"optional client cert", TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile}, false, tls.VerifyClientCertIfGiven},
+		{"required client cert", TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: certFile, RequireClientCert: true},
+			false, tls.RequireAndVerifyClientCert},
+		{"required without CA", TLSOptions{CertFile: certFile, KeyFile: keyFile, RequireClientCert: true}, true, 0},
+		{"CA file without certificates", TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: empty}, true, 0},
+		{"missing CA file", TLSOptions{CertFile: certFile, KeyFile: keyFile, ClientCAFile: empty + ".missing"}, true, 0},
+		{"missing key", TLSOptions{CertFile: certFile, KeyFile: keyFile + ".missing"}, true, 0},
+		{"key does not match", TLSOptions{CertFile: certFile, KeyFile: certFile}, true, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := tt.opts.config()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("err = %v, want error %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if config.ClientAuth != tt.wantClient {
+				t.Errorf("ClientAuth = %v, want %v", config.ClientAuth, tt.wantClient)
+			}
+			if config.MinVersion != tls.VersionTLS12 {
+				t.Errorf("MinVersion = %x, want TLS 1.2", config.MinVersion)
+			}
+		})
+	}
+}